@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/42wim/dt/pkg/dt"
+	"github.com/briandowns/spinner"
+)
+
+// bulkResult is the outcome of one domain of a bulk scan.
+type bulkResult struct {
+	Domain string
+	// Hosts are the common hosts of an expanded zone compared between its
+	// nameservers.
+	Hosts  []string   `json:",omitempty"`
+	Error  string     `json:",omitempty"`
+	Result *dt.Result `json:",omitempty"`
+}
+
+// readDomains reads one domain per line from file, or from stdin when file is
+// "-". Blank lines and lines starting with # are skipped.
+func readDomains(file string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var domains []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains = append(domains, strings.Fields(line)[0])
+	}
+	return domains, scanner.Err()
+}
+
+// bulk runs the full check suite against every domain listed in file and
+// ends with a summary of all domains. With a policy every domain is evaluated
+// against it, pass is false when one violates it or fails to scan.
+func bulk(file string, opts dt.Options, policy *dt.Policy) (pass bool, err error) {
+	domains, err := readDomains(file)
+	if err != nil {
+		return false, err
+	}
+	if len(domains) == 0 {
+		return false, fmt.Errorf("no domains found in %s", file)
+	}
+	var targets []dt.Target
+	for _, domain := range domains {
+		targets = append(targets, dt.Target{Domain: domain})
+	}
+	return scanTargets(targets, opts, policy)
+}
+
+// expand runs the full check suite against domain (*.example.com or an apex
+// with -include-delegations), its delegated subzones down to depth and at
+// most limit zones. The common hosts found in every zone are compared
+// between its nameservers. The policy is evaluated like with bulk.
+func expand(domain string, depth, limit int, opts dt.Options, policy *dt.Policy) (pass bool, err error) {
+	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	s.Suffix = fmt.Sprintf(" Expanding %s...", domain)
+	if !*flagDebug && !*flagJSON {
+		s.Start()
+	}
+	targets, err := dt.Expand(domain, depth, limit, opts)
+	s.Stop()
+	if err != nil {
+		return false, err
+	}
+	return scanTargets(targets, opts, policy)
+}
+
+// scanTargets scans every target, with its hosts added to the sync names,
+// and ends with a summary of all of them. pass is false when a target
+// violates policy or fails to scan.
+func scanTargets(targets []dt.Target, opts dt.Options, policy *dt.Policy) (pass bool, err error) {
+	pass = true
+	var results []bulkResult
+	syncNames := opts.SyncNames
+	for i, target := range targets {
+		domain := target.Domain
+		opts.SyncNames = append(append([]string{}, syncNames...), target.Hosts...)
+		s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+		s.Suffix = fmt.Sprintf(" Checking %s (%v/%v)...", domain, i+1, len(targets))
+		if !*flagDebug && !*flagJSON {
+			s.Start()
+		}
+		result, err := dt.Scan(context.Background(), domain, opts)
+		s.Stop()
+		br := bulkResult{Domain: domain, Hosts: target.Hosts, Result: result}
+		if err != nil {
+			br.Error = err.Error()
+			pass = false
+		} else if policy != nil {
+			verdict := policy.Evaluate(result)
+			result.Policy = &verdict
+			pass = pass && verdict.Pass
+		}
+		results = append(results, br)
+		if *flagJSON {
+			continue
+		}
+		fmt.Printf("=== %s (%v/%v)\n", domain, i+1, len(targets))
+		if len(target.Hosts) > 0 {
+			fmt.Printf("Hosts compared between the nameservers: %s\n", strings.Join(target.Hosts, ", "))
+		}
+		if err != nil {
+			fmt.Printf("ERR : %s\n\n", err)
+			continue
+		}
+		printResult(result)
+		if *flagScan {
+			if err := dt.DomainScan(domain, opts); err != nil {
+				fmt.Println(err)
+			}
+		}
+		printSummary(result.Summary)
+		if result.Policy != nil {
+			printVerdict(*result.Policy)
+		}
+		if *flagTimings {
+			printTimings(result.Timings)
+		}
+		fmt.Println()
+	}
+	if *flagJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return pass, enc.Encode(results)
+	}
+	printBulkSummary(results)
+	return pass, nil
+}
+
+// printBulkSummary prints the grade and result counts of every domain of a
+// bulk scan, with the totals and the grade distribution.
+func printBulkSummary(results []bulkResult) {
+	fmt.Printf("Bulk summary (%v domains)\n", len(results))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\t Domain\tGrade\tOK\tWARN\tFAIL\tERR\tSKIP\tMost critical finding")
+	totals := make(map[string]int)
+	grades := make(map[string]int)
+	failed := 0
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+			fmt.Fprintf(w, "\t %s\t-\t\t\t\t\t\t%s\n", r.Domain, r.Error)
+			continue
+		}
+		s := r.Result.Summary
+		grades[s.Grade]++
+		for status, n := range s.Counts {
+			totals[status] += n
+		}
+		top := ""
+		if len(s.Top) > 0 {
+			top = s.Top[0]
+		}
+		fmt.Fprintf(w, "\t %s\t%s\t%v\t%v\t%v\t%v\t%v\t%s\n", r.Domain, s.Grade, s.Counts["OK"], s.Counts["WARN"], s.Counts["FAIL"], s.Counts["ERR"], s.Counts["SKIP"], top)
+	}
+	fmt.Fprintf(w, "\t Total\t\t%v\t%v\t%v\t%v\t%v\t\n", totals["OK"], totals["WARN"], totals["FAIL"], totals["ERR"], totals["SKIP"])
+	w.Flush()
+	var dist []string
+	for _, grade := range []string{"A", "B", "C", "D", "F", "parked"} {
+		if grades[grade] > 0 {
+			dist = append(dist, fmt.Sprintf("%v x %s", grades[grade], grade))
+		}
+	}
+	if failed > 0 {
+		dist = append(dist, fmt.Sprintf("%v not checked", failed))
+	}
+	fmt.Printf("\t Grades: %s\n", strings.Join(dist, ", "))
+	evaluated, violated := 0, 0
+	for _, r := range results {
+		if r.Result != nil && r.Result.Policy != nil {
+			evaluated++
+			if !r.Result.Policy.Pass {
+				violated++
+			}
+		}
+	}
+	if evaluated > 0 {
+		fmt.Printf("\t Policy: %v of %v domains violate it\n", violated, evaluated)
+	}
+}
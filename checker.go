@@ -1,6 +0,0 @@
-package main
-
-type Checker interface {
-	Scan(string)
-	CreateReport(string) Report
-}
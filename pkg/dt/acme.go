@@ -0,0 +1,77 @@
+package dt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// acmeHosts are the hosts whose _acme-challenge names are checked.
+var acmeHosts = []string{"", "www.", "mail.", "autodiscover.", "api.", "app.", "dev.", "staging.", "test.", "vpn.", "remote.", "webmail.", "smtp.", "imap.", "ftp.", "blog.", "shop.", "portal."}
+
+type AcmeCheck struct {
+	NS   []NSData
+	Acme []AcmeData
+	Report
+}
+
+type AcmeData struct {
+	Name     string
+	TXT      []dns.RR
+	Target   string
+	Dangling bool
+}
+
+func (c *AcmeCheck) Scan(domain string) {
+	if len(c.NS) == 0 || len(c.NS[0].IP) == 0 {
+		return
+	}
+	server := c.NS[0].addr(c.NS[0].IP[0])
+	for _, host := range acmeHosts {
+		name := "_acme-challenge." + host + dns.Fqdn(domain)
+		res, err := c.s.query(name, dns.TypeTXT, server, false)
+		if err != nil {
+			continue
+		}
+		data := AcmeData{Name: name, TXT: extractRR(res.Msg.Answer, dns.TypeTXT)}
+		if cname := extractRR(res.Msg.Answer, dns.TypeCNAME); len(cname) > 0 {
+			data.Target = cname[0].(*dns.CNAME).Target
+			if _, err := c.s.query(data.Target, dns.TypeTXT, c.s.resolver, false); err != nil && strings.Contains(err.Error(), "NXDOMAIN") {
+				data.Dangling = true
+			}
+		}
+		if len(data.TXT) > 0 || data.Target != "" {
+			c.Acme = append(c.Acme, data)
+		}
+	}
+}
+
+func (c *AcmeCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	for _, data := range c.Acme {
+		switch {
+		case data.Dangling:
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: %s is delegated to %s which does not exist. Whoever controls that name can get certificates issued for you.", data.Name, data.Target),
+				Status: false, Name: "AcmeDangling", Remediation: "Remove the _acme-challenge delegation or point it at a name you control."})
+		case data.Target != "":
+			results = append(results, ReportResult{Result: fmt.Sprintf("OK  : %s is delegated to %s for validation", data.Name, data.Target),
+				Status: true, Name: "AcmeDelegation"})
+		case len(data.TXT) > 0:
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: %s has %v stale TXT record(s). ACME validation tokens should be removed after issuance.", data.Name, len(data.TXT)),
+				Status: false, Name: "AcmeStale", Remediation: "Remove the _acme-challenge TXT records once the certificates are issued."})
+		}
+	}
+	if len(results) == 0 {
+		results = append(results, ReportResult{Result: "OK  : No stale _acme-challenge records found",
+			Status: true, Name: "AcmeStale"})
+	}
+	return results
+}
+
+func (c *AcmeCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "ACME"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
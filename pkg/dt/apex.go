@@ -0,0 +1,121 @@
+package dt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// typeHTTPS is the HTTPS RR type (RFC 9460), not known by our dns library.
+const typeHTTPS = 65
+
+// apexTypes are the record types summarized for the apex. Only the required
+// ones are a finding when absent, the others are reported for information.
+var apexTypes = []struct {
+	Name     string
+	Qtype    uint16
+	Required bool
+}{
+	{"SOA", dns.TypeSOA, true},
+	{"NS", dns.TypeNS, true},
+	{"A", dns.TypeA, false},
+	{"AAAA", dns.TypeAAAA, false},
+	{"MX", dns.TypeMX, false},
+	{"TXT", dns.TypeTXT, false},
+	{"SPF", dns.TypeTXT, false},
+	{"CAA", dns.TypeCAA, false},
+	{"HTTPS", typeHTTPS, false},
+	{"DNSKEY", dns.TypeDNSKEY, false},
+}
+
+type ApexCheck struct {
+	NS    []NSData
+	Count map[string]int
+	// Errors are the lookups that failed by type, unknown rather than absent.
+	Errors map[string]string `json:",omitempty"`
+	Report
+}
+
+func (c *ApexCheck) Scan(domain string) {
+	server, ok := c.s.respondingServer(c.NS, domain)
+	if !ok {
+		c.Report.Result = append(c.Report.Result, ReportResult{Result: "ERR : None of the nameservers answered, apex records unknown", Name: "Apex"})
+		return
+	}
+	c.Count = make(map[string]int)
+	c.Errors = make(map[string]string)
+	for _, t := range apexTypes {
+		if t.Name == "SPF" {
+			continue
+		}
+		rrset, _, err := c.s.queryRRset(domain, t.Qtype, server, true)
+		if err != nil {
+			// NXDOMAIN and NODATA mean absent, anything else unknown
+			if !strings.Contains(err.Error(), "NXDOMAIN") && !strings.Contains(err.Error(), "no rr for") {
+				c.Errors[t.Name] = err.Error()
+				if t.Qtype == dns.TypeTXT {
+					c.Errors["SPF"] = err.Error()
+				}
+			}
+			continue
+		}
+		c.Count[t.Name] = len(rrset)
+		if t.Qtype == dns.TypeTXT {
+			for _, rr := range rrset {
+				if strings.HasPrefix(txtString(rr.(*dns.TXT)), "v=spf1") {
+					c.Count["SPF"]++
+				}
+			}
+		}
+	}
+}
+
+// Missing returns the record types of names that aren't published at the apex.
+// Nothing is missing when the apex couldn't be scanned, nor are the types
+// whose lookup failed.
+func (c *ApexCheck) Missing(names []string) []string {
+	var missing []string
+	if c.Count == nil {
+		return missing
+	}
+	for _, name := range names {
+		if _, failed := c.Errors[name]; !failed && c.Count[name] == 0 {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+func (c *ApexCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	if c.Count == nil {
+		return results
+	}
+	for _, t := range apexTypes {
+		n := c.Count[t.Name]
+		err, failed := c.Errors[t.Name]
+		switch {
+		case n > 0:
+			results = append(results, ReportResult{Result: fmt.Sprintf("OK  : %-6s present (%v)", t.Name, n),
+				Status: true, Name: t.Name})
+		case failed:
+			results = append(results, ReportResult{Result: fmt.Sprintf("ERR : %-6s lookup failed: %s", t.Name, err),
+				Name: t.Name, Error: err})
+		case t.Required:
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: %-6s absent", t.Name),
+				Status: false, Name: t.Name, Remediation: "Publish the record at the apex of the zone."})
+		default:
+			results = append(results, ReportResult{Result: fmt.Sprintf("SKIP: %-6s absent", t.Name),
+				Status: true, Name: t.Name})
+		}
+	}
+	return results
+}
+
+func (c *ApexCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "Apex"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
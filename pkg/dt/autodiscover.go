@@ -0,0 +1,120 @@
+package dt
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+type AutodiscoverCheck struct {
+	NS   []NSData
+	Data []AutodiscoverData
+	Report
+}
+
+type AutodiscoverData struct {
+	Name   string
+	Client string
+	Target string
+	URL    string
+	IP     []net.IP
+	Status int
+	Error  string
+}
+
+// fetchStatus does a GET on url and returns the HTTP status code.
+func (s *session) fetchStatus(url string) (int, error) {
+	var redirects int
+	resp, err := s.httpClient(&redirects).Get(url)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func (c *AutodiscoverCheck) Scan(domain string) {
+	apex := strings.TrimSuffix(dns.Fqdn(domain), ".")
+
+	host := "autodiscover." + apex
+	data := AutodiscoverData{Name: host, Client: "Outlook", Target: host, IP: c.s.resolveHost(host),
+		URL: "https://" + host + "/autodiscover/autodiscover.xml"}
+	var err error
+	if len(data.IP) > 0 {
+		if data.Status, err = c.s.fetchStatus(data.URL); err != nil {
+			data.Error = err.Error()
+		}
+	}
+	c.Data = append(c.Data, data)
+
+	srv, _, err := c.s.queryRRset("_autodiscover._tcp."+apex, dns.TypeSRV, c.s.resolver, false)
+	if err == nil {
+		for _, rr := range srv {
+			target := strings.TrimSuffix(rr.(*dns.SRV).Target, ".")
+			port := strconv.Itoa(int(rr.(*dns.SRV).Port))
+			data := AutodiscoverData{Name: "_autodiscover._tcp." + apex, Client: "Outlook", Target: target, IP: c.s.resolveHost(target),
+				URL: "https://" + net.JoinHostPort(target, port) + "/autodiscover/autodiscover.xml"}
+			if len(data.IP) > 0 {
+				if data.Status, err = c.s.fetchStatus(data.URL); err != nil {
+					data.Error = err.Error()
+				}
+			}
+			c.Data = append(c.Data, data)
+		}
+	}
+
+	host = "autoconfig." + apex
+	data = AutodiscoverData{Name: host, Client: "Thunderbird", Target: host, IP: c.s.resolveHost(host),
+		URL: "https://" + host + "/mail/config-v1.1.xml"}
+	if len(data.IP) > 0 {
+		if data.Status, err = c.s.fetchStatus(data.URL); err != nil {
+			data.Error = err.Error()
+		}
+	}
+	c.Data = append(c.Data, data)
+}
+
+func (c *AutodiscoverCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	found := make(map[string]bool)
+	for _, data := range c.Data {
+		if len(data.IP) == 0 {
+			if data.Target != data.Name {
+				results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: %s points to %s which does not resolve", data.Name, data.Target),
+					Status: false, Name: data.Client, Remediation: "Point the record at a hostname that resolves, or remove it."})
+			}
+			continue
+		}
+		found[data.Client] = true
+		switch {
+		case data.Error != "":
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: %s does not serve HTTPS: %s", data.URL, data.Error),
+				Status: false, Name: data.Client, Remediation: "Serve the autodiscover URL over HTTPS with a valid certificate, or remove the record."})
+		case data.Client == "Thunderbird" && data.Status != 200:
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: %s returned HTTP %v", data.URL, data.Status),
+				Status: false, Name: data.Client, Remediation: "Fix the web server so the autodiscover URL answers with a success status."})
+		default:
+			results = append(results, ReportResult{Result: fmt.Sprintf("OK  : %s serves HTTPS (HTTP %v)", data.URL, data.Status),
+				Status: true, Name: data.Client})
+		}
+	}
+	if !found["Outlook"] {
+		results = append(results, ReportResult{Result: "WARN: No autodiscover record or _autodiscover._tcp SRV found. Outlook can't set up accounts automatically.",
+			Status: false, Name: "Outlook", Remediation: "Add an autodiscover CNAME or an _autodiscover._tcp SRV record pointing at your mail provider."})
+	}
+	if !found["Thunderbird"] {
+		results = append(results, ReportResult{Result: "WARN: No autoconfig record found. Thunderbird can't set up accounts automatically.",
+			Status: false, Name: "Thunderbird", Remediation: "Add an autoconfig CNAME pointing at your mail provider."})
+	}
+	return results
+}
+
+func (c *AutodiscoverCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "Autodiscover"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
@@ -0,0 +1,83 @@
+package dt
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// AXFRCheck attempts a zone transfer from every address of every
+// authoritative nameserver and reports the ones allowing it to anyone.
+type AXFRCheck struct {
+	NS   []NSData
+	AXFR []AXFRData
+	Report
+}
+
+type AXFRData struct {
+	Name string
+	IP   string
+	// Records is the number of records transferred, Error why the transfer
+	// was refused or broke off.
+	Records int
+	Error   string
+}
+
+func (c *AXFRCheck) Scan(domain string) {
+	apex := dns.Fqdn(domain)
+	for _, ns := range c.NS {
+		for _, ip := range ns.IP {
+			c.AXFR = append(c.AXFR, AXFRData{Name: ns.Name, IP: ns.addr(ip)})
+		}
+	}
+	sem := make(chan struct{}, c.s.concurrency)
+	var wg sync.WaitGroup
+	for i := range c.AXFR {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(d *AXFRData) {
+			defer func() { <-sem; wg.Done() }()
+			err := c.s.streamTransfer(apex, d.IP, func(envelope []dns.RR) {
+				d.Records += len(envelope)
+			})
+			if err != nil {
+				d.Error = err.Error()
+			}
+		}(&c.AXFR[i])
+	}
+	wg.Wait()
+}
+
+func (c *AXFRCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	var refused []string
+	for _, d := range c.AXFR {
+		server := fmt.Sprintf("%s (%s)", d.Name, d.IP)
+		switch {
+		case d.Records > 0 && d.Error != "":
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: %s allows zone transfers to anyone, leaked at least %v records before it broke off: %s", server, d.Records, d.Error),
+				Status: false, Name: "AXFR", Server: d.IP, Error: d.Error,
+				Remediation: "Restrict AXFR/IXFR to the addresses of your secondaries, or require TSIG."})
+		case d.Records > 0:
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: %s allows zone transfers to anyone, leaked %v records", server, d.Records),
+				Status: false, Name: "AXFR", Server: d.IP,
+				Remediation: "Restrict AXFR/IXFR to the addresses of your secondaries, or require TSIG."})
+		default:
+			refused = append(refused, server)
+		}
+	}
+	if len(results) == 0 && len(refused) > 0 {
+		results = append(results, ReportResult{Result: fmt.Sprintf("OK  : Zone transfer refused by all nameservers: %s", strings.Join(refused, ", ")),
+			Status: true, Name: "AXFR"})
+	}
+	return results
+}
+
+func (c *AXFRCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "AXFR"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
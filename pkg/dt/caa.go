@@ -0,0 +1,179 @@
+package dt
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// caaIssuers maps certificate issuer organizations to the CAA identifiers the
+// CA honours.
+var caaIssuers = []struct {
+	Org     string
+	Domains []string
+}{
+	{"Let's Encrypt", []string{"letsencrypt.org"}},
+	{"DigiCert", []string{"digicert.com", "symantec.com", "geotrust.com", "rapidssl.com", "thawte.com"}},
+	{"ZeroSSL", []string{"sectigo.com", "zerossl.com"}},
+	{"Sectigo", []string{"sectigo.com", "comodoca.com", "comodo.com", "usertrust.com", "trust-provider.com"}},
+	{"Google Trust Services", []string{"pki.goog", "google.com"}},
+	{"Amazon", []string{"amazon.com", "amazontrust.com", "awstrust.com", "amazonaws.com"}},
+	{"GlobalSign", []string{"globalsign.com"}},
+	{"GoDaddy", []string{"godaddy.com", "starfieldtech.com"}},
+	{"Entrust", []string{"entrust.net", "affirmtrust.com"}},
+	{"Buypass", []string{"buypass.com", "buypass.no"}},
+	{"SSL.com", []string{"ssl.com"}},
+}
+
+// findCAA returns the relevant CAA RRset for domain, climbing towards the root
+// until a non-empty set is found (RFC 8659 section 3).
+func (s *session) findCAA(domain string) []dns.RR {
+	domain = dns.Fqdn(domain)
+	for domain != "." {
+		rrset, _, err := s.queryRRset(domain, dns.TypeCAA, s.resolver, false)
+		if err == nil && len(rrset) > 0 {
+			return rrset
+		}
+		domain = getParentDomain(domain)
+	}
+	return []dns.RR{}
+}
+
+// caaIssue returns the issuer domains authorized by the tag (issue or
+// issuewild). An empty result for a present tag means issuance is forbidden.
+func caaIssue(rrset []dns.RR, tag string) ([]string, bool) {
+	var domains []string
+	found := false
+	for _, rr := range rrset {
+		caa, ok := rr.(*dns.CAA)
+		if !ok || strings.ToLower(caa.Tag) != tag {
+			continue
+		}
+		found = true
+		issuer := strings.TrimSpace(strings.SplitN(caa.Value, ";", 2)[0])
+		if issuer != "" {
+			domains = append(domains, strings.ToLower(issuer))
+		}
+	}
+	return domains, found
+}
+
+// caaDomains returns the CAA identifiers for the issuer organization, or nil
+// when the CA is unknown.
+func caaDomains(org string) []string {
+	for _, issuer := range caaIssuers {
+		if strings.Contains(strings.ToLower(org), strings.ToLower(issuer.Org)) {
+			return issuer.Domains
+		}
+	}
+	return nil
+}
+
+// caaTags are the property tags defined for CAA (RFC 8659, RFC 9495).
+var caaTags = map[string]bool{"issue": true, "issuewild": true, "iodef": true,
+	"issuemail": true, "issuevmc": true, "contactemail": true, "contactphone": true}
+
+// caaIssuerValue validates an issue or issuewild value: an optional issuer
+// domain followed by ; separated key=value parameters (RFC 8659 section 4.2).
+func caaIssuerValue(value string) error {
+	parts := strings.Split(value, ";")
+	if issuer := strings.TrimSpace(parts[0]); issuer != "" {
+		if _, ok := dns.IsDomainName(issuer); !ok || strings.HasSuffix(issuer, ".") || !strings.Contains(issuer, ".") {
+			return fmt.Errorf("issuer %q is not a domain name", issuer)
+		}
+		for _, label := range strings.Split(issuer, ".") {
+			if !validLabel(strings.ToLower(label)) {
+				return fmt.Errorf("issuer %q is not a domain name", issuer)
+			}
+		}
+	}
+	for _, param := range parts[1:] {
+		param = strings.TrimSpace(param)
+		if param == "" {
+			continue
+		}
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || strings.ContainsAny(kv[0]+kv[1], " \t") {
+			return fmt.Errorf("parameter %q is not key=value", param)
+		}
+	}
+	return nil
+}
+
+type CAACheck struct {
+	NS    []NSData
+	CAA   []dns.RR
+	Owner string
+	Report
+}
+
+func (c *CAACheck) Scan(domain string) {
+	c.CAA = c.s.findCAA(domain)
+	if len(c.CAA) > 0 {
+		c.Owner = c.CAA[0].Header().Name
+	}
+}
+
+func (c *CAACheck) Values(domain string) []ReportResult {
+	results := []ReportResult{}
+	if len(c.CAA) == 0 {
+		return append(results, ReportResult{Result: fmt.Sprintf("WARN: No CAA records for %s or its parents, every CA may issue certificates for it", dns.Fqdn(domain)),
+			Status: false, Name: "CAA", Remediation: "Publish CAA issue records naming the CAs you use, e.g. 0 issue \"letsencrypt.org\"."})
+	}
+	var issuers []string
+	for _, rr := range c.CAA {
+		caa := rr.(*dns.CAA)
+		tag := strings.ToLower(caa.Tag)
+		record := fmt.Sprintf("%v %s %q", caa.Flag, caa.Tag, caa.Value)
+		switch {
+		case !caaTags[tag] && caa.Flag&128 != 0:
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: CAA record %s has the critical flag on unknown tag %s, CAs must refuse to issue", record, caa.Tag),
+				Status: false, Name: "CAASyntax", Remediation: "Remove the critical flag (128) or the record with the unknown tag."})
+			continue
+		case !caaTags[tag]:
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: CAA record %s has unknown tag %s, CAs ignore it", record, caa.Tag),
+				Status: false, Name: "CAASyntax", Remediation: "Check the spelling of the tag (issue, issuewild or iodef)."})
+			continue
+		case caa.Flag&127 != 0:
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: CAA record %s sets reserved flags %v", record, caa.Flag&127),
+				Status: false, Name: "CAASyntax", Remediation: "Use flag 0, or 128 for critical records."})
+		}
+		switch tag {
+		case "issue", "issuewild":
+			if err := caaIssuerValue(caa.Value); err != nil {
+				results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: CAA record %s is invalid: %s", record, err),
+					Status: false, Name: "CAASyntax", Remediation: "Use the issuer domain the CA documents, e.g. \"letsencrypt.org\", optionally followed by ; key=value parameters."})
+				continue
+			}
+			issuer := strings.TrimSpace(strings.SplitN(caa.Value, ";", 2)[0])
+			if issuer == "" {
+				issuer = "nobody"
+			}
+			issuers = append(issuers, tag+" "+issuer)
+		case "iodef":
+			u, err := url.Parse(caa.Value)
+			if err != nil || (u.Scheme != "mailto" && u.Scheme != "http" && u.Scheme != "https") {
+				results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: CAA iodef %q is not a mailto: or http(s): URL", caa.Value),
+					Status: false, Name: "CAASyntax", Remediation: "Use a mailto: address or an https:// URL to receive incident reports."})
+			}
+		}
+	}
+	if len(results) == 0 {
+		results = append(results, ReportResult{Result: fmt.Sprintf("OK  : CAA records are valid (%s)", strings.Join(issuers, ", ")),
+			Status: true, Name: "CAASyntax"})
+	}
+	if !strings.EqualFold(c.Owner, dns.Fqdn(domain)) {
+		results = append(results, ReportResult{Result: fmt.Sprintf("OK  : CAA records of %s apply to %s", c.Owner, dns.Fqdn(domain)),
+			Status: true, Name: "CAA"})
+	}
+	return results
+}
+
+func (c *CAACheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "CAA"
+	c.Report.Result = append(c.Report.Result, c.Values(domain)...)
+	return c.Report
+}
@@ -0,0 +1,27 @@
+package dt
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzCAAIssuerValue(f *testing.F) {
+	for _, seed := range []string{
+		"letsencrypt.org",
+		"letsencrypt.org; validationmethods=dns-01; accounturi=https://acme-v02.api.letsencrypt.org/acme/acct/1",
+		";",
+		"pki.goog; cansignhttpexchanges=yes",
+		"bad_issuer..org; =x",
+		"letsencrypt.org.",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		if caaIssuerValue(value) != nil {
+			return
+		}
+		if issuer := strings.TrimSpace(strings.Split(value, ";")[0]); strings.HasSuffix(issuer, ".") {
+			t.Fatalf("%q: accepted issuer %q with a trailing dot", value, issuer)
+		}
+	})
+}
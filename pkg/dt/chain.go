@@ -0,0 +1,265 @@
+package dt
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// rootAnchors are the DS records of the root key signing keys (KSK-2017 and
+// KSK-2024) published by IANA.
+var rootAnchors = []string{
+	". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	". IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+}
+
+// chainLink is one step of the DNSSEC chain of trust: the DS of Zone at
+// Parent (the trust anchor for the root) proving the DNSKEY set of Zone.
+type chainLink struct {
+	Parent string
+	Zone   string
+	// Keys are the DNSKEYs of Zone the DS records point at.
+	Keys []string
+	// Insecure is set when Parent has no DS for Zone.
+	Insecure bool
+	Err      error
+	// dnskeys is the validated DNSKEY set of Zone.
+	dnskeys []*dns.DNSKEY
+}
+
+func (l chainLink) String() string {
+	if l.Parent == "" {
+		return "trust anchor -> ."
+	}
+	return l.Parent + " -> " + l.Zone
+}
+
+// zoneCuts returns the root and every zone between it and domain.
+func (s *session) zoneCuts(domain string) []string {
+	cuts := []string{"."}
+	labels := dns.SplitDomainName(dns.Fqdn(domain))
+	for i := len(labels) - 1; i >= 0; i-- {
+		name := dns.Fqdn(strings.Join(labels[i:], "."))
+		ns, _, err := s.queryRRset(name, dns.TypeNS, s.resolver, false)
+		if err == nil && strings.EqualFold(ns[0].Header().Name, name) {
+			cuts = append(cuts, name)
+		}
+	}
+	return cuts
+}
+
+// zoneQuery asks the nameservers of a zone for qname until one answers. It
+// returns the records of qtype and their signatures.
+func (s *session) zoneQuery(nsdatas []NSData, qname string, qtype uint16) ([]dns.RR, error) {
+	err := fmt.Errorf("no nameservers")
+	for _, ns := range nsdatas {
+		for _, ip := range ns.IP {
+			var res Response
+			if res, err = s.query(qname, qtype, ns.addr(ip), true); err != nil {
+				continue
+			}
+			return extractRR(res.Msg.Answer, qtype, dns.TypeRRSIG), nil
+		}
+	}
+	return nil, err
+}
+
+// verifySigned checks an RRset has a valid signature by one of keys. rrs may
+// hold signatures covering other types, they are ignored.
+func (s *session) verifySigned(rrs []dns.RR, keys []*dns.DNSKEY) error {
+	var records []dns.RR
+	var sigs []*dns.RRSIG
+	for _, rr := range rrs {
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			sigs = append(sigs, sig)
+		} else {
+			records = append(records, rr)
+		}
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("no records")
+	}
+	qtype := records[0].Header().Rrtype
+	var tags []string
+	for _, key := range keys {
+		tags = append(tags, fmt.Sprint(key.KeyTag()))
+	}
+	now := time.Now()
+	var outdated *dns.RRSIG
+	found := false
+	for _, sig := range sigs {
+		if sig.TypeCovered != qtype {
+			continue
+		}
+		found = true
+		for _, key := range keys {
+			if key.KeyTag() != sig.KeyTag || key.Algorithm != sig.Algorithm || sig.Verify(key, records) != nil {
+				continue
+			}
+			if sig.ValidityPeriod(now) {
+				return nil
+			}
+			outdated = sig
+		}
+	}
+	switch {
+	case !found:
+		return fmt.Errorf("%s is not signed", dns.TypeToString[qtype])
+	case outdated != nil:
+		ti, te := explicitValid(outdated)
+		return fmt.Errorf("RRSIG on %s by key %v is only valid from %s to %s", dns.TypeToString[qtype], outdated.KeyTag,
+			FormatTime(time.Unix(ti, 0), s.useUTC), FormatTime(time.Unix(te, 0), s.useUTC))
+	}
+	return fmt.Errorf("no RRSIG on %s verifies with key %s", dns.TypeToString[qtype], strings.Join(tags, ", "))
+}
+
+// walkChain follows the chain of trust from the root trust anchor down every
+// zone cut to the zone of domain, verifying DS -> DNSKEY -> RRSIG at every
+// level. It stops at the first broken or insecure link, which is the last
+// one returned.
+func (s *session) walkChain(domain string) []chainLink {
+	trusted := s.trustAnchors
+	if len(trusted) == 0 {
+		for _, anchor := range rootAnchors {
+			rr, _ := dns.NewRR(anchor)
+			trusted = append(trusted, rr.(*dns.DS))
+		}
+	}
+	var links []chainLink
+	var parentKeys []*dns.DNSKEY
+	var parentNS []NSData
+	for i, zone := range s.zoneCuts(domain) {
+		link := chainLink{Zone: zone}
+		nsdatas, err := s.findNS(zone)
+		if err != nil {
+			link.Err = fmt.Errorf("Finding the nameservers of %s failed: %s", zone, err)
+			return append(links, link)
+		}
+		if i > 0 {
+			link.Parent = links[i-1].Zone
+			rrs, err := s.zoneQuery(parentNS, zone, dns.TypeDS)
+			if err != nil {
+				link.Err = fmt.Errorf("DS query for %s at %s failed: %s", zone, link.Parent, err)
+				return append(links, link)
+			}
+			if len(extractRR(rrs, dns.TypeDS)) == 0 {
+				link.Insecure = true
+				link.Err = fmt.Errorf("No DS for %s at %s, the delegation is insecure", zone, link.Parent)
+				return append(links, link)
+			}
+			if err := s.verifySigned(rrs, parentKeys); err != nil {
+				link.Err = fmt.Errorf("DS of %s at %s does not validate: %s", zone, link.Parent, err)
+				return append(links, link)
+			}
+			trusted = trusted[:0]
+			for _, rr := range extractRR(rrs, dns.TypeDS) {
+				trusted = append(trusted, rr.(*dns.DS))
+			}
+		}
+
+		rrs, err := s.zoneQuery(nsdatas, zone, dns.TypeDNSKEY)
+		var keys []*dns.DNSKEY
+		for _, rr := range extractRR(rrs, dns.TypeDNSKEY) {
+			keys = append(keys, rr.(*dns.DNSKEY))
+		}
+		if len(keys) == 0 {
+			link.Err = fmt.Errorf("No DNSKEY for %s although it has a DS", zone)
+			if err != nil {
+				link.Err = fmt.Errorf("DNSKEY query for %s failed: %s", zone, err)
+			}
+			return append(links, link)
+		}
+		var sep []*dns.DNSKEY
+		var dsTags []string
+		for _, ds := range trusted {
+			dsTags = append(dsTags, fmt.Sprintf("%v/%s", ds.KeyTag, dns.AlgorithmToString[ds.Algorithm]))
+			for _, key := range keys {
+				if key.KeyTag() != ds.KeyTag || key.Algorithm != ds.Algorithm {
+					continue
+				}
+				if digest := key.ToDS(ds.DigestType); digest != nil && strings.EqualFold(digest.Digest, ds.Digest) {
+					sep = append(sep, key)
+					link.Keys = append(link.Keys, fmt.Sprintf("%v/%s", ds.KeyTag, dns.AlgorithmToString[ds.Algorithm]))
+				}
+			}
+		}
+		if len(sep) == 0 {
+			link.Err = fmt.Errorf("DS %s matches no DNSKEY of %s", strings.Join(dsTags, ", "), zone)
+			return append(links, link)
+		}
+		if err := s.verifySigned(rrs, sep); err != nil {
+			link.Err = fmt.Errorf("DNSKEY set of %s is not signed by the key the DS points at: %s", zone, err)
+			return append(links, link)
+		}
+		link.dnskeys = keys
+		links = append(links, link)
+		parentKeys, parentNS = keys, nsdatas
+	}
+
+	// the zone keys must also sign the zone data
+	last := &links[len(links)-1]
+	rrs, err := s.zoneQuery(parentNS, last.Zone, dns.TypeSOA)
+	if err == nil {
+		err = s.verifySigned(rrs, parentKeys)
+	}
+	if err != nil {
+		last.Err = fmt.Errorf("SOA of %s does not validate: %s", last.Zone, err)
+	}
+	return links
+}
+
+// validateChain reports whether the chain of trust of domain validates from
+// the root. The error names the broken link.
+func (s *session) validateChain(domain string) (bool, error) {
+	links := s.walkChain(domain)
+	last := links[len(links)-1]
+	if last.Err != nil {
+		s.log.Debugf("Chain of %s broken at %s: %s", domain, last, last.Err)
+		return false, last.Err
+	}
+	return true, nil
+}
+
+// validateRRset verifies rrs, records of zone with their signatures, with the
+// DNSKEY set the chain of trust proves for zone. The error is nil when they
+// validate. insecure is set when the zone is not signed or the signatures
+// don't verify, otherwise the chain could not be followed.
+func (s *session) validateRRset(zone string, rrs []dns.RR) (insecure bool, err error) {
+	links := s.walkChain(zone)
+	last := links[len(links)-1]
+	if last.Err != nil {
+		return last.Insecure, last.Err
+	}
+	if err := s.verifySigned(rrs, last.dnskeys); err != nil {
+		return true, err
+	}
+	return false, nil
+}
+
+// chainReport describes the chain of trust of domain.
+func chainReport(links []chainLink) ReportResult {
+	var path []string
+	for _, link := range links {
+		if link.Err == nil {
+			path = append(path, fmt.Sprintf("%s (%s)", link.Zone, strings.Join(link.Keys, " ")))
+		}
+	}
+	last := links[len(links)-1]
+	if last.Err == nil {
+		return ReportResult{Result: fmt.Sprintf("OK  : DNSKEY validated. Chain validated from the root: %s", strings.Join(path, " -> ")),
+			Status: true, Name: "Chain"}
+	}
+	res := ReportResult{Result: fmt.Sprintf("FAIL: Chain broken at %s: %s", last, last.Err), Status: false, Name: "Chain"}
+	if last.Insecure {
+		res.Result = fmt.Sprintf("FAIL: %s", last.Err)
+		res.Remediation = fmt.Sprintf("Sign %s and publish its DS record at %s through your registrar.", last.Zone, last.Parent)
+	} else {
+		res.Remediation = fmt.Sprintf("Fix the link between %s and %s: the DS at the parent must match a DNSKEY signing the DNSKEY set, and signatures must be current.", last.Parent, last.Zone)
+	}
+	if len(path) > 0 {
+		res.Result += fmt.Sprintf("\n\t   validated: %s", strings.Join(path, " -> "))
+	}
+	return res
+}
@@ -0,0 +1,154 @@
+package dt
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type Checker interface {
+	Scan(string)
+	CreateReport(string) Report
+}
+
+// Dependency describes the apex records a check needs to be meaningful.
+type Dependency struct {
+	Type     string
+	Requires []string
+}
+
+// Dependent is implemented by checkers that are skipped when the apex lacks
+// the records they depend on, instead of reporting cascading errors.
+type Dependent interface {
+	Dependency() Dependency
+}
+
+// checkOrder returns the order to run checkers in: the ApexCheck the others
+// depend on first, the rest in random order unless shuffling is turned off.
+func (s *session) checkOrder(checkers []Checker) []int {
+	var order, rest []int
+	for i, checker := range checkers {
+		if _, ok := checker.(*ApexCheck); ok {
+			order = append(order, i)
+		} else {
+			rest = append(rest, i)
+		}
+	}
+	if s.shuffle {
+		rand.Shuffle(len(rest), func(i, j int) { rest[i], rest[j] = rest[j], rest[i] })
+	}
+	return append(order, rest...)
+}
+
+// timed runs fn and adds its duration and number of queries to timings.
+func (s *session) timed(timings *[]Timing, name string, fn func()) {
+	start, queries := time.Now(), atomic.LoadInt64(&s.queryCount)
+	fn()
+	*timings = append(*timings, Timing{Check: name, Duration: time.Since(start), Queries: atomic.LoadInt64(&s.queryCount) - queries})
+}
+
+// runCheckers runs the checkers enabled in profile until the context of s is
+// done. Checkers depending on apex records found missing by an ApexCheck are
+// skipped. The reports are in the order of checkers, whatever order they ran
+// in, the timings in the order they ran.
+func (s *session) runCheckers(domain string, checkers []Checker, profile Profile) ([]Report, []Timing) {
+	done, timings := s.runEach(domain, checkers, profile)
+	return compact(done), timings
+}
+
+// runEach is runCheckers returning the report of every checker at its index,
+// nil for the checkers that didn't run.
+func (s *session) runEach(domain string, checkers []Checker, profile Profile) ([]*Report, []Timing) {
+	var timings []Timing
+	done := make([]*Report, len(checkers))
+	var apex *ApexCheck
+	for _, i := range s.checkOrder(checkers) {
+		checker := checkers[i]
+		if s.ctx.Err() != nil {
+			break
+		}
+		if !profile.Enabled(checker) {
+			continue
+		}
+		if d, ok := checker.(Dependent); ok && apex != nil {
+			dep := d.Dependency()
+			if missing := apex.Missing(dep.Requires); len(missing) > 0 {
+				done[i] = &Report{Type: dep.Type, Result: []ReportResult{{
+					Result: fmt.Sprintf("SKIP: No %s record at the apex", strings.Join(missing, "/")), Status: true, Name: "Skipped"}}}
+				continue
+			}
+		}
+		start, queries, raw := time.Now(), atomic.LoadInt64(&s.queryCount), s.rawMark()
+		report := isolate(s.createReport(checker, domain))
+		report.Raw = s.rawSince(raw)
+		report.Start, report.End = start, time.Now()
+		timings = append(timings, Timing{Check: report.Type, Duration: time.Since(start), Queries: atomic.LoadInt64(&s.queryCount) - queries})
+		done[i] = &report
+		if a, ok := checker.(*ApexCheck); ok {
+			apex = a
+		}
+	}
+	return done, timings
+}
+
+// compact returns the reports of the checkers that ran.
+func compact(done []*Report) []Report {
+	var reports []Report
+	for _, report := range done {
+		if report != nil {
+			reports = append(reports, *report)
+		}
+	}
+	return reports
+}
+
+// binder is implemented by the checkers through the Report they embed.
+type binder interface {
+	bind(s *session)
+}
+
+func (r *Report) bind(s *session) {
+	r.s = s
+}
+
+// createReport runs checker in s, turning a panic into an error result so
+// one broken check doesn't abort the whole run.
+func (s *session) createReport(checker Checker, domain string) (report Report) {
+	if b, ok := checker.(binder); ok {
+		b.bind(s)
+	}
+	defer func() {
+		report.s = nil
+		if r := recover(); r != nil {
+			report.Type = strings.TrimSuffix(strings.TrimPrefix(fmt.Sprintf("%T", checker), "*dt."), "Check")
+			report.Result = append(report.Result, ReportResult{Result: fmt.Sprintf("ERR : Check aborted: %v", r), Name: "Aborted"})
+		}
+	}()
+	return checker.CreateReport(domain)
+}
+
+// isolate moves the unreachable results of each nameserver into a single
+// result at the end of report, after the results of the servers that answered.
+func isolate(report Report) Report {
+	var results []ReportResult
+	var servers []string
+	failures := make(map[string][]string)
+	for _, res := range report.Result {
+		if !res.Unreachable {
+			results = append(results, res)
+			continue
+		}
+		if _, ok := failures[res.Server]; !ok {
+			servers = append(servers, res.Server)
+		}
+		failures[res.Server] = append(failures[res.Server], fmt.Sprintf("%s: %s", res.Name, res.Error))
+	}
+	for _, server := range servers {
+		results = append(results, ReportResult{Result: fmt.Sprintf("ERR : %s failed, left out of the checks above: %s", server, strings.Join(failures[server], "; ")),
+			Name: "Unreachable", Server: server, Error: strings.Join(failures[server], "; "), Unreachable: true})
+	}
+	report.Result = results
+	return report
+}
@@ -0,0 +1,115 @@
+package dt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/miekg/dns"
+)
+
+// resultSeverity orders the result prefixes from good to bad.
+var resultSeverity = map[string]int{"SKIP": 0, "OK": 1, "WARN": 2, "ERR": 3, "FAIL": 4}
+
+// resultStatus returns the status prefix (OK, WARN, FAIL or ERR) of a result.
+func resultStatus(res ReportResult) string {
+	if i := strings.Index(res.Result, ":"); i > 0 {
+		return strings.TrimSpace(res.Result[:i])
+	}
+	return ""
+}
+
+// checkDomain runs the default checks enabled in profile on domain.
+func (s *session) checkDomain(domain string, opts Options, profile Profile) ([]Report, error) {
+	nsdatas, err := s.findNS(dns.Fqdn(domain))
+	if err != nil {
+		return nil, err
+	}
+	reports, _ := s.runCheckers(domain, defaultCheckers(nsdatas, nil, opts), profile)
+	return reports, nil
+}
+
+// Compare checks all domains in parallel and prints a matrix with the worst
+// outcome of every check per domain.
+func Compare(domains []string, opts Options) error {
+	profile, err := LookupProfile(opts.Profile)
+	if err != nil {
+		return err
+	}
+	s, err := newSession(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	spin := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	spin.Suffix = fmt.Sprintf(" Checking %v domains...", len(domains))
+	if !s.debug {
+		spin.Start()
+	}
+	outcomes := make([]map[string]string, len(domains))
+	rows := []string{"Nameservers"}
+	seen := map[string]bool{"Nameservers": true}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, domain := range domains {
+		wg.Add(1)
+		go func(i int, domain string) {
+			defer wg.Done()
+			m := map[string]string{"Nameservers": "OK"}
+			reports, err := s.checkDomain(domain, opts, profile)
+			if err != nil {
+				m["Nameservers"] = "ERR"
+			}
+			var order []string
+			for _, report := range reports {
+				for _, res := range report.Result {
+					status := resultStatus(res)
+					if status == "" {
+						continue
+					}
+					row := report.Type
+					if res.Name != "" {
+						row += " " + res.Name
+					}
+					if _, ok := m[row]; !ok {
+						order = append(order, row)
+					}
+					if resultSeverity[status] > resultSeverity[m[row]] {
+						m[row] = status
+					}
+				}
+			}
+			mu.Lock()
+			outcomes[i] = m
+			for _, row := range order {
+				if !seen[row] {
+					seen[row] = true
+					rows = append(rows, row)
+				}
+			}
+			mu.Unlock()
+		}(i, domain)
+	}
+	wg.Wait()
+	spin.Stop()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+	fmt.Fprintf(w, "Check\t%s\n", strings.Join(domains, "\t"))
+	for _, row := range rows {
+		cells := []string{row}
+		for _, m := range outcomes {
+			status, ok := m[row]
+			if !ok {
+				status = "-"
+			}
+			cells = append(cells, status)
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	w.Flush()
+	return nil
+}
@@ -0,0 +1,229 @@
+package dt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/miekg/dns"
+)
+
+// confusables maps characters to the Latin character they are visually
+// confusable with. This is the subset of Unicode confusables.txt relevant for
+// lowercase (IDNA mapped) domain names.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'о': 'o', 'р': 'p', 'с': 'c', 'у': 'y', 'х': 'x', 'і': 'i', 'ј': 'j',
+	'ѕ': 's', 'һ': 'h', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w', 'ӏ': 'l', 'ѵ': 'v', 'ү': 'y', 'ԍ': 'g',
+	// Greek
+	'α': 'a', 'ο': 'o', 'ν': 'v', 'ρ': 'p', 'ι': 'i', 'κ': 'k', 'υ': 'u', 'χ': 'x', 'ϲ': 'c', 'ϳ': 'j', 'γ': 'y',
+	// Armenian
+	'օ': 'o', 'ս': 'u', 'հ': 'h', 'զ': 'q', 'ց': 'g', 'ո': 'n',
+	// Latin lookalikes
+	'ı': 'i', 'ɑ': 'a', 'ɡ': 'g', 'ɩ': 'i', 'ǀ': 'l', 'ƅ': 'b', 'ɢ': 'g', 'ʜ': 'h',
+	'ᴄ': 'c', 'ᴏ': 'o', 'ᴠ': 'v', 'ᴡ': 'w', 'ᴢ': 'z', 'ꞵ': 'b',
+}
+
+// punyDecode decodes a punycode label without the xn-- prefix (RFC 3492).
+func punyDecode(s string) (string, error) {
+	const base, tmin, tmax, skew, damp = 36, 1, 26, 38, 700
+	adapt := func(delta, points int, first bool) int {
+		if first {
+			delta /= damp
+		} else {
+			delta /= 2
+		}
+		delta += delta / points
+		k := 0
+		for delta > ((base-tmin)*tmax)/2 {
+			delta /= base - tmin
+			k += base
+		}
+		return k + (base-tmin+1)*delta/(delta+skew)
+	}
+	var output []rune
+	if pos := strings.LastIndex(s, "-"); pos >= 0 {
+		output = []rune(s[:pos])
+		s = s[pos+1:]
+	}
+	n, i, bias := 128, 0, 72
+	for len(s) > 0 {
+		oldi, w := i, 1
+		for k := base; ; k += base {
+			if len(s) == 0 {
+				return "", fmt.Errorf("invalid punycode")
+			}
+			c := s[0]
+			s = s[1:]
+			var digit int
+			switch {
+			case c >= '0' && c <= '9':
+				digit = int(c-'0') + 26
+			case c >= 'a' && c <= 'z':
+				digit = int(c - 'a')
+			case c >= 'A' && c <= 'Z':
+				digit = int(c - 'A')
+			default:
+				return "", fmt.Errorf("invalid punycode")
+			}
+			i += digit * w
+			t := k - bias
+			if t < tmin {
+				t = tmin
+			} else if t > tmax {
+				t = tmax
+			}
+			if digit < t {
+				break
+			}
+			w *= base - t
+			if w > unicode.MaxRune*base {
+				return "", fmt.Errorf("invalid punycode")
+			}
+		}
+		bias = adapt(i-oldi, len(output)+1, oldi == 0)
+		n += i / (len(output) + 1)
+		i %= len(output) + 1
+		if n > unicode.MaxRune {
+			return "", fmt.Errorf("invalid punycode")
+		}
+		output = append(output[:i], append([]rune{rune(n)}, output[i:]...)...)
+		i++
+	}
+	return string(output), nil
+}
+
+// unicodeLabel returns the Unicode form of an (A-label) domain name label.
+func unicodeLabel(label string) string {
+	if strings.HasPrefix(strings.ToLower(label), "xn--") {
+		if u, err := punyDecode(label[4:]); err == nil {
+			return u
+		}
+	}
+	return label
+}
+
+func scriptOf(r rune) string {
+	if unicode.Is(unicode.Common, r) || unicode.Is(unicode.Inherited, r) {
+		return ""
+	}
+	for name, table := range unicode.Scripts {
+		if unicode.Is(table, r) {
+			return name
+		}
+	}
+	return "Unknown"
+}
+
+// mixedScripts returns the scripts used in label when they are a combination
+// that isn't commonly used together (UTS 39 highly restrictive).
+func mixedScripts(label string) []string {
+	m := make(map[string]bool)
+	for _, r := range label {
+		if script := scriptOf(r); script != "" {
+			m[script] = true
+		}
+	}
+	var scripts []string
+	for script := range m {
+		scripts = append(scripts, script)
+	}
+	sort.Strings(scripts)
+	if len(scripts) < 2 {
+		return nil
+	}
+	allowed := []map[string]bool{
+		{"Latin": true, "Han": true, "Hiragana": true, "Katakana": true},
+		{"Latin": true, "Han": true, "Bopomofo": true},
+		{"Latin": true, "Han": true, "Hangul": true},
+	}
+	for _, set := range allowed {
+		ok := true
+		for _, script := range scripts {
+			if !set[script] {
+				ok = false
+			}
+		}
+		if ok {
+			return nil
+		}
+	}
+	return scripts
+}
+
+// skeleton maps confusable characters of label to their Latin lookalike.
+func skeleton(label string) string {
+	return strings.Map(func(r rune) rune {
+		if c, ok := confusables[r]; ok {
+			return c
+		}
+		return r
+	}, label)
+}
+
+// confusableProblems returns a description of every spoofing issue in name.
+func confusableProblems(name string) []string {
+	var problems []string
+	var labels, skel []string
+	spoof := false
+	for _, label := range dns.SplitDomainName(name) {
+		u := unicodeLabel(label)
+		labels = append(labels, u)
+		if scripts := mixedScripts(u); scripts != nil {
+			problems = append(problems, fmt.Sprintf("label %s (%s) mixes %s scripts", label, u, strings.Join(scripts, ", ")))
+		}
+		s := skeleton(u)
+		if s != u {
+			spoof = true
+		}
+		skel = append(skel, s)
+	}
+	if spoof {
+		problems = append(problems, fmt.Sprintf("%s looks like %s", strings.Join(labels, "."), strings.Join(skel, ".")))
+	}
+	return problems
+}
+
+type ConfusableCheck struct {
+	NS    []NSData
+	Names []string
+	Report
+}
+
+func (c *ConfusableCheck) Scan(domain string) {
+	m := map[string]bool{dns.Fqdn(domain): true}
+	for _, ns := range c.NS {
+		m[dns.Fqdn(ns.Name)] = true
+	}
+	mx, _, _ := c.s.queryRRset(domain, dns.TypeMX, c.s.resolver, false)
+	for _, rr := range mx {
+		m[dns.Fqdn(rr.(*dns.MX).Mx)] = true
+	}
+	for name := range m {
+		c.Names = append(c.Names, name)
+	}
+	sort.Strings(c.Names)
+}
+
+func (c *ConfusableCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	for _, name := range c.Names {
+		for _, problem := range confusableProblems(name) {
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: %s: %s", name, problem),
+				Status: false, Name: "Confusable", Remediation: "Register or block the confusable names, or monitor them for abuse."})
+		}
+	}
+	if len(results) == 0 {
+		results = append(results, ReportResult{Result: "OK  : No mixed-script or confusable names found",
+			Status: true, Name: "Confusable"})
+	}
+	return results
+}
+
+func (c *ConfusableCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "Confusables"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
@@ -0,0 +1,85 @@
+package dt
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var ctURL = "https://crt.sh/?output=json&q="
+
+type ctEntry struct {
+	NameValue string `json:"name_value"`
+}
+
+// ctHosts queries a Certificate Transparency log aggregator for certificates
+// issued under domain and returns the hostnames found in them.
+func (s *session) ctHosts(domain string) ([]string, error) {
+	if s.intranet {
+		return []string{}, errIntranet
+	}
+	domain = strings.ToLower(strings.TrimSuffix(dns.Fqdn(domain), "."))
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(ctURL + url.QueryEscape("%."+domain))
+	if err != nil {
+		return []string{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return []string{}, fmt.Errorf("CT lookup failed: %s", resp.Status)
+	}
+	var entries []ctEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return []string{}, err
+	}
+	m := make(map[string]bool)
+	for _, entry := range entries {
+		for _, name := range strings.Split(entry.NameValue, "\n") {
+			name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "*."))
+			if name == domain || !strings.HasSuffix(name, "."+domain) {
+				continue
+			}
+			m[name] = true
+		}
+	}
+	hosts := []string{}
+	for name := range m {
+		hosts = append(hosts, name)
+	}
+	sort.Strings(hosts)
+	s.log.Debugf("Found %v hostnames in CT logs for %s", len(hosts), domain)
+	return hosts, nil
+}
+
+// ctScanEntries returns the CT discovered hostnames as scan entries relative
+// to domain, skipping the ones already in DSP.
+func (s *session) ctScanEntries(domain string) []string {
+	hosts, err := s.ctHosts(domain)
+	if err != nil {
+		s.log.Debugf("CT lookup for %s failed: %s", domain, err)
+		return []string{}
+	}
+	known := make(map[string]bool)
+	for _, src := range DSP {
+		if src.Qtype == dns.TypeA {
+			for _, entry := range src.Entries {
+				known[entry] = true
+			}
+		}
+	}
+	suffix := strings.ToLower(strings.TrimSuffix(dns.Fqdn(domain), "."))
+	entries := []string{}
+	for _, host := range hosts {
+		entry := strings.TrimSuffix(host, suffix)
+		if !known[entry] {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
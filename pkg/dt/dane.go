@@ -0,0 +1,199 @@
+package dt
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DANECheck verifies the certificates of the MX hosts against their TLSA
+// records (RFC 7672).
+type DANECheck struct {
+	NS   []NSData
+	DANE []DANEData
+	Report
+}
+
+type DANEData struct {
+	MX   string
+	IP   string
+	TLSA []*dns.TLSA
+	// Secure is set when the TLSA records validate from the root trust
+	// anchor, Insecure when they can't: the zone is unsigned or the
+	// signatures don't verify. Neither is set when the chain of trust could
+	// not be followed, Validation tells why.
+	Secure     bool
+	Insecure   bool
+	Validation string
+	Certs      []*x509.Certificate
+	Error      string
+}
+
+// smtpCerts connects to ip on port 25, upgrades the session with STARTTLS
+// using host as SNI and returns the presented chain without verifying it.
+func smtpCerts(host string, ip net.IP) ([]*x509.Certificate, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip.String(), "25"), 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	if err := client.Hello("localhost"); err != nil {
+		return nil, err
+	}
+	if ok, _ := client.Extension("STARTTLS"); !ok {
+		return nil, fmt.Errorf("STARTTLS not offered")
+	}
+	if err := client.StartTLS(&tls.Config{ServerName: host, InsecureSkipVerify: true}); err != nil {
+		return nil, err
+	}
+	state, _ := client.TLSConnectionState()
+	client.Quit()
+	if len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("no certificate presented")
+	}
+	return state.PeerCertificates, nil
+}
+
+// tlsaMatch reports whether the chain matches the TLSA record. DANE-EE (3)
+// matches the leaf, DANE-TA (2) a certificate above it; the PKIX usages (0, 1)
+// also need the chain to validate.
+func tlsaMatch(tlsa *dns.TLSA, certs []*x509.Certificate) bool {
+	candidates := certs[:1]
+	if tlsa.Usage == 0 || tlsa.Usage == 2 {
+		candidates = certs[1:]
+	}
+	for _, cert := range candidates {
+		if tlsa.Verify(cert) != nil {
+			continue
+		}
+		if tlsa.Usage <= 1 {
+			return verifyChain(certs) == nil
+		}
+		return true
+	}
+	return false
+}
+
+func (c *DANECheck) Scan(domain string) {
+	mx, _, _ := c.s.queryRRset(dns.Fqdn(domain), dns.TypeMX, c.s.resolver, false)
+	seen := make(map[string]bool)
+	for _, rr := range mx {
+		host := strings.ToLower(dns.Fqdn(rr.(*dns.MX).Mx))
+		if seen[host] || host == "." {
+			continue
+		}
+		seen[host] = true
+		data := DANEData{MX: host}
+		res, err := c.s.query("_25._tcp."+host, dns.TypeTLSA, c.s.resolver, true)
+		if err == nil {
+			for _, rr := range extractRR(res.Msg.Answer, dns.TypeTLSA) {
+				data.TLSA = append(data.TLSA, rr.(*dns.TLSA))
+			}
+			if len(data.TLSA) > 0 {
+				// the AD bit is only as good as the resolver, validate ourselves
+				insecure, err := c.s.validateRRset(c.s.findZone(data.TLSA[0].Hdr.Name),
+					extractRR(res.Msg.Answer, dns.TypeTLSA, dns.TypeRRSIG))
+				data.Secure, data.Insecure = err == nil, insecure
+				if err != nil {
+					data.Validation = err.Error()
+				}
+			}
+		}
+		if len(data.TLSA) > 0 {
+			ips := c.s.resolveHost(host)
+			if len(ips) == 0 {
+				data.Error = "no A/AAAA records"
+			} else {
+				data.IP = ips[0].String()
+				if data.Certs, err = smtpCerts(strings.TrimSuffix(host, "."), ips[0]); err != nil {
+					data.Error = err.Error()
+				}
+			}
+		}
+		c.DANE = append(c.DANE, data)
+	}
+}
+
+func (c *DANECheck) Values() []ReportResult {
+	results := []ReportResult{}
+	var with, without []string
+	for _, d := range c.DANE {
+		if len(d.TLSA) > 0 {
+			with = append(with, d.MX)
+		} else {
+			without = append(without, d.MX)
+		}
+	}
+	if len(with) == 0 {
+		if len(c.DANE) > 0 {
+			results = append(results, ReportResult{Result: "SKIP: No TLSA records for your MX hosts, DANE is not in use",
+				Status: true, Name: "DANE"})
+		}
+		return results
+	}
+	if len(without) > 0 {
+		results = append(results, ReportResult{Result: fmt.Sprintf("WARN: MX %s have no TLSA records while %s have, DANE senders can be downgraded to them", strings.Join(without, ", "), strings.Join(with, ", ")),
+			Status: false, Name: "DANE", Remediation: "Publish TLSA records at _25._tcp for every MX host."})
+	}
+	for _, d := range c.DANE {
+		if len(d.TLSA) == 0 {
+			continue
+		}
+		var records []string
+		pkix := false
+		for _, tlsa := range d.TLSA {
+			records = append(records, tlsa.String())
+			pkix = pkix || tlsa.Usage <= 1
+		}
+		if pkix {
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: TLSA records of %s use PKIX usages (0 or 1), which SMTP senders don't support (RFC 7672 section 3.1.3)", d.MX),
+				Status: false, Name: "DANE", Records: records, Remediation: "Use DANE-EE (3 1 1) or DANE-TA (2 1 1) records."})
+		}
+		if !d.Secure && !d.Insecure {
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: TLSA records of %s could not be DNSSEC validated: %s", d.MX, d.Validation),
+				Status: false, Name: "DANE", Records: records, Error: d.Validation, Remediation: "Check the chain of trust of the zone of the MX host, senders only use validated TLSA records."})
+		}
+		switch {
+		case d.Insecure:
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: TLSA records of %s are not DNSSEC validated, senders ignore them: %s", d.MX, d.Validation),
+				Status: false, Name: "DANE", Records: records, Remediation: "Sign the zone of the MX host, DANE requires DNSSEC."})
+		case d.Error != "":
+			results = append(results, ReportResult{Result: fmt.Sprintf("ERR : STARTTLS to %s (%s) failed: %s", d.MX, d.IP, d.Error),
+				Status: false, Name: "DANE", Error: d.Error})
+		default:
+			var matched []string
+			for _, tlsa := range d.TLSA {
+				if tlsaMatch(tlsa, d.Certs) {
+					matched = append(matched, fmt.Sprintf("%v %v %v", tlsa.Usage, tlsa.Selector, tlsa.MatchingType))
+				}
+			}
+			if len(matched) > 0 {
+				results = append(results, ReportResult{Result: fmt.Sprintf("OK  : Certificate of %s (%s) matches TLSA %s", d.MX, d.IP, strings.Join(matched, ", ")),
+					Status: true, Name: "DANE"})
+			} else {
+				results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: Certificate of %s (%s) matches none of its %v TLSA records, DANE senders will not deliver", d.MX, d.IP, len(d.TLSA)),
+					Status: false, Name: "DANE", Records: records, Remediation: "Publish TLSA records for the new certificate before rolling it out, then remove the old ones."})
+			}
+		}
+	}
+	return results
+}
+
+func (c *DANECheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "DANE"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
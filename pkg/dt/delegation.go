@@ -0,0 +1,146 @@
+package dt
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// maxDelegationZones limits the number of zones followed when looking for
+// delegation dependencies.
+const maxDelegationZones = 20
+
+type DelegationCheck struct {
+	NS     []NSData
+	Deps   map[string][]string
+	Glued  map[string]bool
+	Cycles [][]string
+	Report
+}
+
+// zoneDeps returns the zones the out-of-bailiwick nameservers of zone live in
+// and whether the parent provides glue for any of its nameservers.
+func (s *session) zoneDeps(zone string) ([]string, bool) {
+	var deps []string
+	rrset, _, err := s.queryRRset(zone, dns.TypeNS, s.resolver, false)
+	if err != nil {
+		return deps, false
+	}
+	m := make(map[string]bool)
+	for _, rr := range rrset {
+		ns := strings.ToLower(rr.(*dns.NS).Ns)
+		if dns.IsSubDomain(zone, ns) {
+			continue
+		}
+		if z := s.findZone(ns); !m[z] {
+			m[z] = true
+			deps = append(deps, z)
+		}
+	}
+	glued := false
+	if referral, err := s.parentReferral(zone); err == nil {
+		glued = len(extractRR(referral.Extra, dns.TypeA, dns.TypeAAAA)) > 0
+	}
+	return deps, glued
+}
+
+func (c *DelegationCheck) walk(zone string, path []string) {
+	for i, z := range path {
+		if z == zone {
+			cycle := append(append([]string{}, path[i:]...), zone)
+			c.Cycles = append(c.Cycles, cycle)
+			return
+		}
+	}
+	if _, ok := c.Deps[zone]; !ok {
+		if len(c.Deps) >= maxDelegationZones {
+			return
+		}
+		c.Deps[zone], c.Glued[zone] = c.s.zoneDeps(zone)
+	}
+	for _, dep := range c.Deps[zone] {
+		c.walk(dep, append(path, zone))
+	}
+}
+
+func (c *DelegationCheck) Scan(domain string) {
+	c.Deps = make(map[string][]string)
+	c.Glued = make(map[string]bool)
+	c.walk(strings.ToLower(dns.Fqdn(domain)), nil)
+}
+
+func (c *DelegationCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	for _, cycle := range c.Cycles {
+		glued := false
+		for _, zone := range cycle {
+			glued = glued || c.Glued[zone]
+		}
+		if glued {
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: Circular delegation dependency, only resolvable via glue: %s", strings.Join(cycle, " -> ")),
+				Status: false, Name: "Circular", Remediation: "Keep the glue for these nameservers at the parent, or use nameservers outside the cycle."})
+		} else {
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: Circular delegation dependency without glue: %s", strings.Join(cycle, " -> ")),
+				Status: false, Name: "Circular", Remediation: "Add glue at the parent for the nameservers in the cycle, or use nameservers outside it."})
+		}
+	}
+	if len(results) == 0 {
+		results = append(results, ReportResult{Result: "OK  : No circular delegation dependencies found",
+			Status: true, Name: "Circular"})
+	}
+	return results
+}
+
+// CheckDependencies checks the health of the zones the nameservers depend on,
+// as the domain inherits their weaknesses.
+func (c *DelegationCheck) CheckDependencies(domain string) []ReportResult {
+	results := []ReportResult{}
+	self := strings.ToLower(dns.Fqdn(domain))
+	var zones []string
+	for zone := range c.Deps {
+		if zone != self {
+			zones = append(zones, zone)
+		}
+	}
+	sort.Strings(zones)
+	for _, zone := range zones {
+		nsdata, err := c.s.findNS(zone)
+		if err != nil {
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: Nameservers of %s, which your nameservers depend on, can't be resolved: %s", zone, err),
+				Status: false, Name: "Dependency", Remediation: "Use nameservers in zones that resolve, or fix the delegation of the zone they depend on."})
+			continue
+		}
+		var ips []net.IP
+		for _, ns := range nsdata {
+			if len(ns.IP) == 0 {
+				results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: Nameserver %s of %s, which your nameservers depend on, does not resolve", ns.Name, zone),
+					Status: false, Name: "Dependency", Remediation: "Use nameservers whose hostnames resolve, or fix the records of the zone they depend on."})
+			}
+			ips = append(ips, ns.IP...)
+		}
+		if len(nsdata) < 2 || isSameSubnet(ips...) {
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: %s, which your nameservers depend on, has no nameserver diversity (%v nameservers)", zone, len(nsdata)),
+				Status: false, Name: "Dependency", Remediation: "Use nameservers in zones with at least two nameservers, or ask their operator to add one."})
+		}
+		if _, err := c.s.validateChain(zone); err != nil {
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: %s, which your nameservers depend on, is not DNSSEC validated: %s", zone, err),
+				Status: false, Name: "Dependency", Remediation: "Use nameservers in a DNSSEC signed zone, or ask their operator to sign it."})
+		}
+	}
+	if len(results) == 0 {
+		results = append(results, ReportResult{Result: "OK  : Zones your nameservers depend on are healthy",
+			Status: true, Name: "Dependency"})
+	}
+	return results
+}
+
+func (c *DelegationCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "Delegation"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	c.Report.Result = append(c.Report.Result, c.CheckDependencies(domain)...)
+	return c.Report
+}
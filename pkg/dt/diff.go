@@ -0,0 +1,209 @@
+package dt
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// RunDiff is the difference between two saved runs (dt -json output).
+type RunDiff struct {
+	Domain string
+	From   string
+	To     string
+	// Checks that changed state, with "" for a check missing from a run.
+	Checks []CheckChange `json:",omitempty"`
+	// Records are the changed records shown by a check, and the nameservers.
+	Records []RecordChange `json:",omitempty"`
+	Rtts    []RttChange    `json:",omitempty"`
+}
+
+type CheckChange struct {
+	Check string
+	From  string
+	To    string
+}
+
+type RecordChange struct {
+	Check   string
+	Added   []string `json:",omitempty"`
+	Removed []string `json:",omitempty"`
+}
+
+type RttChange struct {
+	Nameserver string
+	From       time.Duration
+	To         time.Duration
+	Delta      time.Duration
+}
+
+// loadResult reads a run saved with -json.
+func loadResult(file string) (*Result, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var result Result
+	if err := json.NewDecoder(f).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%s is not a saved run (dt -json): %s", file, err)
+	}
+	return &result, nil
+}
+
+// runChecks returns the worst status and the records of every check of
+// result, keyed by report type and result name like the compare matrix, and
+// the keys in report order.
+func runChecks(result *Result) (map[string]string, map[string][]string, []string) {
+	status := make(map[string]string)
+	records := make(map[string][]string)
+	var order []string
+	for _, report := range result.Reports {
+		for _, res := range report.Result {
+			key := report.Type
+			if res.Name != "" {
+				key += " " + res.Name
+			}
+			s := resultStatus(res)
+			if _, ok := status[key]; !ok {
+				order = append(order, key)
+				status[key] = s
+			}
+			if resultSeverity[s] > resultSeverity[status[key]] {
+				status[key] = s
+			}
+			records[key] = append(records[key], res.Records...)
+		}
+	}
+	for _, ns := range result.Nameservers {
+		records["Nameservers"] = append(records["Nameservers"], fmt.Sprintf("%s %s", ns.Name, ns.IP))
+	}
+	return status, records, order
+}
+
+// setDiff returns the entries of b missing from a and those of a missing
+// from b, sorted.
+func setDiff(a, b []string) (added, removed []string) {
+	in := func(list []string) map[string]bool {
+		m := make(map[string]bool)
+		for _, s := range list {
+			m[s] = true
+		}
+		return m
+	}
+	ma, mb := in(a), in(b)
+	for s := range mb {
+		if !ma[s] {
+			added = append(added, s)
+		}
+	}
+	for s := range ma {
+		if !mb[s] {
+			removed = append(removed, s)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// diffRuns compares the run from with the later run to.
+func diffRuns(from, to *Result) RunDiff {
+	d := RunDiff{Domain: to.Domain}
+	fromStatus, fromRecords, fromOrder := runChecks(from)
+	toStatus, toRecords, toOrder := runChecks(to)
+	keys := toOrder
+	for _, key := range fromOrder {
+		if _, ok := toStatus[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		if fromStatus[key] != toStatus[key] {
+			d.Checks = append(d.Checks, CheckChange{Check: key, From: fromStatus[key], To: toStatus[key]})
+		}
+	}
+	for _, key := range append([]string{"Nameservers"}, keys...) {
+		added, removed := setDiff(fromRecords[key], toRecords[key])
+		if len(added) > 0 || len(removed) > 0 {
+			d.Records = append(d.Records, RecordChange{Check: key, Added: added, Removed: removed})
+		}
+	}
+	rtts := make(map[string]time.Duration)
+	for _, ns := range from.Nameservers {
+		rtts[fmt.Sprintf("%s (%s)", ns.Name, ns.IP)] = ns.Rtt
+	}
+	for _, ns := range to.Nameservers {
+		server := fmt.Sprintf("%s (%s)", ns.Name, ns.IP)
+		if rtt, ok := rtts[server]; ok {
+			d.Rtts = append(d.Rtts, RttChange{Nameserver: server, From: rtt, To: ns.Rtt, Delta: ns.Rtt - rtt})
+		}
+	}
+	return d
+}
+
+// Diff compares two runs saved with -json, printing the checks that changed
+// state, the changed records and the RTT of every nameserver in both runs.
+// Any two runs can be compared, they don't have to be of the same domain.
+func Diff(fromFile, toFile string, asJSON bool) error {
+	from, err := loadResult(fromFile)
+	if err != nil {
+		return err
+	}
+	to, err := loadResult(toFile)
+	if err != nil {
+		return err
+	}
+	d := diffRuns(from, to)
+	d.From, d.To = fromFile, toFile
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	}
+	if from.Domain != to.Domain {
+		fmt.Printf("Comparing %s (%s) with %s (%s)\n\n", from.Domain, fromFile, to.Domain, toFile)
+	}
+	missing := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+	if len(d.Checks) == 0 {
+		fmt.Println("No check changed state")
+	} else {
+		fmt.Fprintf(w, "Check\t%s\t%s\n", fromFile, toFile)
+		for _, c := range d.Checks {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", c.Check, missing(c.From), missing(c.To))
+		}
+		w.Flush()
+	}
+	for _, r := range d.Records {
+		fmt.Printf("\n%s:\n", r.Check)
+		for _, s := range r.Removed {
+			fmt.Printf("- %s\n", strings.TrimSpace(s))
+		}
+		for _, s := range r.Added {
+			fmt.Printf("+ %s\n", strings.TrimSpace(s))
+		}
+	}
+	if len(d.Rtts) > 0 {
+		fmt.Println()
+		fmt.Fprintf(w, "Nameserver\t%s\t%s\tDelta\n", fromFile, toFile)
+		for _, r := range d.Rtts {
+			sign := "+"
+			if r.Delta < 0 {
+				sign = ""
+			}
+			fmt.Fprintf(w, "%s\t%v\t%v\t%s%v\n", r.Nameserver, r.From, r.To, sign, r.Delta)
+		}
+		w.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package dt
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSetDiff(t *testing.T) {
+	tests := []struct {
+		a, b           []string
+		added, removed []string
+	}{
+		{nil, nil, nil, nil},
+		{[]string{"x"}, []string{"x"}, nil, nil},
+		{nil, []string{"b", "a"}, []string{"a", "b"}, nil},
+		{[]string{"b", "a"}, nil, nil, []string{"a", "b"}},
+		{[]string{"a", "b", "b"}, []string{"b", "c"}, []string{"c"}, []string{"a"}},
+	}
+	for _, tt := range tests {
+		added, removed := setDiff(tt.a, tt.b)
+		if !reflect.DeepEqual(added, tt.added) || !reflect.DeepEqual(removed, tt.removed) {
+			t.Errorf("setDiff(%q, %q) = %q, %q, want %q, %q", tt.a, tt.b, added, removed, tt.added, tt.removed)
+		}
+	}
+}
+
+func TestDiffRuns(t *testing.T) {
+	ns := func(name, ip string, rtt time.Duration) NSInfo {
+		return NSInfo{Name: name, Rtt: rtt, IPInfo: IPInfo{IP: net.ParseIP(ip)}}
+	}
+	mx := func(result string, records ...string) Report {
+		return Report{Type: "MX", Result: []ReportResult{{Result: result, Name: "Multiple", Records: records}}}
+	}
+	base := &Result{Domain: "example.com",
+		Nameservers: []NSInfo{ns("ns1.example.com.", "192.0.2.1", 10*time.Millisecond)},
+		Reports: []Report{
+			mx("OK  : Multiple MX records", "10 mx1.example.com.", "20 mx2.example.com."),
+			{Type: "SPF", Result: []ReportResult{{Result: "OK  : SPF record valid"}, {Result: "WARN: 9 DNS lookups"}}},
+		}}
+	tests := []struct {
+		name string
+		to   *Result
+		want RunDiff
+	}{
+		{"same", base, RunDiff{Domain: "example.com",
+			Rtts: []RttChange{{Nameserver: "ns1.example.com. (192.0.2.1)", From: 10 * time.Millisecond, To: 10 * time.Millisecond}}}},
+		{"changed", &Result{Domain: "example.com",
+			Nameservers: []NSInfo{ns("ns1.example.com.", "192.0.2.1", 25*time.Millisecond), ns("ns2.example.com.", "192.0.2.2", time.Millisecond)},
+			Reports: []Report{
+				mx("FAIL: Single MX record", "10 mx1.example.com."),
+				{Type: "DANE", Result: []ReportResult{{Result: "SKIP: No TLSA records"}}},
+			}},
+			RunDiff{Domain: "example.com",
+				Checks: []CheckChange{
+					{Check: "MX Multiple", From: "OK", To: "FAIL"},
+					{Check: "DANE", From: "", To: "SKIP"},
+					// the worst result of a check is its state
+					{Check: "SPF", From: "WARN", To: ""},
+				},
+				Records: []RecordChange{
+					{Check: "Nameservers", Added: []string{"ns2.example.com. 192.0.2.2"}},
+					{Check: "MX Multiple", Removed: []string{"20 mx2.example.com."}},
+				},
+				Rtts: []RttChange{{Nameserver: "ns1.example.com. (192.0.2.1)", From: 10 * time.Millisecond, To: 25 * time.Millisecond, Delta: 15 * time.Millisecond}},
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffRuns(base, tt.to); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package dt
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// dkimSelectors are common DKIM selectors of mail providers and signing
+// software, probed when looking for the keys of a domain.
+var dkimSelectors = []string{
+	"default", "dkim", "mail", "email", "smtp", "k1", "k2", "k3", "s1", "s2", "s1024", "s2048",
+	"selector1", "selector2", "google", "mxvault", "everlytickey1", "everlytickey2",
+	"mandrill", "mailjet", "zoho", "fm1", "fm2", "fm3", "protonmail", "protonmail2", "protonmail3",
+	"key1", "key2", "sig1", "dk", "mta", "amazonses", "sendgrid", "smtpapi", "pm", "mailo",
+}
+
+// DKIMCheck looks for DKIM keys under common selectors and the selectors
+// given, and validates the key records found.
+type DKIMCheck struct {
+	NS        []NSData
+	Selectors []string
+	// Wildcard is set when a random selector resolves, so the common
+	// selectors can't be told apart from missing ones.
+	Wildcard bool
+	Keys     []DKIMKey
+	Report
+}
+
+// DKIMKey is the key record published under a selector.
+type DKIMKey struct {
+	Selector string
+	Record   string
+}
+
+// dkimKey parses a DKIM key record (RFC 6376 section 3.6.1). It returns the
+// key type and size in bits, 0 bits for a revoked key with an empty p= tag.
+func dkimKey(record string) (tags map[string]string, ktype string, bits int, err error) {
+	tags = make(map[string]string)
+	for i, part := range strings.Split(record, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		if len(kv) != 2 {
+			return nil, "", 0, fmt.Errorf("%q is not a tag=value pair", strings.TrimSpace(part))
+		}
+		tag := strings.TrimSpace(kv[0])
+		if tag == "v" && i != 0 {
+			return nil, "", 0, fmt.Errorf("v= must be the first tag")
+		}
+		tags[tag] = strings.Join(strings.Fields(kv[1]), "")
+	}
+	if v, ok := tags["v"]; ok && v != "DKIM1" {
+		return nil, "", 0, fmt.Errorf("unknown version v=%s", v)
+	}
+	p, ok := tags["p"]
+	if !ok {
+		return nil, "", 0, fmt.Errorf("p= tag missing")
+	}
+	ktype = "rsa"
+	if k, ok := tags["k"]; ok {
+		ktype = k
+	}
+	if p == "" {
+		return tags, ktype, 0, nil
+	}
+	der, err := base64.StdEncoding.DecodeString(p)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("p= is not valid base64")
+	}
+	switch ktype {
+	case "rsa":
+		key, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			// some signers publish the bare PKCS #1 key
+			if pkcs1, err1 := x509.ParsePKCS1PublicKey(der); err1 == nil {
+				key, err = pkcs1, nil
+			}
+		}
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("p= is not an RSA public key: %s", err)
+		}
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, "", 0, fmt.Errorf("p= is not an RSA public key")
+		}
+		return tags, ktype, rsaKey.N.BitLen(), nil
+	case "ed25519":
+		if len(der) != 32 {
+			return nil, "", 0, fmt.Errorf("ed25519 key is %v bytes instead of 32", len(der))
+		}
+		return tags, ktype, 256, nil
+	}
+	return nil, "", 0, fmt.Errorf("unknown key type k=%s", ktype)
+}
+
+// dkimLookup returns the DKIM key records under selector.
+func (s *session) dkimLookup(domain, selector string) []string {
+	txt, _, _ := s.queryRRset(selector+"._domainkey."+dns.Fqdn(domain), dns.TypeTXT, s.resolver, false)
+	var records []string
+	for _, rr := range txt {
+		records = append(records, txtString(rr.(*dns.TXT)))
+	}
+	return records
+}
+
+func (c *DKIMCheck) Scan(domain string) {
+	selectors := c.Selectors
+	if random := c.s.dkimLookup(domain, fmt.Sprintf("dt%v", dns.Id())); len(random) > 0 {
+		c.Wildcard = true
+	} else {
+		selectors = append(selectors, dkimSelectors...)
+	}
+	found := make([][]string, len(selectors))
+	sem := make(chan struct{}, c.s.concurrency)
+	var wg sync.WaitGroup
+	for i, selector := range selectors {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, selector string) {
+			defer func() { <-sem; wg.Done() }()
+			found[i] = c.s.dkimLookup(domain, selector)
+		}(i, selector)
+	}
+	wg.Wait()
+	seen := make(map[string]bool)
+	for i, records := range found {
+		if seen[selectors[i]] {
+			continue
+		}
+		seen[selectors[i]] = true
+		for _, record := range records {
+			c.Keys = append(c.Keys, DKIMKey{Selector: selectors[i], Record: record})
+		}
+	}
+}
+
+func (c *DKIMCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	if c.Wildcard {
+		results = append(results, ReportResult{Result: "SKIP: A wildcard makes every DKIM selector resolve, only the selectors given are checked (use -dkim-selector)",
+			Status: true, Name: "DKIMSelectors"})
+	}
+	if len(c.Keys) == 0 {
+		if !c.Wildcard {
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: No DKIM key found under %v common selectors. Your mail may be unsigned or use another selector (use -dkim-selector).", len(dkimSelectors)+len(c.Selectors)),
+				Status: false, Name: "DKIMSelectors", Remediation: "Sign outgoing mail with DKIM and publish the key under <selector>._domainkey."})
+		}
+		return results
+	}
+	for _, key := range c.Keys {
+		tags, ktype, bits, err := dkimKey(key.Record)
+		switch {
+		case err != nil:
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: DKIM key of selector %s is invalid: %s", key.Selector, err),
+				Status: false, Name: "DKIMKey", Remediation: "Publish the key record exactly as generated by the signer, see RFC 6376 section 3.6.1."})
+			continue
+		case bits == 0:
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: DKIM key of selector %s is revoked (empty p=)", key.Selector),
+				Status: false, Name: "DKIMKey", Remediation: "Remove the record once no mail signed with the old key is in transit."})
+			continue
+		case ktype == "rsa" && bits < 1024:
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: DKIM key of selector %s is RSA %v bits, verifiers ignore keys below 1024 bits (RFC 8301)", key.Selector, bits),
+				Status: false, Name: "DKIMKey", Remediation: "Rotate to a 2048 bit RSA key."})
+		case ktype == "rsa" && bits < 2048:
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: DKIM key of selector %s is RSA %v bits, 2048 bits are recommended (RFC 8301)", key.Selector, bits),
+				Status: false, Name: "DKIMKey", Remediation: "Rotate to a 2048 bit RSA key."})
+		default:
+			results = append(results, ReportResult{Result: fmt.Sprintf("OK  : DKIM key of selector %s is %s %v bits", key.Selector, ktype, bits),
+				Status: true, Name: "DKIMKey"})
+		}
+		for _, flag := range strings.Split(tags["t"], ":") {
+			if flag == "y" {
+				results = append(results, ReportResult{Result: fmt.Sprintf("WARN: DKIM key of selector %s is in test mode (t=y), verifiers treat failures as unsigned", key.Selector),
+					Status: false, Name: "DKIMKey", Remediation: "Drop t=y once signing works."})
+			}
+		}
+	}
+	return results
+}
+
+func (c *DKIMCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "DKIM"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
@@ -0,0 +1,167 @@
+package dt
+
+import (
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// maxDMARCDestinations is the number of report destinations per tag checked
+// for authorization.
+const maxDMARCDestinations = 5
+
+// dmarcURIs returns the mailbox domains of a rua or ruf tag, a comma
+// separated list of mailto: URIs with an optional !size suffix.
+func dmarcURIs(value string) ([]string, error) {
+	var domains []string
+	for _, uri := range strings.Split(value, ",") {
+		uri = strings.TrimSpace(uri)
+		if i := strings.LastIndex(uri, "!"); i >= 0 {
+			uri = uri[:i]
+		}
+		if !strings.HasPrefix(strings.ToLower(uri), "mailto:") {
+			return nil, fmt.Errorf("%q is not a mailto: URI", uri)
+		}
+		addr, err := mail.ParseAddress(uri[len("mailto:"):])
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid address", uri)
+		}
+		domains = append(domains, dns.Fqdn(strings.ToLower(addr.Address[strings.LastIndex(addr.Address, "@")+1:])))
+	}
+	return domains, nil
+}
+
+// parseDMARC parses and validates a DMARC record (RFC 7489 section 6.3).
+func parseDMARC(record string) (map[string]string, error) {
+	parts := strings.Split(record, ";")
+	if kv := strings.SplitN(parts[0], "=", 2); len(kv) != 2 || strings.TrimSpace(kv[0]) != "v" || strings.TrimSpace(kv[1]) != "DMARC1" {
+		return nil, fmt.Errorf("does not start with v=DMARC1")
+	}
+	tags := dmarcTags(record)
+	for _, part := range parts[1:] {
+		if strings.TrimSpace(part) != "" && !strings.Contains(part, "=") {
+			return nil, fmt.Errorf("%q is not a tag=value pair", strings.TrimSpace(part))
+		}
+	}
+	policies := map[string]bool{"none": true, "quarantine": true, "reject": true}
+	if p, ok := tags["p"]; !ok {
+		return nil, fmt.Errorf("p= tag missing")
+	} else if !policies[p] {
+		return nil, fmt.Errorf("invalid policy p=%s", p)
+	}
+	if sp, ok := tags["sp"]; ok && !policies[sp] {
+		return nil, fmt.Errorf("invalid subdomain policy sp=%s", sp)
+	}
+	if pct, ok := tags["pct"]; ok {
+		if n, err := strconv.Atoi(pct); err != nil || n < 0 || n > 100 {
+			return nil, fmt.Errorf("pct=%s is not a percentage", pct)
+		}
+	}
+	for _, tag := range []string{"adkim", "aspf"} {
+		if v, ok := tags[tag]; ok && v != "r" && v != "s" {
+			return nil, fmt.Errorf("%s=%s is not r or s", tag, v)
+		}
+	}
+	for _, tag := range []string{"rua", "ruf"} {
+		if v, ok := tags[tag]; ok {
+			if _, err := dmarcURIs(v); err != nil {
+				return nil, fmt.Errorf("%s: %s", tag, err)
+			}
+		}
+	}
+	return tags, nil
+}
+
+// CheckDMARC validates the DMARC record found by the scan and checks that
+// report destinations outside the domain authorize receiving its reports
+// (RFC 7489 section 7.1).
+func (c *SpamCheck) CheckDMARC(domain string) []ReportResult {
+	results := []ReportResult{}
+	var rrset []dns.RR
+	for _, ns := range c.Spam {
+		if ns.Dmarc != nil {
+			rrset = ns.Dmarc
+			break
+		}
+	}
+	if len(rrset) == 0 {
+		rrset = c.OrgDmarc
+	}
+	var records []string
+	for _, rr := range extractRR(rrset, dns.TypeTXT) {
+		if s := txtString(rr.(*dns.TXT)); strings.HasPrefix(s, "v=DMARC1") {
+			records = append(records, s)
+		}
+	}
+	switch {
+	case len(records) == 0:
+		if len(rrset) > 0 {
+			results = append(results, ReportResult{Result: "FAIL: DMARC record does not start with v=DMARC1 and is ignored",
+				Status: false, Name: "DMARCSyntax", Remediation: "Start the record with v=DMARC1; p=..."})
+		}
+		return results
+	case len(records) > 1:
+		return append(results, ReportResult{Result: fmt.Sprintf("FAIL: %v DMARC records found, receivers ignore them all", len(records)),
+			Status: false, Name: "DMARCSyntax", Remediation: "Keep a single DMARC record."})
+	}
+	tags, err := parseDMARC(records[0])
+	if err != nil {
+		return append(results, ReportResult{Result: fmt.Sprintf("FAIL: DMARC record is invalid: %s", err),
+			Status: false, Name: "DMARCSyntax", Remediation: "Fix the record, see RFC 7489 section 6.3 for the tags."})
+	}
+	if pct, ok := tags["pct"]; ok && pct != "100" && tags["p"] != "none" {
+		results = append(results, ReportResult{Result: fmt.Sprintf("WARN: DMARC policy only applies to %s%% of failing mail", pct),
+			Status: false, Name: "DMARCPolicy", Remediation: "Raise pct to 100 once reports show legitimate mail passes."})
+	}
+	if tags["sp"] == "none" && tags["p"] != "none" {
+		results = append(results, ReportResult{Result: "WARN: DMARC subdomain policy sp=none leaves subdomains unprotected",
+			Status: false, Name: "DMARCPolicy", Remediation: "Drop sp= or set it to the policy of the domain."})
+	}
+	if tags["rua"] == "" {
+		results = append(results, ReportResult{Result: "WARN: DMARC record has no rua= destination, you receive no aggregate reports",
+			Status: false, Name: "DMARCReports", Remediation: "Add rua=mailto:dmarc@<domain> to see who sends mail as you."})
+	}
+
+	owner := strings.TrimPrefix(strings.ToLower(rrset[0].Header().Name), "_dmarc.")
+	for _, tag := range []string{"rua", "ruf"} {
+		// a missing tag is no mailto: URI, so yields no destinations
+		dests, _ := dmarcURIs(tags[tag])
+		// receivers only have to send to two (RFC 7489 section 6.2)
+		if len(dests) > maxDMARCDestinations {
+			dests = dests[:maxDMARCDestinations]
+		}
+		for _, dest := range dests {
+			if c.s.orgDomain(dest) == c.s.orgDomain(owner) {
+				continue
+			}
+			auth := owner + "_report._dmarc." + dest
+			if c.s.offline {
+				results = append(results, ReportResult{Result: fmt.Sprintf("SKIP: DMARC %s destination %s not checked for %s offline", tag, dest, auth),
+					Status: true, Name: "DMARCReports"})
+				continue
+			}
+			txt, _, err := c.s.queryRRset(auth, dns.TypeTXT, c.s.resolver, false)
+			authorized := false
+			for _, rr := range extractRR(txt, dns.TypeTXT) {
+				if strings.HasPrefix(txtString(rr.(*dns.TXT)), "v=DMARC1") {
+					authorized = true
+				}
+			}
+			switch {
+			case authorized:
+				results = append(results, ReportResult{Result: fmt.Sprintf("OK  : DMARC %s destination %s accepts reports for %s", tag, dest, owner),
+					Status: true, Name: "DMARCReports"})
+			case err != nil && !strings.Contains(err.Error(), "NXDOMAIN") && !strings.Contains(err.Error(), "no rr for"):
+				results = append(results, ReportResult{Result: fmt.Sprintf("ERR : Checking %s failed: %s", auth, err),
+					Status: false, Name: "DMARCReports"})
+			default:
+				results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: DMARC %s destination %s does not authorize reports for %s, receivers will not send them", tag, dest, owner),
+					Status: false, Name: "DMARCReports", Remediation: fmt.Sprintf("Ask the operator of %s to publish TXT \"v=DMARC1\" at %s.", dest, auth)})
+			}
+		}
+	}
+	return results
+}
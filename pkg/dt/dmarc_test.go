@@ -0,0 +1,26 @@
+package dt
+
+import "testing"
+
+func FuzzParseDMARC(f *testing.F) {
+	for _, seed := range []string{
+		"v=DMARC1; p=reject",
+		"v=DMARC1; p=none; sp=quarantine; pct=50; adkim=s; aspf=r; rua=mailto:dmarc@example.com,mailto:x@example.net!10m",
+		"v=DMARC1; p=quarantine; ruf=mailto:forensic@example.com; fo=1",
+		"v=DMARC1; p=bogus; pct=101",
+		"v=DMARC1;;p=reject;x",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, record string) {
+		tags, err := parseDMARC(record)
+		if err != nil {
+			return
+		}
+		switch tags["p"] {
+		case "none", "quarantine", "reject":
+		default:
+			t.Fatalf("%q: accepted policy %q", record, tags["p"])
+		}
+	})
+}
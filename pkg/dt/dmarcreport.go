@@ -0,0 +1,170 @@
+package dt
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/miekg/dns"
+)
+
+// DMARCFeedback is a DMARC aggregate report (RFC 7489 appendix C).
+type DMARCFeedback struct {
+	Metadata struct {
+		OrgName  string `xml:"org_name"`
+		ReportID string `xml:"report_id"`
+		Begin    int64  `xml:"date_range>begin"`
+		End      int64  `xml:"date_range>end"`
+	} `xml:"report_metadata"`
+	Policy struct {
+		Domain string `xml:"domain"`
+		ADKIM  string `xml:"adkim"`
+		ASPF   string `xml:"aspf"`
+		P      string `xml:"p"`
+		SP     string `xml:"sp"`
+		Pct    int    `xml:"pct"`
+	} `xml:"policy_published"`
+	Records []struct {
+		SourceIP    string `xml:"row>source_ip"`
+		Count       int    `xml:"row>count"`
+		Disposition string `xml:"row>policy_evaluated>disposition"`
+		DKIM        string `xml:"row>policy_evaluated>dkim"`
+		SPF         string `xml:"row>policy_evaluated>spf"`
+		HeaderFrom  string `xml:"identifiers>header_from"`
+		AuthDKIM    []struct {
+			Domain   string `xml:"domain"`
+			Selector string `xml:"selector"`
+			Result   string `xml:"result"`
+		} `xml:"auth_results>dkim"`
+	} `xml:"record"`
+}
+
+type dmarcSource struct {
+	IP     string
+	Count  int
+	DKIM   int
+	SPF    int
+	Pass   int
+	Reject int
+}
+
+func parseDMARCReport(file string) (*DMARCFeedback, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(file, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	feedback := &DMARCFeedback{}
+	if err := xml.NewDecoder(r).Decode(feedback); err != nil {
+		return nil, err
+	}
+	return feedback, nil
+}
+
+// DMARCReport prints a summary of a DMARC aggregate report.
+func DMARCReport(file string, opts Options) error {
+	s, err := newSession(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	feedback, err := parseDMARCReport(file)
+	if err != nil {
+		return err
+	}
+	domain := dns.Fqdn(feedback.Policy.Domain)
+	fmt.Printf("Report %s from %s for %s (p=%s, adkim=%s, aspf=%s)\n\n", feedback.Metadata.ReportID, feedback.Metadata.OrgName,
+		domain, feedback.Policy.P, feedback.Policy.ADKIM, feedback.Policy.ASPF)
+
+	m := make(map[string]*dmarcSource)
+	selectors := make(map[string]bool)
+	total, pass := 0, 0
+	for _, rec := range feedback.Records {
+		src, ok := m[rec.SourceIP]
+		if !ok {
+			src = &dmarcSource{IP: rec.SourceIP}
+			m[rec.SourceIP] = src
+		}
+		src.Count += rec.Count
+		total += rec.Count
+		if rec.DKIM == "pass" {
+			src.DKIM += rec.Count
+		}
+		if rec.SPF == "pass" {
+			src.SPF += rec.Count
+		}
+		if rec.DKIM == "pass" || rec.SPF == "pass" {
+			src.Pass += rec.Count
+			pass += rec.Count
+		}
+		if rec.Disposition == "reject" || rec.Disposition == "quarantine" {
+			src.Reject += rec.Count
+		}
+		for _, auth := range rec.AuthDKIM {
+			if auth.Selector != "" && dns.Fqdn(auth.Domain) == domain {
+				selectors[auth.Selector] = true
+			}
+		}
+	}
+	var sources []*dmarcSource
+	for _, src := range m {
+		sources = append(sources, src)
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Count > sources[j].Count })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+	fmt.Fprintf(w, "Source\tPTR\tMessages\tDKIM aligned\tSPF aligned\tDMARC pass\tQuarantined/rejected\n")
+	for _, src := range sources {
+		ptr := ""
+		if rev, err := dns.ReverseAddr(src.IP); err == nil {
+			if rrset, _, err := s.queryRRset(rev, dns.TypePTR, s.resolver, false); err == nil {
+				ptr = rrset[0].(*dns.PTR).Ptr
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%v\t%v\t%v\t%v\t%v\n", src.IP, ptr, src.Count, src.DKIM, src.SPF, src.Pass, src.Reject)
+	}
+	w.Flush()
+	if total > 0 {
+		fmt.Printf("\n%v of %v messages (%v%%) passed DMARC\n", pass, total, pass*100/total)
+	}
+
+	fmt.Println("\nDNS")
+	txt, _, _ := s.queryRRset(domain, dns.TypeTXT, s.resolver, false)
+	spf := false
+	for _, rr := range txt {
+		if strings.Contains(rr.String(), "v=spf") {
+			spf = true
+			fmt.Println("\t OK  : SPF record published:", txtString(rr.(*dns.TXT)))
+		}
+	}
+	if !spf {
+		fmt.Println("\t WARN: No SPF record published for", domain)
+	}
+	var names []string
+	for selector := range selectors {
+		names = append(names, selector)
+	}
+	sort.Strings(names)
+	for _, selector := range names {
+		if _, _, err := s.queryRRset(selector+"._domainkey."+domain, dns.TypeTXT, s.resolver, false); err == nil {
+			fmt.Printf("\t OK  : DKIM selector %s seen in the report is published\n", selector)
+		} else {
+			fmt.Printf("\t WARN: DKIM selector %s seen in the report is not published\n", selector)
+		}
+	}
+	return nil
+}
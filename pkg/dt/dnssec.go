@@ -0,0 +1,62 @@
+package dt
+
+import (
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func (s *session) validateDNSKEY(keys []dns.RR) (bool, KeyInfo, error) {
+	return s.validateRRSIG(keys, keys)
+}
+
+func (s *session) validateRRSIG(keys []dns.RR, rrset []dns.RR) (bool, KeyInfo, error) {
+	if len(rrset) == 0 {
+		return false, KeyInfo{}, nil
+	}
+	var sig *dns.RRSIG
+	var cleanset []dns.RR
+	for _, v := range rrset {
+		_, ok := v.(*dns.RRSIG)
+		if ok {
+			sig = v.(*dns.RRSIG)
+		} else {
+			cleanset = append(cleanset, v)
+		}
+	}
+	for _, k := range keys {
+		if _, ok := k.(*dns.DNSKEY); !ok {
+			//fmt.Println("not ok, skipping")
+			continue
+		}
+		key := k.(*dns.DNSKEY)
+		s.log.Debugf("Trying validation RRSIG with DNSKEY %s (flag %v, keytag %v)", key.PublicKey, key.Flags, key.KeyTag())
+		err := sig.Verify(key, cleanset)
+		if err == nil {
+			ti, te := explicitValid(sig)
+			if sig.ValidityPeriod(time.Now()) {
+				s.log.Debugf("Validation succeeded")
+				return true, KeyInfo{ti, te}, nil
+			}
+			//	return false, KeyInfo{ti, te}, nil
+		}
+		s.log.Debugf("Validation failed")
+	}
+	return false, KeyInfo{}, nil
+}
+
+func explicitValid(rr *dns.RRSIG) (int64, int64) {
+	t := time.Now()
+	var utc int64
+	var year68 = int64(1 << 31)
+	if t.IsZero() {
+		utc = time.Now().UTC().Unix()
+	} else {
+		utc = t.UTC().Unix()
+	}
+	modi := (int64(rr.Inception) - utc) / year68
+	mode := (int64(rr.Expiration) - utc) / year68
+	ti := int64(rr.Inception) + (modi * year68)
+	te := int64(rr.Expiration) + (mode * year68)
+	return ti, te
+}
@@ -0,0 +1,121 @@
+package dt
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// dotPort is the DNS over TLS port (RFC 7858).
+const dotPort = "853"
+
+// setResolver sets the resolver from list, comma separated host[:port] or
+// tls://host[:port], or without one to the first resolver of the system.
+// The resolvers after the first are the fallbacks. DNS over TLS is used for
+// all of them with the tls:// form or when dot is set, local resolvers
+// rarely offer it so fallbackResolver is the default then.
+func (s *session) setResolver(list string, dot bool, sni string, pins []string) error {
+	if list == "" {
+		list = fallbackResolver
+		if servers, err := systemResolvers(); err == nil && len(servers) > 0 && !dot {
+			list = servers[0]
+		}
+		s.log.Debugf("no resolver given, using %s", list)
+	}
+	var hosts, ports []string
+	for _, r := range strings.Split(list, ",") {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		if strings.HasPrefix(r, "tls://") {
+			r, dot = strings.TrimPrefix(r, "tls://"), true
+		}
+		host, port, err := net.SplitHostPort(r)
+		if err != nil {
+			host, port = strings.Trim(r, "[]"), ""
+		}
+		hosts, ports = append(hosts, host), append(ports, port)
+	}
+	if len(hosts) == 0 {
+		return fmt.Errorf("no resolver in %q", list)
+	}
+	var servers []string
+	for i, host := range hosts {
+		if ports[i] == "" && dot {
+			ports[i] = dotPort
+		}
+		servers = append(servers, hostPort(host, ports[i]))
+	}
+	s.resolver, s.resolverFallbacks = servers[0], servers[1:]
+	s.dotConfig = nil
+	if !dot {
+		return nil
+	}
+	config, err := newDoTConfig(sni, pins)
+	if err != nil {
+		return err
+	}
+	s.dotConfig = config
+	return nil
+}
+
+// pickResolver makes the first of the resolvers that answers the resolver,
+// so one that is down doesn't cost a timeout on every query.
+func (s *session) pickResolver() {
+	if len(s.resolverFallbacks) == 0 || s.offline {
+		return
+	}
+	res, _ := s.query(".", dns.TypeNS, s.resolver, false)
+	if res.Msg == nil || res.Server == s.resolver {
+		return
+	}
+	s.log.Debugf("resolver %s doesn't answer, using %s", s.resolver, res.Server)
+	fallbacks := []string{s.resolver}
+	for _, r := range s.resolverFallbacks {
+		if r != res.Server {
+			fallbacks = append(fallbacks, r)
+		}
+	}
+	s.resolver, s.resolverFallbacks = res.Server, fallbacks
+}
+
+// newDoTConfig returns the TLS configuration for the resolver. With SPKI pins
+// the certificate chain isn't verified, the server key must match one of the
+// pins instead (RFC 7858 section 4.2).
+func newDoTConfig(sni string, pins []string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: sni}
+	pinned := make(map[string]bool)
+	for _, pin := range pins {
+		if pin = strings.TrimSpace(pin); pin == "" {
+			continue
+		}
+		if b, err := base64.StdEncoding.DecodeString(pin); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("invalid SPKI pin %s: need a base64 SHA-256 digest", pin)
+		}
+		pinned[pin] = true
+	}
+	if len(pinned) == 0 {
+		return config, nil
+	}
+	config.InsecureSkipVerify = true
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			if pinned[base64.StdEncoding.EncodeToString(digest[:])] {
+				return nil
+			}
+		}
+		return fmt.Errorf("no certificate of the resolver matches the SPKI pins")
+	}
+	return config, nil
+}
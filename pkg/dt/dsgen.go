@@ -0,0 +1,191 @@
+package dt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// dsDigests are the digest types generated for DS submission (SHA-256 and
+// SHA-384).
+var dsDigests = []uint8{dns.SHA256, dns.SHA384}
+
+// deprecatedAlgorithms are the DNSSEC algorithms RFC 8624 section 3.1 says
+// validators must not (true) or should not (false) use anymore.
+var deprecatedAlgorithms = map[uint8]bool{
+	dns.RSAMD5:           true,
+	dns.DSA:              true,
+	dns.DSANSEC3SHA1:     true,
+	dns.RSASHA1:          false,
+	dns.RSASHA1NSEC3SHA1: false,
+	dns.ECCGOST:          false,
+}
+
+type DSCheck struct {
+	NS    []NSData
+	Keys  []dns.RR
+	Valid bool
+	DS    []dns.RR
+	Report
+}
+
+func (c *DSCheck) Scan(domain string) {
+	for _, ns := range c.NS {
+		for _, nsip := range ns.IP {
+			res, err := c.s.query(domain, dns.TypeDNSKEY, ns.addr(nsip), true)
+			if err != nil {
+				continue
+			}
+			c.Keys = extractRR(res.Msg.Answer, dns.TypeDNSKEY)
+			if len(c.Keys) > 0 && len(extractRR(res.Msg.Answer, dns.TypeRRSIG)) > 0 {
+				c.Valid, _, _ = c.s.validateRRSIG(c.Keys, res.Msg.Answer)
+			}
+			break
+		}
+		if len(c.Keys) > 0 {
+			break
+		}
+	}
+	// the DS as the parent serves it, the resolver may have a cached one
+	if parent, err := c.s.findNS(c.s.parentZone(domain)); err == nil {
+		if rrs, err := c.s.zoneQuery(parent, dns.Fqdn(domain), dns.TypeDS); err == nil {
+			c.DS = extractRR(rrs, dns.TypeDS)
+			return
+		}
+	}
+	c.DS, _, _ = c.s.queryRRset(domain, dns.TypeDS, c.s.resolver, false)
+}
+
+func (c *DSCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	if len(c.Keys) == 0 {
+		return results
+	}
+	if !c.Valid {
+		return append(results, ReportResult{Result: "FAIL: DNSKEY RRset doesn't validate, not generating DS records",
+			Status: false, Name: "DS", Remediation: "Re-sign the zone so the DNSKEY RRset validates before publishing DS records."})
+	}
+	for _, rr := range c.Keys {
+		key := rr.(*dns.DNSKEY)
+		if key.Flags&dns.SEP == 0 {
+			continue
+		}
+		if dsMatches(c.DS, []dns.RR{key}) {
+			results = append(results, ReportResult{Result: fmt.Sprintf("OK  : DS for KSK %v (algorithm %v) is published at the parent", key.KeyTag(), key.Algorithm),
+				Status: true, Name: "DS"})
+			continue
+		}
+		var records []string
+		res := ReportResult{Result: fmt.Sprintf("WARN: KSK %v (algorithm %v) has no DS at the parent. Submit to your registrar:", key.KeyTag(), key.Algorithm),
+			Status: false, Name: "DS", Remediation: "Submit one of the DS records below to your registrar, SHA-256 preferred."}
+		for _, digest := range dsDigests {
+			ds := key.ToDS(digest)
+			if ds == nil {
+				continue
+			}
+			res.Result += fmt.Sprintf("\n\t   key tag %v, algorithm %v, digest type %v, digest %s", ds.KeyTag, ds.Algorithm, ds.DigestType, strings.ToUpper(ds.Digest))
+			records = append(records, ds.String(), ds.ToCDS().String())
+		}
+		res.Records = records
+		results = append(results, res)
+	}
+	if len(results) == 0 {
+		results = append(results, ReportResult{Result: "WARN: No KSK (flag 257) found to generate DS records for",
+			Status: false, Name: "DS", Remediation: "Add a key signing key (flag 257) to the zone before publishing DS records."})
+	}
+	return results
+}
+
+// Audit checks every DS at the parent has a DNSKEY with the same key tag,
+// algorithm and digest, and reports deprecated algorithms and SHA-1 digests.
+func (c *DSCheck) Audit() []ReportResult {
+	results := []ReportResult{}
+	if len(c.DS) == 0 {
+		return results
+	}
+	if len(c.Keys) == 0 {
+		return append(results, ReportResult{Result: fmt.Sprintf("FAIL: The parent publishes %v DS records but the zone has no DNSKEY, validating resolvers fail the zone", len(c.DS)),
+			Status: false, Name: "DSMatch", Remediation: "Sign the zone again or remove the DS records at the registrar."})
+	}
+	var mismatches []ReportResult
+	matched := 0
+	for _, rr := range c.DS {
+		ds := rr.(*dns.DS)
+		tag := fmt.Sprintf("DS %v (algorithm %s, digest type %v)", ds.KeyTag, dns.AlgorithmToString[ds.Algorithm], ds.DigestType)
+		problem := "matches no DNSKEY of the zone"
+		for _, k := range c.Keys {
+			key := k.(*dns.DNSKEY)
+			if key.KeyTag() != ds.KeyTag || key.Algorithm != ds.Algorithm {
+				continue
+			}
+			kds := key.ToDS(ds.DigestType)
+			switch {
+			case kds == nil:
+				problem = "uses an unsupported digest type"
+			case !strings.EqualFold(kds.Digest, ds.Digest):
+				problem = fmt.Sprintf("has a digest that doesn't match DNSKEY %v", key.KeyTag())
+			default:
+				problem = ""
+			}
+			if problem == "" {
+				break
+			}
+		}
+		if problem != "" {
+			mismatches = append(mismatches, ReportResult{Result: fmt.Sprintf("%s %s", tag, problem), Records: []string{ds.String()}})
+		} else {
+			matched++
+		}
+		if ds.DigestType == dns.SHA1 {
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: %s uses a SHA-1 digest, which must no longer be used for DS records (RFC 8624 section 3.3)", tag),
+				Status: false, Name: "DSDigest", Records: []string{ds.String()}, Remediation: "Submit a DS with digest type 2 (SHA-256) and remove the SHA-1 one."})
+		}
+	}
+	for _, m := range mismatches {
+		// stale DS records are harmless as long as another one matches
+		if matched > 0 {
+			m.Result = fmt.Sprintf("WARN: %s, it is stale", m.Result)
+			m.Remediation = "Remove the stale DS record at the registrar."
+		} else {
+			m.Result = fmt.Sprintf("FAIL: %s, the chain of trust is broken", m.Result)
+			m.Remediation = "Submit the DS of the current KSK to the registrar, or remove the DS records to go insecure."
+		}
+		m.Status, m.Name = false, "DSMatch"
+		results = append(results, m)
+	}
+	seen := make(map[uint8]bool)
+	for _, k := range c.Keys {
+		key := k.(*dns.DNSKEY)
+		mustNot, deprecated := deprecatedAlgorithms[key.Algorithm]
+		if !deprecated || seen[key.Algorithm] {
+			continue
+		}
+		seen[key.Algorithm] = true
+		alg := fmt.Sprintf("%s (%v)", dns.AlgorithmToString[key.Algorithm], key.Algorithm)
+		if mustNot {
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: DNSKEY %v uses algorithm %s, validators treat the zone as unsigned (RFC 8624)", key.KeyTag(), alg),
+				Status: false, Name: "Algorithm", Remediation: "Roll the keys over to algorithm 13 (ECDSAP256SHA256)."})
+		} else {
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: DNSKEY %v uses deprecated algorithm %s, validators are dropping support (RFC 8624)", key.KeyTag(), alg),
+				Status: false, Name: "Algorithm", Remediation: "Roll the keys over to algorithm 13 (ECDSAP256SHA256)."})
+		}
+	}
+	if len(results) == 0 {
+		results = append(results, ReportResult{Result: fmt.Sprintf("OK  : All %v DS records at the parent match a DNSKEY and use current algorithms and digests", len(c.DS)),
+			Status: true, Name: "DSMatch"})
+	}
+	return results
+}
+
+func (c *DSCheck) Dependency() Dependency {
+	return Dependency{Type: "DS", Requires: []string{"DNSKEY"}}
+}
+
+func (c *DSCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "DS"
+	c.Report.Result = append(c.Report.Result, c.Audit()...)
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
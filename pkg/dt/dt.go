@@ -0,0 +1,573 @@
+// Package dt runs the DNS checks of the dt command on a domain.
+package dt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/42wim/ipisp"
+	"github.com/Sirupsen/logrus"
+	"github.com/miekg/dns"
+)
+
+type NSInfo struct {
+	Name   string
+	Rtt    time.Duration
+	Serial int64
+	IPInfo
+	DNSSECInfo
+	Msg *dns.Msg `json:"-"`
+}
+
+type NSData struct {
+	Name string
+	Info []NSInfo
+	IP   []net.IP
+	// Port is the port the nameserver is queried on when given with -ns,
+	// empty for port 53.
+	Port string `json:",omitempty"`
+}
+
+// addr returns the server to query the nameserver at ip with, ip or ip:port.
+func (ns NSData) addr(ip net.IP) string {
+	return hostPort(ip.String(), ns.Port)
+}
+
+type IPInfo struct {
+	IP  net.IP
+	Loc string
+	ASN ipisp.ASN
+	ISP string
+}
+
+type DNSSECInfo struct {
+	Valid      bool
+	ChainValid bool
+	Disabled   bool
+	KeyInfo
+}
+
+type KeyInfo struct {
+	Start int64
+	End   int64
+}
+
+type DomainStat struct {
+	Domain string
+	NS     []NSInfo
+}
+
+type Response struct {
+	Msg    *dns.Msg
+	Server string
+	Rtt    time.Duration
+}
+
+type Report struct {
+	Type   string
+	Result []ReportResult
+	// ScanID is the ID of the scan in Meta, Start and End the times the
+	// check ran.
+	ScanID string
+	Start  time.Time
+	End    time.Time
+	// Raw are the responses the check received, with -include-raw.
+	Raw []RawMessage `json:",omitempty"`
+	// s is the session the check queries through, set before it runs.
+	s *session
+}
+
+type ReportResult struct {
+	Result      string
+	Status      bool
+	Error       string
+	Records     []string
+	Name        string
+	Remediation string
+	Server      string `json:",omitempty"`
+	// Unreachable marks a result about Server not answering, as opposed to
+	// a finding about what it answered. isolate folds these per server.
+	Unreachable bool `json:",omitempty"`
+	// SecondOpinion tells whether the second resolver sees the same problem.
+	SecondOpinion string `json:",omitempty"`
+}
+
+// MarshalJSON adds the severity of the result (OK, WARN, FAIL, ERR or SKIP)
+// so consumers don't have to parse Result.
+func (r ReportResult) MarshalJSON() ([]byte, error) {
+	type plain ReportResult
+	return json.Marshal(struct {
+		plain
+		Level string
+	}{plain(r), resultStatus(r)})
+}
+
+// SubzoneReport holds the reports of a subzone checked by checkSubzones.
+type SubzoneReport struct {
+	Domain  string
+	Depth   int
+	Error   string `json:",omitempty"`
+	Reports []Report
+}
+
+// Options select the checks run by Scan and how dt queries. The zero value
+// runs the standard checks through the default resolver.
+type Options struct {
+	// Resolver is host[:port] or tls://host[:port] (default the system
+	// resolver, or 8.8.8.8 when none is configured), or a comma separated
+	// list of them queried in order when one doesn't answer.
+	Resolver string
+	// DoT, DoTSNI and DoTPins configure DNS over TLS to the resolver.
+	DoT     bool
+	DoTSNI  string
+	DoTPins []string
+	// IPv4 and IPv6 restrict the queries and the nameserver addresses
+	// checked to one address family.
+	IPv4    bool
+	IPv6    bool
+	Timeout time.Duration
+	Probes  int
+	// QPS is the query rate per nameserver of scans (default 10).
+	QPS int
+	// Concurrency is the number of nameserver addresses queried in
+	// parallel by the per nameserver checks (default 8).
+	Concurrency int
+	// Class is the class of all queries: IN (default), CH or HS.
+	Class string
+	// NoShuffle probes nameservers and runs checks in a fixed order instead
+	// of a random order per run.
+	NoShuffle bool
+	// Fast skips the origin lookups of nameserver addresses, multiple probes
+	// and the checks that aren't about delegation, DNSSEC or mail, for a
+	// quick sanity pass.
+	Fast bool
+	// Jitter delays Scan by a random duration up to Jitter, so monitoring
+	// runs started together don't hit the nameservers at once.
+	Jitter time.Duration
+	CT     bool
+	PSL    string
+	Debug  bool
+	// IncludeRaw keeps the responses every check received in its report.
+	IncludeRaw bool
+	// UTC shows times in UTC instead of the local time zone.
+	UTC bool
+
+	// Profile is the name of the profile selecting the checks (default
+	// standard).
+	Profile string
+	// NS replaces the published nameservers (host[:port]), ExcludeNS skips
+	// nameservers by name or IP.
+	NS        []string
+	ExcludeNS []string
+	// Predelegate audits the nameservers in NS before delegating to them.
+	Predelegate bool
+	Subzones    []string
+	Recurse     bool
+	// ZoneFile is checked for duplicate and conflicting records instead of
+	// the zone transfer.
+	ZoneFile string
+
+	RootHints       string
+	MinProviders    int
+	MinCountries    int
+	DeniedCountries []string
+	LastSerial      uint32
+	SyncNames       []string
+	Web             bool
+	TLS             bool
+	TLSHosts        []string
+	PDNS            string
+	Autodiscover    bool
+	ResolverTest    bool
+	// Migration is the cutover of a planned NS migration, a duration or an
+	// RFC 3339 time.
+	Migration      string
+	ThreatFeeds    []string
+	ProviderStatus bool
+	// RRSIGWarn is how long before expiry a signature is reported, in days
+	// (7d) or as a duration, 7 days when empty.
+	RRSIGWarn string
+	// CheckParked runs all checks on domains that look parked instead of
+	// only reporting them as parked.
+	CheckParked bool
+	// DKIMSelectors are probed for DKIM keys besides the common selectors.
+	DKIMSelectors []string
+	// SSHFPHosts are checked for SSHFP records besides the apex and the
+	// nameservers, SSHFPConnect compares them with the host keys on port 22.
+	SSHFPHosts   []string
+	SSHFPConnect bool
+	// MailKeyUsers are probed for OPENPGPKEY and SMIMEA records besides the
+	// common users.
+	MailKeyUsers []string
+	// Offline runs only the checks that can be evaluated on ZoneFile and
+	// LastSerial without sending a query, the others are reported skipped.
+	Offline bool
+	// Intranet audits zones under an internal root: public services are not
+	// contacted and the checks needing the public Internet are reported as
+	// suppressed. RootHints and TrustAnchors point dt at the internal root.
+	Intranet bool
+	// TrustAnchors is a file with the DS or DNSKEY records of the root,
+	// replacing the IANA trust anchors.
+	TrustAnchors string
+	// SecondOpinion is the resolver (host[:port]) failing checks depending
+	// on our resolver are verified again with, telling a broken domain from
+	// a broken resolver path. The default is a public resolver, "off"
+	// disables it.
+	SecondOpinion string
+}
+
+// Result is the outcome of Scan.
+type Result struct {
+	Domain string
+	// Summary comes first in the JSON output so consumers can stop reading
+	// early.
+	Summary     Summary
+	Meta        *Meta
+	Nameservers []NSInfo
+	Reports     []Report
+	Subzones    []SubzoneReport `json:",omitempty"`
+	// Policy is the verdict of the policy set by the caller, see LoadPolicy.
+	Policy  *Verdict `json:",omitempty"`
+	Timings []Timing `json:",omitempty"`
+}
+
+// Timing is the wall clock duration and number of queries of a check or of a
+// step of Scan.
+type Timing struct {
+	Check    string
+	Duration time.Duration
+	Queries  int64
+}
+
+// defaultCheckers returns the checks run for every domain. subzones are names
+// to verify as delegated subzones besides the ones discovered.
+func defaultCheckers(nsdatas []NSData, subzones []string, opts Options) []Checker {
+	// validated by Scan, an invalid window falls back to the default
+	rrsigWarn, _ := parseWindow(opts.RRSIGWarn)
+	checkers := []Checker{
+		&ApexCheck{NS: nsdatas},
+		&RootCheck{NS: nsdatas, File: opts.RootHints},
+		&ParentCheck{NS: nsdatas},
+		&NSCheck{NS: nsdatas, MinProviders: opts.MinProviders, MinCountries: opts.MinCountries, DeniedCountries: opts.DeniedCountries, NoOrigin: opts.Fast || opts.Intranet},
+		&Glue{NS: nsdatas},
+		&DelegationCheck{NS: nsdatas},
+		&DSCheck{NS: nsdatas},
+		&ZoneSigCheck{NS: nsdatas},
+		&RRSIGCheck{NS: nsdatas, Warn: rrsigWarn},
+		&NSECCheck{NS: nsdatas},
+		&ZoneDataCheck{NS: nsdatas, File: opts.ZoneFile},
+		&ENTCheck{NS: nsdatas},
+		&AXFRCheck{NS: nsdatas},
+		&SubzoneCheck{NS: nsdatas, List: subzones},
+		&ResponseCheck{NS: nsdatas},
+		&EDNSCheck{NS: nsdatas},
+		&TCPCheck{NS: nsdatas},
+		&SOACheck{NS: nsdatas, LastSerial: opts.LastSerial, SyncNames: opts.SyncNames},
+		&MXCheck{NS: nsdatas},
+		&WebCheck{NS: nsdatas, HTTP: opts.Web},
+		&CAACheck{NS: nsdatas},
+		&GeoDNSCheck{NS: nsdatas},
+		&SpamCheck{NS: nsdatas},
+		&DKIMCheck{NS: nsdatas, Selectors: opts.DKIMSelectors},
+		&MTASTSCheck{NS: nsdatas},
+		&DANECheck{NS: nsdatas},
+		&SSHFPCheck{NS: nsdatas, Hosts: opts.SSHFPHosts, Connect: opts.SSHFPConnect},
+		&MailKeyCheck{NS: nsdatas, Users: opts.MailKeyUsers},
+		&AcmeCheck{NS: nsdatas},
+		&ConfusableCheck{NS: nsdatas}}
+	if opts.Intranet && opts.RootHints == "" {
+		// the bundled hints are the public root servers
+		var kept []Checker
+		for _, c := range checkers {
+			if _, ok := c.(*RootCheck); !ok {
+				kept = append(kept, c)
+			}
+		}
+		checkers = kept
+	}
+	if !opts.Fast {
+		return checkers
+	}
+	var fast []Checker
+	for _, c := range checkers {
+		switch c.(type) {
+		case *RootCheck, *ZoneSigCheck, *ZoneDataCheck, *ENTCheck, *AXFRCheck, *SubzoneCheck, *ResponseCheck, *EDNSCheck, *TCPCheck,
+			*WebCheck, *CAACheck, *MTASTSCheck, *DANECheck, *SSHFPCheck, *MailKeyCheck, *GeoDNSCheck, *AcmeCheck, *ConfusableCheck:
+			continue
+		}
+		fast = append(fast, c)
+	}
+	return fast
+}
+
+// checkSubzones runs the default checks on every subzone and its subzones,
+// depth first.
+func (s *session) checkSubzones(subzones []string, opts Options, profile Profile, depth int) []SubzoneReport {
+	var results []SubzoneReport
+	if depth > maxSubzoneDepth {
+		return results
+	}
+	for _, subzone := range subzones {
+		if s.ctx.Err() != nil {
+			break
+		}
+		nsdatas, err := s.findNS(subzone)
+		if err != nil {
+			results = append(results, SubzoneReport{Domain: subzone, Depth: depth, Error: err.Error()})
+			continue
+		}
+		checkers := defaultCheckers(nsdatas, nil, opts)
+		reports, _ := s.runCheckers(subzone, checkers, profile)
+		results = append(results, SubzoneReport{Domain: subzone, Depth: depth, Reports: reports})
+		for _, checker := range checkers {
+			if c, ok := checker.(*SubzoneCheck); ok {
+				results = append(results, s.checkSubzones(c.Subzones, opts, profile, depth+1)...)
+			}
+		}
+	}
+	return results
+}
+
+// nsInfos looks up the origin, SOA serial and DNSSEC state of every
+// nameserver address.
+func (s *session) nsInfos(domain string, nsdatas []NSData, infos map[string]IPInfo, chainValid bool) []NSInfo {
+	// for now disable debuglevel (because of multiple goroutines output)
+	if s.debug {
+		s.log.Level = logrus.InfoLevel
+		defer func() { s.log.Level = logrus.DebugLevel }()
+	}
+
+	result := make([]NSInfo, serverCount(nsdatas))
+	s.eachServer(nsdatas, &Report{}, func(i int, ns NSData, ip net.IP, r *Report) {
+		var newnsinfo NSInfo
+		newnsinfo.IPInfo = infos[ip.String()]
+		newnsinfo.IPInfo.IP = ip
+		newnsinfo.Name = ns.Name
+
+		soa, rtt, err := s.queryRRset(domain, dns.TypeSOA, ns.addr(ip), false)
+		if err == nil {
+			newnsinfo.Rtt = rtt
+			newnsinfo.Serial = int64(soa[0].(*dns.SOA).Serial)
+		}
+
+		keys, _, _ := s.queryRRset(domain, dns.TypeDNSKEY, ns.addr(ip), true)
+		res, err := s.query(domain, dns.TypeNS, ns.addr(ip), true)
+		if err == nil {
+			valid, keyinfo, _ := s.validateRRSIG(keys, res.Msg.Answer)
+			newnsinfo.DNSSECInfo = DNSSECInfo{Valid: valid, KeyInfo: keyinfo, ChainValid: chainValid}
+			if keyinfo.Start == 0 && len(keys) == 0 {
+				newnsinfo.Disabled = true
+			}
+		}
+		newnsinfo.Msg = res.Msg
+		result[i] = newnsinfo
+	})
+	return result
+}
+
+// Scan runs the checks selected by opts on domain. When ctx is done the
+// queries of the running checks fail and the remaining checks are left out.
+func Scan(ctx context.Context, domain string, opts Options) (*Result, error) {
+	start := time.Now()
+	s, err := newSession(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.scan(domain, opts)
+	if result != nil {
+		s.stamp(result, s.newMeta(start))
+	}
+	return result, err
+}
+
+// scan is Scan without the metadata.
+func (s *session) scan(domain string, opts Options) (*Result, error) {
+	if opts.Jitter > 0 {
+		select {
+		case <-time.After(time.Duration(rand.Int63n(int64(opts.Jitter)))):
+		case <-s.ctx.Done():
+			return nil, s.ctx.Err()
+		}
+	}
+	if opts.Profile == "" {
+		opts.Profile = "standard"
+	}
+	profile, err := LookupProfile(opts.Profile)
+	if err != nil {
+		return nil, err
+	}
+	var cutover time.Time
+	if opts.Migration != "" {
+		if cutover, err = parseCutover(opts.Migration); err != nil {
+			return nil, err
+		}
+	}
+	if opts.RRSIGWarn != "" {
+		if _, err := parseWindow(opts.RRSIGWarn); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Offline {
+		return s.offlineScan(domain, opts, profile)
+	}
+	if opts.Predelegate && len(opts.NS) == 0 {
+		return nil, fmt.Errorf("predelegate needs the new nameservers")
+	}
+
+	var timings []Timing
+	var nsdatas []NSData
+	s.timed(&timings, "Nameserver discovery", func() {
+		if len(opts.NS) > 0 {
+			nsdatas, err = s.overrideNS(opts.NS)
+		} else {
+			nsdatas, err = s.findNS(dns.Fqdn(domain))
+		}
+	})
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(opts.NS) > 0 && err != nil {
+		return nil, err
+	}
+	if len(nsdatas) == 0 {
+		return nil, fmt.Errorf("no nameservers found for %s", domain)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var familyReport Report
+	if s.ipFamily != 0 {
+		if nsdatas, familyReport = s.familyNS(nsdatas); len(nsdatas) == 0 {
+			return nil, fmt.Errorf("no nameserver of %s has an IPv%v address", domain, s.ipFamily)
+		}
+	}
+	nsdatas, excluded := excludeNS(nsdatas, opts.ExcludeNS)
+	if len(nsdatas) == 0 {
+		return nil, fmt.Errorf("all nameservers of %s are excluded", domain)
+	}
+
+	// check dnssec
+	var chain []chainLink
+	chainValid := false
+	if !opts.Intranet || opts.TrustAnchors != "" {
+		s.timed(&timings, "DNSSEC chain", func() { chain = s.walkChain(dns.Fqdn(domain)) })
+		chainValid = chain[len(chain)-1].Err == nil
+	}
+
+	var ips []net.IP
+	for _, nsdata := range nsdatas {
+		ips = append(ips, nsdata.IP...)
+	}
+	infos, infoErr := make(map[string]IPInfo), error(nil)
+	if !opts.Fast && !opts.Intranet {
+		s.timed(&timings, "Origin lookup", func() { infos, infoErr = s.ipinfos(ips) })
+	}
+	result := &Result{Domain: domain}
+	s.timed(&timings, "Nameserver info", func() { result.Nameservers = s.nsInfos(domain, nsdatas, infos, chainValid) })
+
+	reports := []Report{}
+	if opts.Intranet {
+		reports = append(reports, intranetReport(opts))
+	}
+	if s.ipFamily != 0 {
+		reports = append(reports, familyReport)
+	}
+	if len(excluded) > 0 {
+		report := Report{Type: "Excluded"}
+		for _, e := range excluded {
+			report.Result = append(report.Result, ReportResult{Result: fmt.Sprintf("WARN: %s is excluded from all checks", e),
+				Status: false, Name: "Excluded", Remediation: "Drop the nameserver from -exclude-ns to check it again."})
+		}
+		reports = append(reports, report)
+	}
+	if infoErr != nil {
+		reports = append(reports, Report{Type: "IPInfo", Result: []ReportResult{{Result: fmt.Sprintf("ERR : Origin lookup of the nameserver addresses failed: %s", infoErr),
+			Error: infoErr.Error(), Name: "Origin"}}})
+	}
+
+	if !opts.CheckParked && !opts.Predelegate {
+		var signals []string
+		var service bool
+		s.timed(&timings, "Parked detection", func() { signals, service = s.parkedSignals(domain, nsdatas) })
+		if len(signals) > 0 && !service {
+			reports = append(reports, parkedHint(domain, signals))
+		} else if len(signals) > 0 {
+			result.Timings = timings
+			result.Reports = append(reports, parkedReport(domain, signals))
+			result.Summary = summarize(result.Reports)
+			result.Summary.Parked = signals
+			result.Summary.Grade = "parked"
+			return result, s.ctx.Err()
+		}
+	}
+
+	// newCheckers returns fresh checkers, the second opinion runs some again
+	newCheckers := func() []Checker {
+		checkers := defaultCheckers(nsdatas, opts.Subzones, opts)
+		if opts.Predelegate {
+			checkers = append(checkers, &PredelegateCheck{NS: nsdatas})
+		}
+		if opts.Web {
+			checkers = append(checkers, &HTTPCheck{NS: nsdatas})
+		}
+		if opts.TLS {
+			checkers = append(checkers, &TLSCheck{NS: nsdatas, Hosts: opts.TLSHosts})
+		}
+		if opts.PDNS != "" {
+			checkers = append(checkers, &PassiveDNSCheck{NS: nsdatas, Provider: &COFProvider{URL: opts.PDNS}})
+		}
+		if opts.Autodiscover {
+			checkers = append(checkers, &AutodiscoverCheck{NS: nsdatas})
+		}
+		if opts.ResolverTest {
+			checkers = append(checkers, &EntropyCheck{NS: nsdatas})
+		}
+		if opts.Migration != "" {
+			checkers = append(checkers, &MigrationCheck{NS: nsdatas, Cutover: cutover})
+		}
+		if len(opts.ThreatFeeds) > 0 {
+			checkers = append(checkers, &ThreatCheck{NS: nsdatas, Feeds: opts.ThreatFeeds})
+		}
+		return checkers
+	}
+	checkers := newCheckers()
+
+	// TODO concurrency
+	done, checkTimings := s.runEach(domain, checkers, profile)
+	timings = append(timings, checkTimings...)
+	if second := s.secondResolver(opts.SecondOpinion); second != "" && second != s.resolver && s.ctx.Err() == nil {
+		s.timed(&timings, "Second opinion", func() { s.secondOpinion(domain, checkers, newCheckers(), done, second) })
+	}
+	checked := compact(done)
+	if opts.ProviderStatus && !opts.Intranet {
+		if report, ok := s.providerStatus(nsdatas, append(append([]Report{}, reports...), checked...)); ok {
+			checked = append(checked, report)
+		}
+	}
+
+	// the apex report comes first, followed by the chain and the notes on
+	// the nameservers checked
+	var head []Report
+	if len(checked) > 0 && checked[0].Type == "Apex" {
+		head, checked = []Report{checked[0]}, checked[1:]
+	}
+	if chain != nil {
+		head = append(head, Report{Type: "DNSSEC", Result: []ReportResult{chainReport(chain)}})
+	}
+	result.Reports = append(append(head, reports...), checked...)
+
+	if opts.Recurse {
+		for _, checker := range checkers {
+			if c, ok := checker.(*SubzoneCheck); ok {
+				s.timed(&timings, "Subzones", func() { result.Subzones = s.checkSubzones(c.Subzones, opts, profile, 1) })
+			}
+		}
+	}
+	result.Timings = timings
+	result.Summary = summarize(result.Reports)
+	return result, s.ctx.Err()
+}
@@ -0,0 +1,306 @@
+package dt
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// ednsUnknownOption is an unassigned EDNS option code.
+	ednsUnknownOption = 100
+	// ednsUnknownFlag is an unassigned bit of the EDNS flags (must be zero).
+	ednsUnknownFlag = 0x4000
+)
+
+// ednsProbe is a crafted query in the style of the ISC EDNS compliance
+// tester and what a compliant server answers to it.
+type ednsProbe struct {
+	Name  string
+	Proto string
+	// Critical probes break resolution or DNSSEC when they fail.
+	Critical bool
+	Query    func(s *session, zone string) *dns.Msg
+	// Check returns why the response is not compliant, or "".
+	Check func(m *dns.Msg) string
+}
+
+// ednsQuery builds a query without recursion desired for qtype of zone with
+// an OPT record of the given version, buffer size and flags, carrying opts.
+func (s *session) ednsQuery(zone string, qtype uint16, version uint8, size uint16, flags uint16, opts ...dns.EDNS0) *dns.Msg {
+	m := prepMsg(zone, qtype, s.qclass)
+	m.RecursionDesired = false
+	opt := &dns.OPT{Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeOPT}}
+	opt.SetUDPSize(size)
+	opt.SetVersion(version)
+	opt.Hdr.Ttl |= uint32(flags)
+	opt.Option = opts
+	m.Extra = append(m.Extra, opt)
+	return m
+}
+
+// ednsRcode returns the rcode of m including the extended bits of the OPT.
+func ednsRcode(m *dns.Msg) int {
+	if opt := m.IsEdns0(); opt != nil {
+		return m.Rcode | int(opt.Hdr.Ttl>>24)<<4
+	}
+	return m.Rcode
+}
+
+// ednsExpect checks the rcode and the OPT record of m, with version -1
+// meaning there must be no OPT record. A NOERROR response must carry the SOA
+// asked for, other responses no records.
+func ednsExpect(m *dns.Msg, rcode int, version int) string {
+	if got := ednsRcode(m); got != rcode {
+		name, ok := dns.RcodeToString[got]
+		if got == dns.RcodeBadVers {
+			name, ok = "BADVERS", true
+		}
+		if !ok {
+			name = fmt.Sprintf("rcode %v", got)
+		}
+		want := dns.RcodeToString[rcode]
+		if rcode == dns.RcodeBadVers {
+			want = "BADVERS"
+		}
+		return fmt.Sprintf("answered %s instead of %s", name, want)
+	}
+	opt := m.IsEdns0()
+	switch {
+	case version < 0 && opt != nil:
+		return "answered with an OPT record to a query without EDNS"
+	case version >= 0 && opt == nil:
+		return "answered without an OPT record"
+	case opt != nil && int(opt.Version()) != version:
+		return fmt.Sprintf("answered with EDNS version %v instead of %v", opt.Version(), version)
+	}
+	if m.Question[0].Qtype != dns.TypeSOA {
+		return ""
+	}
+	if rcode == dns.RcodeSuccess && len(extractRR(m.Answer, dns.TypeSOA)) == 0 {
+		return "answered without the SOA record"
+	}
+	if rcode != dns.RcodeSuccess && len(m.Answer) > 0 {
+		return "answered with records to an unsupported EDNS version"
+	}
+	return ""
+}
+
+// ednsEchoed reports whether the response carries the unknown option back.
+func ednsEchoed(m *dns.Msg) bool {
+	if opt := m.IsEdns0(); opt != nil {
+		for _, o := range opt.Option {
+			if o.Option() == ednsUnknownOption {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ednsProbes are the EDNS compliance probes, see RFC 6891 and
+// https://ednscomp.isc.org/.
+var ednsProbes = []ednsProbe{
+	{Name: "dns", Proto: "udp", Critical: true,
+		Query: func(s *session, zone string) *dns.Msg {
+			m := prepMsg(zone, dns.TypeSOA, s.qclass)
+			m.RecursionDesired = false
+			return m
+		},
+		Check: func(m *dns.Msg) string { return ednsExpect(m, dns.RcodeSuccess, -1) }},
+	{Name: "edns", Proto: "udp", Critical: true,
+		Query: func(s *session, zone string) *dns.Msg { return s.ednsQuery(zone, dns.TypeSOA, 0, 4096, 0) },
+		Check: func(m *dns.Msg) string { return ednsExpect(m, dns.RcodeSuccess, 0) }},
+	{Name: "edns1", Proto: "udp",
+		Query: func(s *session, zone string) *dns.Msg { return s.ednsQuery(zone, dns.TypeSOA, 1, 4096, 0) },
+		Check: func(m *dns.Msg) string { return ednsExpect(m, dns.RcodeBadVers, 0) }},
+	{Name: "ednsopt", Proto: "udp",
+		Query: func(s *session, zone string) *dns.Msg {
+			return s.ednsQuery(zone, dns.TypeSOA, 0, 4096, 0, &dns.EDNS0_LOCAL{Code: ednsUnknownOption})
+		},
+		Check: func(m *dns.Msg) string {
+			if problem := ednsExpect(m, dns.RcodeSuccess, 0); problem != "" {
+				return problem
+			}
+			if ednsEchoed(m) {
+				return "echoed the unknown option"
+			}
+			return ""
+		}},
+	{Name: "edns1opt", Proto: "udp",
+		Query: func(s *session, zone string) *dns.Msg {
+			return s.ednsQuery(zone, dns.TypeSOA, 1, 4096, 0, &dns.EDNS0_LOCAL{Code: ednsUnknownOption})
+		},
+		Check: func(m *dns.Msg) string {
+			if problem := ednsExpect(m, dns.RcodeBadVers, 0); problem != "" {
+				return problem
+			}
+			if ednsEchoed(m) {
+				return "echoed the unknown option"
+			}
+			return ""
+		}},
+	{Name: "do", Proto: "udp", Critical: true,
+		Query: func(s *session, zone string) *dns.Msg { return s.ednsQuery(zone, dns.TypeSOA, 0, 4096, 0x8000) },
+		Check: func(m *dns.Msg) string {
+			if problem := ednsExpect(m, dns.RcodeSuccess, 0); problem != "" {
+				return problem
+			}
+			if !m.IsEdns0().Do() {
+				return "cleared the DO bit"
+			}
+			return ""
+		}},
+	{Name: "ednsflags", Proto: "udp",
+		Query: func(s *session, zone string) *dns.Msg {
+			return s.ednsQuery(zone, dns.TypeSOA, 0, 4096, ednsUnknownFlag)
+		},
+		Check: func(m *dns.Msg) string {
+			if problem := ednsExpect(m, dns.RcodeSuccess, 0); problem != "" {
+				return problem
+			}
+			if m.IsEdns0().Hdr.Ttl&ednsUnknownFlag != 0 {
+				return "echoed the unknown EDNS flag, which must be zero"
+			}
+			return ""
+		}},
+	{Name: "bufsize", Proto: "udp",
+		Query: func(s *session, zone string) *dns.Msg { return s.ednsQuery(zone, dns.TypeDNSKEY, 0, 4096, 0x8000) },
+		Check: func(m *dns.Msg) string { return ednsExpect(m, dns.RcodeSuccess, 0) }},
+	{Name: "edns512", Proto: "udp",
+		Query: func(s *session, zone string) *dns.Msg { return s.ednsQuery(zone, dns.TypeDNSKEY, 0, 512, 0x8000) },
+		Check: func(m *dns.Msg) string {
+			if ednsRcode(m) != dns.RcodeSuccess {
+				return fmt.Sprintf("answered %s instead of NOERROR", dns.RcodeToString[ednsRcode(m)])
+			}
+			if m.Len() > 512 && !m.Truncated {
+				return fmt.Sprintf("sent %v bytes to a 512 byte buffer without setting TC", m.Len())
+			}
+			return ""
+		}},
+	{Name: "ednstcp", Proto: "tcp", Critical: true,
+		Query: func(s *session, zone string) *dns.Msg { return s.ednsQuery(zone, dns.TypeSOA, 0, 4096, 0) },
+		Check: func(m *dns.Msg) string { return ednsExpect(m, dns.RcodeSuccess, 0) }},
+}
+
+// ednsExchange sends a crafted query as is, unlike query which builds its
+// own, and returns the response whatever its rcode, also when truncated.
+func (s *session) ednsExchange(m *dns.Msg, server, proto string) (*dns.Msg, error) {
+	if s.offline {
+		return nil, errOffline
+	}
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	c := &dns.Client{Net: s.familyNet(proto), Timeout: s.queryTimeout}
+	atomic.AddInt64(&s.queryCount, 1)
+	in, rtt, err := c.Exchange(m, serverAddr(server))
+	s.recordRaw(m, in, server, proto, rtt, err)
+	if err == dns.ErrTruncated && in != nil {
+		return in, nil
+	}
+	return in, err
+}
+
+// EDNSCheck probes every nameserver address with EDNS queries that
+// compliant servers answer in a defined way (RFC 6891) and reports the
+// servers that drop, mangle or reject them.
+type EDNSCheck struct {
+	NS   []NSData
+	EDNS []EDNSData
+	Report
+}
+
+type EDNSData struct {
+	Name string
+	IP   string
+	// Problems maps the failed probes to what went wrong, Error is set when
+	// the plain query already failed and nothing was probed.
+	Problems map[string]string
+	Error    string
+}
+
+func (c *EDNSCheck) Scan(domain string) {
+	zone := dns.Fqdn(domain)
+	for _, ns := range c.NS {
+		for _, ip := range ns.IP {
+			c.EDNS = append(c.EDNS, EDNSData{Name: ns.Name, IP: ns.addr(ip), Problems: make(map[string]string)})
+		}
+	}
+	sem := make(chan struct{}, c.s.concurrency)
+	var wg sync.WaitGroup
+	for i := range c.EDNS {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(d *EDNSData) {
+			defer func() { <-sem; wg.Done() }()
+			for i, probe := range ednsProbes {
+				in, err := c.s.ednsExchange(probe.Query(c.s, zone), d.IP, probe.Proto)
+				// without an answer to the plain query the server is down,
+				// not broken by EDNS
+				if err != nil && i == 0 {
+					d.Error = err.Error()
+					return
+				}
+				if err != nil {
+					if e, ok := err.(net.Error); ok && e.Timeout() {
+						d.Problems[probe.Name] = "no response, the query is dropped"
+					} else {
+						d.Problems[probe.Name] = err.Error()
+					}
+					continue
+				}
+				if problem := probe.Check(in); problem != "" {
+					d.Problems[probe.Name] = problem
+				}
+			}
+		}(&c.EDNS[i])
+	}
+	wg.Wait()
+}
+
+func (c *EDNSCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	for _, d := range c.EDNS {
+		if d.Error != "" {
+			results = append(results, ReportResult{Result: fmt.Sprintf("ERR : %s (%s) doesn't answer, EDNS not probed: %s", d.Name, d.IP, d.Error),
+				Status: false, Name: "EDNS", Server: d.IP, Error: d.Error, Unreachable: true})
+			continue
+		}
+		if len(d.Problems) == 0 {
+			continue
+		}
+		status := "WARN"
+		var problems []string
+		for _, probe := range ednsProbes {
+			problem, ok := d.Problems[probe.Name]
+			if !ok {
+				continue
+			}
+			if probe.Critical {
+				status = "FAIL"
+			}
+			problems = append(problems, fmt.Sprintf("%s: %s", probe.Name, problem))
+		}
+		results = append(results, ReportResult{Result: fmt.Sprintf("%s: %s (%s) fails %v of %v EDNS compliance probes\n\t   %s", status, d.Name, d.IP,
+			len(problems), len(ednsProbes), strings.Join(problems, "\n\t   ")),
+			Status: false, Name: "EDNS", Server: d.IP,
+			Remediation: "Upgrade the nameserver software and make sure no firewall or load balancer drops or rewrites EDNS queries (https://ednscomp.isc.org/)."})
+	}
+	if len(results) == 0 && len(c.EDNS) > 0 {
+		results = append(results, ReportResult{Result: fmt.Sprintf("OK  : All %v nameserver addresses pass the %v EDNS compliance probes", len(c.EDNS), len(ednsProbes)),
+			Status: true, Name: "EDNS"})
+	}
+	return results
+}
+
+func (c *EDNSCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "EDNS"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
@@ -0,0 +1,143 @@
+package dt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// entProbes are common names whose parents are usually empty non-terminals,
+// used when the zone can't be transferred.
+var entProbes = []struct {
+	Qtype uint16
+	Name  string
+}{
+	{dns.TypeSRV, "_sip._tcp."},
+	{dns.TypeSRV, "_sip._udp."},
+	{dns.TypeSRV, "_sip._tls."},
+	{dns.TypeSRV, "_xmpp-server._tcp."},
+	{dns.TypeSRV, "_autodiscover._tcp."},
+	{dns.TypeSRV, "_submission._tcp."},
+	{dns.TypeSRV, "_imaps._tcp."},
+	{dns.TypeSRV, "_ldap._tcp."},
+}
+
+// maxENTChecked is the number of empty non-terminals queried on every
+// nameserver, large reverse zones have many thousands.
+const maxENTChecked = 100
+
+type ENTCheck struct {
+	NS  []NSData
+	ENT []string
+	// Total is the number of empty non-terminals found, of which the first
+	// maxENTChecked are in ENT.
+	Total int
+	AXFR  bool
+	Report
+}
+
+// emptyNonTerminals returns the names between the owners and the apex that
+// have no records of their own.
+func emptyNonTerminals(apex string, owners []string) []string {
+	sorter := &spillSort{}
+	for _, owner := range owners {
+		sorter.Add(canonicalKey(owner))
+	}
+	var names []string
+	sortedENTs(apex, sorter, func(name string) {
+		names = append(names, name)
+	})
+	return names
+}
+
+// sortedENTs passes the empty non-terminals below apex to fn, reading the
+// canonicalKey of every owner from sorter. As a name sorts right before the
+// names below it only the names above the current one are remembered.
+func sortedENTs(apex string, sorter *spillSort, fn func(string)) error {
+	apexKey := canonicalKey(apex)
+	depth := len(dns.SplitDomainName(apex))
+	var stack []string
+	return sorter.Each(func(key string) {
+		if !strings.HasPrefix(key, apexKey+"\x01") && !(apexKey == "" && key != "") {
+			return
+		}
+		for len(stack) > 0 && !strings.HasPrefix(key, stack[len(stack)-1]+"\x01") {
+			if stack[len(stack)-1] == key {
+				return
+			}
+			stack = stack[:len(stack)-1]
+		}
+		labels := strings.Split(key, "\x01")
+		for i := depth + 1; i < len(labels); i++ {
+			parent := strings.Join(labels[:i], "\x01")
+			if len(stack) > 0 && len(stack[len(stack)-1]) >= len(parent) {
+				continue
+			}
+			fn(keyName(parent))
+			stack = append(stack, parent)
+		}
+		stack = append(stack, key)
+	})
+}
+
+func (c *ENTCheck) Scan(domain string) {
+	apex := strings.ToLower(dns.Fqdn(domain))
+	sorter := &spillSort{}
+	defer sorter.Close()
+	var owners []string
+	source, _ := c.s.streamZone("", c.NS, apex, func(r ZoneRecord) {
+		sorter.Add(canonicalKey(r.RR.Header().Name))
+	})
+	if c.AXFR = source != ""; c.AXFR {
+		sortedENTs(apex, sorter, func(name string) {
+			if c.Total++; c.Total <= maxENTChecked {
+				c.ENT = append(c.ENT, name)
+			}
+		})
+		return
+	}
+	if server, ok := c.s.respondingServer(c.NS, apex); ok {
+		for _, probe := range entProbes {
+			if _, _, err := c.s.queryRRset(probe.Name+apex, probe.Qtype, server, false); err == nil {
+				owners = append(owners, probe.Name+apex)
+			}
+		}
+	}
+	c.ENT = emptyNonTerminals(apex, owners)
+	c.Total = len(c.ENT)
+}
+
+func (c *ENTCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	for _, ent := range c.ENT {
+		for _, ns := range c.NS {
+			for _, ip := range ns.IP {
+				_, err := c.s.query(ent, dns.TypeTXT, ns.addr(ip), false)
+				if err != nil && strings.Contains(err.Error(), "NXDOMAIN") {
+					results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: %s (%s) answers NXDOMAIN for empty non-terminal %s instead of NODATA", ns.Name, ip, ent),
+						Status: false, Name: "ENT", Remediation: "Upgrade the nameserver software, empty non-terminals must answer NODATA (RFC 8020)."})
+				}
+			}
+		}
+	}
+	if len(c.ENT) == 0 {
+		results = append(results, ReportResult{Result: "OK  : No empty non-terminals found to verify",
+			Status: true, Name: "ENT"})
+	} else if len(results) == 0 {
+		res := ReportResult{Result: fmt.Sprintf("OK  : All nameservers answer NODATA for %v empty non-terminals", len(c.ENT)),
+			Status: true, Name: "ENT"}
+		if c.Total > len(c.ENT) {
+			res.Result += fmt.Sprintf(" (the first %v of %v checked)", len(c.ENT), c.Total)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func (c *ENTCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "ENT"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
@@ -0,0 +1,99 @@
+package dt
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// entropyTests are echo zones that report the randomness of the queries the
+// resolver sent to them.
+var entropyTests = []struct {
+	Name  string
+	Query string
+}{
+	{"source port", "porttest.dns-oarc.net."},
+	{"query ID", "txidtest.dns-oarc.net."},
+}
+
+var entropyRe = regexp.MustCompile(`is (\w+): (\d+) queries in ([\d.]+) seconds from (\d+) \w+ with std dev ([\d.]+)`)
+
+type EntropyCheck struct {
+	NS      []NSData
+	Entropy []EntropyData
+	Report
+}
+
+type EntropyData struct {
+	Name    string
+	Rating  string
+	Queries int
+	Unique  int
+	StdDev  float64
+	Bits    float64
+	Error   string
+}
+
+// entropyBits estimates the bits of randomness of a uniform distribution with
+// the given standard deviation.
+func entropyBits(stddev float64) float64 {
+	if stddev <= 0 {
+		return 0
+	}
+	return math.Log2(stddev * math.Sqrt(12))
+}
+
+func (c *EntropyCheck) Scan(domain string) {
+	for _, test := range entropyTests {
+		data := EntropyData{Name: test.Name}
+		rrset, _, err := c.s.queryRRset(test.Query, dns.TypeTXT, c.s.resolver, false)
+		if err != nil {
+			data.Error = err.Error()
+			c.Entropy = append(c.Entropy, data)
+			continue
+		}
+		txt := strings.Join(rrset[0].(*dns.TXT).Txt, "")
+		m := entropyRe.FindStringSubmatch(txt)
+		if m == nil {
+			data.Error = fmt.Sprintf("unexpected answer %q", txt)
+			c.Entropy = append(c.Entropy, data)
+			continue
+		}
+		data.Rating = m[1]
+		data.Queries, _ = strconv.Atoi(m[2])
+		data.Unique, _ = strconv.Atoi(m[4])
+		data.StdDev, _ = strconv.ParseFloat(m[5], 64)
+		data.Bits = entropyBits(data.StdDev)
+		c.Entropy = append(c.Entropy, data)
+	}
+}
+
+func (c *EntropyCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	for _, data := range c.Entropy {
+		if data.Error != "" {
+			results = append(results, ReportResult{Result: fmt.Sprintf("ERR : %s randomness test through %s failed: %s", data.Name, c.s.resolver, data.Error),
+				Name: "Entropy"})
+			continue
+		}
+		msg := fmt.Sprintf("%s randomness of %s is %s: %v unique in %v queries, std dev %v (~%.1f bits)", data.Name, c.s.resolver, data.Rating, data.Unique, data.Queries, data.StdDev, data.Bits)
+		if data.Rating == "GREAT" || data.Rating == "GOOD" {
+			results = append(results, ReportResult{Result: "OK  : " + msg, Status: true, Name: "Entropy"})
+		} else {
+			results = append(results, ReportResult{Result: "FAIL: " + msg + ". The resolver or a NAT device makes spoofing easier.",
+				Status: false, Name: "Entropy", Remediation: "Use a resolver that randomizes source ports and query IDs, and check NAT devices don't rewrite ports."})
+		}
+	}
+	return results
+}
+
+func (c *EntropyCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "Resolver"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
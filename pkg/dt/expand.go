@@ -0,0 +1,113 @@
+package dt
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// Target is a zone of an expanded scan, with the common hosts that exist in
+// it.
+type Target struct {
+	Domain string
+	Depth  int
+	Hosts  []string
+}
+
+// expandHosts returns the host labels probed in every zone of an expanded
+// scan, the names of the A entries of the domain scan.
+func expandHosts() []string {
+	var labels []string
+	for _, entry := range DSP {
+		if entry.Qtype != dns.TypeA {
+			continue
+		}
+		for _, e := range entry.Entries {
+			if label := strings.TrimSuffix(e, "."); label != "" && !strings.HasPrefix(label, "_") {
+				labels = append(labels, label)
+			}
+		}
+	}
+	return labels
+}
+
+// findHosts returns the labels of expandHosts that resolve in zone. Zones
+// with a wildcard have none, every label would.
+func (s *session) findHosts(zone string) []string {
+	exists := func(label string) bool {
+		res, err := s.query(label+"."+zone, dns.TypeA, s.resolver, false)
+		return err == nil && len(extractRR(res.Msg.Answer, dns.TypeA, dns.TypeCNAME)) > 0
+	}
+	if exists(fmt.Sprintf("dt%v", dns.Id())) {
+		return nil
+	}
+	labels := expandHosts()
+	found := make([]bool, len(labels))
+	sem := make(chan struct{}, s.concurrency)
+	var wg sync.WaitGroup
+	for i, label := range labels {
+		if s.ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, label string) {
+			defer func() { <-sem; wg.Done() }()
+			found[i] = exists(label)
+		}(i, label)
+	}
+	wg.Wait()
+	seen := make(map[string]bool)
+	var hosts []string
+	for i, label := range labels {
+		if found[i] && !seen[label] {
+			seen[label] = true
+			hosts = append(hosts, label)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// Expand returns the zones to scan for domain (example.com or
+// *.example.com): the apex first, then its delegated subzones found in the
+// zone transfer or by walking NSEC, down to depth, at most limit zones (0 for
+// no limit). The common hosts existing in every zone are looked up as well.
+func Expand(domain string, depth, limit int, opts Options) ([]Target, error) {
+	s, err := newSession(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+	apex := strings.ToLower(dns.Fqdn(strings.TrimPrefix(domain, "*.")))
+	if _, err := s.findNS(apex); err != nil {
+		return nil, fmt.Errorf("%s is not a zone: %s", apex, err)
+	}
+	targets := []Target{{Domain: apex}}
+	// breadth first, targets grows while it is walked
+	for i := 0; i < len(targets); i++ {
+		zone, level := targets[i].Domain, targets[i].Depth
+		targets[i].Hosts = s.findHosts(zone)
+		if level >= depth {
+			continue
+		}
+		nsdatas, err := s.findNS(zone)
+		if err != nil || len(nsdatas) == 0 || len(nsdatas[0].IP) == 0 {
+			continue
+		}
+		subzones, source := s.findSubzones(zone, nsdatas, nsdatas[0].addr(nsdatas[0].IP[0]))
+		s.log.Debugf("%s has %v subzones (%s)", zone, len(subzones), source)
+		sort.Strings(subzones)
+		for _, subzone := range subzones {
+			if limit > 0 && len(targets) >= limit {
+				s.log.Debugf("expansion of %s stops at %v zones", apex, limit)
+				break
+			}
+			targets = append(targets, Target{Domain: subzone, Depth: level + 1})
+		}
+	}
+	return targets, nil
+}
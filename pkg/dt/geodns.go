@@ -0,0 +1,152 @@
+package dt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// geoRounds is the number of times every name is asked per nameserver IP.
+const geoRounds = 5
+
+type GeoDNSCheck struct {
+	NS  []NSData
+	Geo []GeoData
+	Report
+}
+
+type GeoData struct {
+	Name   string
+	Qtype  uint16
+	Server string
+	IP     string
+	Serial uint32
+	Sets   map[string]bool
+	MinTTL uint32
+}
+
+func (c *GeoDNSCheck) Scan(domain string) {
+	for _, name := range []string{domain, "www." + domain} {
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			for _, ns := range c.NS {
+				for _, nsip := range ns.IP {
+					data := GeoData{Name: name, Qtype: qtype, Server: ns.Name, IP: ns.addr(nsip), Sets: make(map[string]bool)}
+					if soa, _, err := c.s.queryRRset(domain, dns.TypeSOA, ns.addr(nsip), false); err == nil {
+						data.Serial = soa[0].(*dns.SOA).Serial
+					}
+					for i := 0; i < c.s.probeRounds(geoRounds); i++ {
+						rrset, _, err := c.s.queryRRset(name, qtype, ns.addr(nsip), false)
+						if err != nil {
+							continue
+						}
+						var ips []string
+						for _, ip := range extractIP(rrset) {
+							ips = append(ips, ip.String())
+						}
+						for _, rr := range rrset {
+							if data.MinTTL == 0 || rr.Header().Ttl < data.MinTTL {
+								data.MinTTL = rr.Header().Ttl
+							}
+						}
+						sort.Strings(ips)
+						data.Sets[strings.Join(ips, " ")] = true
+					}
+					if len(data.Sets) > 0 {
+						c.Geo = append(c.Geo, data)
+					}
+				}
+			}
+		}
+	}
+}
+
+func (c *GeoDNSCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	type key struct {
+		name  string
+		qtype uint16
+	}
+	var keys []key
+	m := make(map[key][]GeoData)
+	for _, data := range c.Geo {
+		k := key{data.Name, data.Qtype}
+		if _, ok := m[k]; !ok {
+			keys = append(keys, k)
+		}
+		m[k] = append(m[k], data)
+	}
+	for _, k := range keys {
+		datas := m[k]
+		label := fmt.Sprintf("%s %s", k.name, dns.TypeToString[k.qtype])
+		sets := make(map[string][]string)
+		serials := make(map[uint32]bool)
+		rotating := true
+		minTTL := uint32(0)
+		for _, data := range datas {
+			if len(data.Sets) < 2 {
+				rotating = false
+			}
+			for set := range data.Sets {
+				sets[set] = append(sets[set], data.IP)
+			}
+			serials[data.Serial] = true
+			if minTTL == 0 || data.MinTTL < minTTL {
+				minTTL = data.MinTTL
+			}
+		}
+		switch {
+		case len(sets) == 1:
+			results = append(results, ReportResult{Result: fmt.Sprintf("OK  : %s is identical on all nameservers", label),
+				Status: true, Name: "Variance"})
+		case rotating || setsOverlap(sets):
+			results = append(results, ReportResult{Result: fmt.Sprintf("OK  : %s varies per query or nameserver (%v answer sets, TTL %s): GeoDNS/GSLB", label, len(sets), FormatTTL(minTTL)),
+				Status: true, Name: "Variance"})
+		default:
+			res := ReportResult{Result: fmt.Sprintf("FAIL: %s is inconsistent between nameservers", label),
+				Status: false, Name: "Variance", Remediation: "Make sure all nameservers serve the same zone version, check the zone transfers to the secondaries."}
+			if len(serials) > 1 {
+				res.Result += " (SOA serials differ)"
+			}
+			var answers []string
+			for set, ips := range sets {
+				answers = append(answers, fmt.Sprintf("\n\t %v\n\t %s", ips, set))
+			}
+			sort.Strings(answers)
+			res.Result += strings.Join(answers, "")
+			results = append(results, res)
+		}
+	}
+	return results
+}
+
+// setsOverlap returns whether every two answer sets share an address, as the
+// answers of a GSLB picking from one pool do.
+func setsOverlap(sets map[string][]string) bool {
+	var all [][]string
+	for set := range sets {
+		all = append(all, strings.Fields(set))
+	}
+	for i := range all {
+		for j := i + 1; j < len(all); j++ {
+			shared := false
+			for _, a := range all[i] {
+				for _, b := range all[j] {
+					shared = shared || a == b
+				}
+			}
+			if !shared {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (c *GeoDNSCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "GeoDNS"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
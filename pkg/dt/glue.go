@@ -0,0 +1,284 @@
+package dt
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+type Glue struct {
+	NS []NSData
+	// Zone is the parent zone, Servers the number of its servers that
+	// returned a referral.
+	Zone    string
+	Servers int
+	Data    []GlueData
+	Err     string
+	Report
+}
+
+// GlueData compares the glue of a nameserver at the parent with the
+// addresses the nameserver has in the zone serving it.
+type GlueData struct {
+	Name string
+	// Parent maps every glue address to the parent servers serving it.
+	Parent map[string][]string
+	Child  []string
+	// InZone is set for nameservers below the domain, they can't be resolved
+	// without glue.
+	InZone bool
+	Error  string
+}
+
+// Scan asks every server of the parent zone for the delegation of domain
+// and looks up the glued nameservers where they are authoritative: the
+// child zone for nameservers below the domain, the resolver for siblings.
+func (g *Glue) Scan(domain string) {
+	domain = dns.Fqdn(domain)
+	g.Zone = g.s.parentZone(domain)
+	parent, err := g.s.findNS(g.Zone)
+	if err != nil {
+		g.Err = err.Error()
+		return
+	}
+	data := make(map[string]*GlueData)
+	var mu sync.Mutex
+	g.s.eachServer(parent, &g.Report, func(i int, ns NSData, nsip net.IP, r *Report) {
+		res, err := g.s.query(domain, dns.TypeNS, ns.addr(nsip), true)
+		if err != nil {
+			r.Result = append(r.Result, ReportResult{Result: fmt.Sprintf("ERR : Glue lookup failed on %s (%s): %s", ns.Name, nsip, err),
+				Name: "Glue", Error: err.Error(), Server: fmt.Sprintf("%s (%s)", ns.Name, nsip), Unreachable: true})
+			return
+		}
+		names := extractRR(append(res.Msg.Ns, res.Msg.Answer...), dns.TypeNS)
+		if len(names) == 0 {
+			return
+		}
+		server := fmt.Sprintf("%s (%s)", ns.Name, nsip)
+		mu.Lock()
+		defer mu.Unlock()
+		g.Servers++
+		for _, rr := range names {
+			name := strings.ToLower(rr.(*dns.NS).Ns)
+			if data[name] == nil {
+				data[name] = &GlueData{Name: name, Parent: make(map[string][]string), InZone: dns.IsSubDomain(domain, name)}
+			}
+		}
+		for _, rr := range extractRR(res.Msg.Extra, dns.TypeA, dns.TypeAAAA) {
+			if d := data[strings.ToLower(rr.Header().Name)]; d != nil {
+				ip := extractIP([]dns.RR{rr})[0].String()
+				d.Parent[ip] = append(d.Parent[ip], server)
+			}
+		}
+	})
+	child, ok := g.s.respondingServer(g.NS, domain)
+	for _, d := range data {
+		// glue outside the parent zone is ignored by resolvers
+		if !d.InZone && (len(d.Parent) == 0 || !dns.IsSubDomain(g.Zone, d.Name)) {
+			continue
+		}
+		var ips []net.IP
+		switch {
+		case d.InZone && !ok:
+			d.Error = fmt.Sprintf("no nameserver of %s answers", domain)
+		case d.InZone:
+			for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+				rrs, _, err := g.s.queryRRset(d.Name, qtype, child, false)
+				if err == nil {
+					ips = append(ips, extractIP(rrs)...)
+				}
+			}
+		default:
+			ips = append(g.s.getIP(d.Name, dns.TypeA, g.s.resolver), g.s.getIP(d.Name, dns.TypeAAAA, g.s.resolver)...)
+		}
+		for _, ip := range ips {
+			d.Child = append(d.Child, ip.String())
+		}
+		if len(ips) == 0 && d.Error == "" {
+			d.Error = "it has no A or AAAA records"
+		}
+		sort.Strings(d.Child)
+		g.Data = append(g.Data, *d)
+	}
+	sort.Slice(g.Data, func(i, j int) bool { return g.Data[i].Name < g.Data[j].Name })
+}
+
+// Values reports per nameserver the glue at the parent that isn't an
+// address of the nameserver (stale), the addresses without glue (missing)
+// and glue served by only some of the parent servers.
+func (g *Glue) Values() []ReportResult {
+	results := []ReportResult{}
+	if g.Err != "" {
+		return append(results, ReportResult{Result: fmt.Sprintf("ERR : Finding nameservers of %s failed: %s", g.Zone, g.Err),
+			Name: "Glue", Error: g.Err})
+	}
+	for _, d := range g.Data {
+		if d.Error != "" {
+			results = append(results, ReportResult{Result: fmt.Sprintf("ERR : Can't compare the glue of %s, %s", d.Name, d.Error),
+				Status: false, Name: "Glue", Error: d.Error})
+			continue
+		}
+		child := make(map[string]bool)
+		for _, ip := range d.Child {
+			child[ip] = true
+		}
+		var glue, stale, missing, partial []string
+		for ip, servers := range d.Parent {
+			glue = append(glue, ip)
+			if !child[ip] {
+				stale = append(stale, ip)
+			}
+			if len(servers) < g.Servers {
+				partial = append(partial, fmt.Sprintf("%s (%v of %v servers)", ip, len(servers), g.Servers))
+			}
+		}
+		for _, ip := range d.Child {
+			if _, ok := d.Parent[ip]; !ok {
+				missing = append(missing, ip)
+			}
+		}
+		sort.Strings(glue)
+		sort.Strings(stale)
+		sort.Strings(partial)
+		switch {
+		case len(stale) > 0:
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: Stale glue for %s at %s: %s, the nameserver has %s",
+				d.Name, g.Zone, strings.Join(stale, ", "), strings.Join(d.Child, ", ")),
+				Status: false, Name: "Glue", Remediation: "Update the glue (host records) of the nameserver at your registrar to its current addresses."})
+		case d.InZone && len(glue) == 0:
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: No glue for %s at %s, it is inside the zone it serves and can't be resolved without",
+				d.Name, g.Zone),
+				Status: false, Name: "Glue", Remediation: "Register glue (host records) for in-bailiwick nameservers at your registrar."})
+		case len(missing) > 0:
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: Glue for %s at %s lacks %s",
+				d.Name, g.Zone, strings.Join(missing, ", ")),
+				Status: false, Name: "Glue", Remediation: "Register all addresses (A and AAAA) of the nameserver as glue at your registrar."})
+		}
+		if len(partial) > 0 {
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: Glue for %s differs between the servers of %s, only some serve %s",
+				d.Name, g.Zone, strings.Join(partial, ", ")),
+				Status: false, Name: "Glue", Remediation: "Wait for the registry to publish the change on all its servers, or contact it when this persists."})
+		}
+	}
+	if len(results) == 0 && len(g.Data) > 0 {
+		results = append(results, ReportResult{Result: fmt.Sprintf("OK  : Glue at %s matches the addresses of all %v glued nameservers", g.Zone, len(g.Data)),
+			Status: true, Name: "Glue"})
+	}
+	return results
+}
+
+func (g *Glue) CheckParent(domain string) (bool, []string, error) {
+	parentGlue, err := g.s.getParentGlue(domain)
+	if err != nil {
+		return false, []string{}, err
+	}
+	ok, res := g.Compare(parentGlue)
+	return ok, res, nil
+}
+
+func (g *Glue) CheckSelf(domain string) (bool, []string, error) {
+	selfGlue, err := g.getSelfGlue(domain)
+	if err != nil {
+		return false, []string{}, err
+	}
+	ok, res := g.Compare(selfGlue)
+	return ok, res, nil
+}
+
+func (g *Glue) CreateReport(domain string) Report {
+	res := ReportResult{}
+	rep := Report{}
+	var missed []string
+	var err error
+	res.Status, missed, err = g.CheckParent(domain)
+	if err != nil {
+		res.Error = err.Error()
+	}
+	if !res.Status {
+		res.Result = fmt.Sprintf("WARN: no glue records found for %s in NS of parent %s", missed, g.s.parentZone(domain))
+		res.Name = "ParentNS"
+		res.Remediation = "Register glue (host records) for in-bailiwick nameservers at your registrar."
+	}
+	if res.Error != "" {
+		res.Result = fmt.Sprintf("ERR : CheckParentGlue test failed: %s", res.Error)
+	}
+	rep.Result = append(rep.Result, res)
+	res = ReportResult{Result: fmt.Sprintf("OK  : glue records found for all nameservers in NS record of %s", dns.Fqdn(domain))}
+	res.Status, missed, err = g.CheckSelf(domain)
+	if !res.Status {
+		res.Result = fmt.Sprintf("WARN: no glue records found for %s in NS of %s", missed, dns.Fqdn(domain))
+		res.Name = "OwnNS"
+		res.Remediation = "Add A/AAAA records for the nameservers to your zone."
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	if res.Error != "" {
+		res.Result = fmt.Sprintf("ERR : CheckSelfGlue test failed: %s", res.Error)
+	}
+	rep.Result = append(rep.Result, res)
+	g.Scan(domain)
+	rep.Result = append(rep.Result, g.Report.Result...)
+	rep.Result = append(rep.Result, g.Values()...)
+	rep.Type = "GLUE"
+	g.Report = rep
+	return rep
+}
+
+func (g *Glue) Compare(parentGlue []net.IP) (bool, []string) {
+	var NSips []net.IP
+	for _, data := range g.NS {
+		NSips = append(NSips, data.IP...)
+	}
+
+	m := make(map[string]bool)
+	var ips []string
+	for _, ip := range NSips {
+		m[ip.String()] = false
+	}
+	for _, ip := range parentGlue {
+		m[ip.String()] = true
+	}
+	for k, v := range m {
+		if v == false {
+			ips = append(ips, k)
+		}
+	}
+	if len(ips) == 0 {
+		return true, ips
+	}
+	return false, ips
+}
+
+func (s *session) getParentGlue(domain string) ([]net.IP, error) {
+	// TODO ask every parent
+	s.log.Debugf("Finding NS of parent: %s", s.parentZone(domain))
+	var ips []net.IP
+	nsdata, err := s.findNS(s.parentZone(domain))
+	if err != nil {
+		return ips, err
+	}
+	// asking parent about NS
+	s.log.Debugf("Asking parent %s (%s) NS of %s", nsdata[0].Info[0].IP.String(), s.parentZone(domain), domain)
+	return s.getGlueIPs(domain, nsdata[0].Info[0].IP.String())
+}
+
+func (g *Glue) getSelfGlue(domain string) ([]net.IP, error) {
+	// TODO all NS
+	g.s.log.Debugf("Asking self %s (%s) NS of %s", g.NS[0].addr(g.NS[0].IP[0]), domain, domain)
+	return g.s.getGlueIPs(domain, g.NS[0].addr(g.NS[0].IP[0]))
+}
+
+func (s *session) getGlueIPs(domain string, server string) ([]net.IP, error) {
+	var ips []net.IP
+	res, err := s.query(domain, dns.TypeNS, server, true)
+	if err != nil {
+		return ips, err
+	}
+	rrset := extractRR(res.Msg.Extra, dns.TypeA, dns.TypeAAAA)
+	return extractIP(rrset), nil
+}
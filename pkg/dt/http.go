@@ -0,0 +1,183 @@
+package dt
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type HTTPCheck struct {
+	NS   []NSData
+	HTTP []HTTPData
+	Report
+}
+
+type HTTPData struct {
+	Host      string
+	IP        []net.IP
+	URL       string
+	Final     string
+	Status    int
+	Redirects int
+	Error     string
+}
+
+// httpClient returns a client that resolves hostnames with our resolver instead
+// of the system one, so the results match the DNS answers we report on.
+func (s *session) httpClient(redirects *int) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			Dial: func(network, addr string) (net.Conn, error) {
+				host, port, err := net.SplitHostPort(addr)
+				if err != nil {
+					return nil, err
+				}
+				ips := s.resolveHost(host)
+				if len(ips) == 0 {
+					return nil, fmt.Errorf("%s does not resolve", host)
+				}
+				return dialer.Dial(network, net.JoinHostPort(ips[0].String(), port))
+			},
+			TLSHandshakeTimeout: 5 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			*redirects = len(via)
+			return nil
+		},
+	}
+}
+
+func (s *session) resolveHost(host string) []net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}
+	}
+	var ips []net.IP
+	ips = append(ips, s.getIP(host, dns.TypeA, s.resolver)...)
+	ips = append(ips, s.getIP(host, dns.TypeAAAA, s.resolver)...)
+	return ips
+}
+
+func (c *HTTPCheck) Scan(domain string) {
+	apex := strings.TrimSuffix(dns.Fqdn(domain), ".")
+	for _, host := range []string{apex, "www." + apex} {
+		ips := c.s.resolveHost(host)
+		for _, scheme := range []string{"http", "https"} {
+			data := HTTPData{Host: host, IP: ips, URL: scheme + "://" + host + "/"}
+			if len(ips) == 0 {
+				data.Error = "no A/AAAA records"
+				c.HTTP = append(c.HTTP, data)
+				continue
+			}
+			resp, err := c.s.httpClient(&data.Redirects).Get(data.URL)
+			if err != nil {
+				data.Error = err.Error()
+			} else {
+				data.Status = resp.StatusCode
+				data.Final = resp.Request.URL.String()
+				resp.Body.Close()
+			}
+			c.HTTP = append(c.HTTP, data)
+		}
+	}
+}
+
+func (c *HTTPCheck) resolves(host string) bool {
+	for _, data := range c.HTTP {
+		if data.Host == host && len(data.IP) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *HTTPCheck) CheckResolve(domain string) []ReportResult {
+	apex := strings.TrimSuffix(dns.Fqdn(domain), ".")
+	www := "www." + apex
+	rep := []ReportResult{}
+	switch {
+	case c.resolves(www) && !c.resolves(apex):
+		rep = append(rep, ReportResult{Result: fmt.Sprintf("WARN: %s resolves but %s does not", www, apex),
+			Status: false, Name: "Resolve", Remediation: "Add A/AAAA records for the name that doesn't resolve so both serve the website."})
+	case !c.resolves(www) && c.resolves(apex):
+		rep = append(rep, ReportResult{Result: fmt.Sprintf("WARN: %s resolves but %s does not", apex, www),
+			Status: false, Name: "Resolve", Remediation: "Add A/AAAA records for the name that doesn't resolve so both serve the website."})
+	case !c.resolves(www) && !c.resolves(apex):
+		rep = append(rep, ReportResult{Result: fmt.Sprintf("WARN: Neither %s nor %s resolve", apex, www),
+			Status: false, Name: "Resolve", Remediation: "Add A/AAAA records at the apex and for www pointing at your website."})
+	default:
+		rep = append(rep, ReportResult{Result: fmt.Sprintf("OK  : Both %s and %s resolve", apex, www),
+			Status: true, Name: "Resolve"})
+	}
+	return rep
+}
+
+func (c *HTTPCheck) CheckReachable() []ReportResult {
+	rep := []ReportResult{}
+	for _, data := range c.HTTP {
+		if len(data.IP) == 0 {
+			continue
+		}
+		if data.Error != "" {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: %s is not reachable: %s", data.URL, data.Error),
+				Status: false, Name: "Reachable", Remediation: "Make sure the web server is running and reachable on the addresses the name resolves to."})
+			continue
+		}
+		if data.Status >= 400 {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("WARN: %s returned HTTP %d", data.URL, data.Status),
+				Status: false, Name: "Reachable", Remediation: "Fix the web server configuration so the URL answers with a success or redirect status."})
+			continue
+		}
+		result := fmt.Sprintf("OK  : %s is reachable (HTTP %d)", data.URL, data.Status)
+		if data.Final != data.URL {
+			result = fmt.Sprintf("OK  : %s is reachable (HTTP %d after %d redirect(s) to %s)", data.URL, data.Status, data.Redirects, data.Final)
+		}
+		rep = append(rep, ReportResult{Result: result, Status: true, Name: "Reachable"})
+	}
+	return rep
+}
+
+// CheckCertApex verifies the certificate served by the apex and www hosts also
+// covers the apex name, which breaks https://domain when it doesn't.
+func (c *HTTPCheck) CheckCertApex(domain string) []ReportResult {
+	apex := strings.TrimSuffix(dns.Fqdn(domain), ".")
+	rep := []ReportResult{}
+	checked := false
+	for _, host := range []string{apex, "www." + apex} {
+		ips := c.s.resolveHost(host)
+		if len(ips) == 0 {
+			continue
+		}
+		certs, err := fetchCerts(host, ips[0])
+		if err != nil {
+			continue
+		}
+		checked = true
+		if err := certs[0].VerifyHostname(apex); err != nil {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: HTTPS certificate served by %s does not cover %s", host, apex),
+				Status: false, Name: "CertApex", Remediation: "Serve a certificate that covers the apex as well as www."})
+		}
+	}
+	if len(rep) == 0 && checked {
+		rep = append(rep, ReportResult{Result: fmt.Sprintf("OK  : HTTPS certificates cover %s", apex),
+			Status: true, Name: "CertApex"})
+	}
+	return rep
+}
+
+func (c *HTTPCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "HTTP"
+	c.Report.Result = append(c.Report.Result, c.CheckResolve(domain)...)
+	c.Report.Result = append(c.Report.Result, c.CheckReachable()...)
+	c.Report.Result = append(c.Report.Result, c.CheckCertApex(domain)...)
+	return c.Report
+}
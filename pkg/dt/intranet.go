@@ -0,0 +1,64 @@
+package dt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/miekg/dns"
+)
+
+// errIntranet is returned instead of calling a public service in intranet
+// mode.
+var errIntranet = errors.New("intranet mode, public services are not contacted")
+
+// loadTrustAnchors returns the root trust anchors in file, DS records or
+// DNSKEY records with the SEP flag, which are turned into SHA-256 DS records.
+func loadTrustAnchors(file string) ([]*dns.DS, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var anchors []*dns.DS
+	for t := range dns.ParseZone(f, ".", file) {
+		if t.Error != nil {
+			return nil, t.Error
+		}
+		switch rr := t.RR.(type) {
+		case *dns.DS:
+			anchors = append(anchors, rr)
+		case *dns.DNSKEY:
+			if rr.Flags&dns.SEP != 0 {
+				anchors = append(anchors, rr.ToDS(dns.SHA256))
+			}
+		}
+	}
+	if len(anchors) == 0 {
+		return nil, fmt.Errorf("no DS or DNSKEY (SEP) records found in %s", file)
+	}
+	return anchors, nil
+}
+
+// intranetReport notes what intranet mode suppresses because it needs the
+// public Internet.
+func intranetReport(opts Options) Report {
+	report := Report{Type: "Intranet"}
+	skip := func(msg string) {
+		report.Result = append(report.Result, ReportResult{Result: "SKIP: " + msg, Status: true, Name: "Intranet"})
+	}
+	skip("Origin lookup (AS, country and ISP) of the nameserver addresses, it queries a public service")
+	if opts.RootHints == "" {
+		skip("Root priming, the bundled hints are the public root servers (use -roothints for the internal root)")
+	}
+	if opts.TrustAnchors == "" {
+		skip("DNSSEC chain of trust, the IANA trust anchors don't sign an internal root (use -trustanchor)")
+	}
+	if opts.CT {
+		skip("Certificate Transparency lookups, crt.sh is public")
+	}
+	if opts.ProviderStatus {
+		skip("DNS provider status pages, they are public")
+	}
+	return report
+}
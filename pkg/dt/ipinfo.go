@@ -0,0 +1,108 @@
+package dt
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/42wim/ipisp"
+)
+
+const (
+	// ipinfoConcurrency is the maximum number of origin lookups in flight.
+	ipinfoConcurrency = 4
+	// ipinfoQPS is the maximum number of origin lookups per second.
+	ipinfoQPS = 20
+)
+
+var (
+	ispClient     ipisp.Client
+	ispClientErr  error
+	ispClientOnce sync.Once
+)
+
+// originCache holds the origin lookups of a session and limits their rate.
+type originCache struct {
+	sync.Mutex
+	infos map[string]IPInfo
+	slots chan struct{}
+	// next is when the next lookup may start.
+	next time.Time
+}
+
+// newOriginCache returns an empty cache.
+func newOriginCache() *originCache {
+	return &originCache{infos: make(map[string]IPInfo), slots: make(chan struct{}, ipinfoConcurrency)}
+}
+
+// wait blocks until a lookup may start, at most ipinfoQPS a second.
+func (c *originCache) wait() {
+	c.Lock()
+	now := time.Now()
+	if c.next.Before(now) {
+		c.next = now
+	}
+	delay := c.next.Sub(now)
+	c.next = c.next.Add(time.Second / ipinfoQPS)
+	c.Unlock()
+	time.Sleep(delay)
+}
+
+// ipinfo returns the origin (country, AS and ISP) of ip. Lookups share one
+// client, are rate limited and cached for the session.
+func (s *session) ipinfo(ip net.IP) (IPInfo, error) {
+	s.origins.Lock()
+	info, ok := s.origins.infos[ip.String()]
+	s.origins.Unlock()
+	if ok {
+		return info, nil
+	}
+	if s.intranet {
+		return IPInfo{}, errIntranet
+	}
+	ispClientOnce.Do(func() {
+		ispClient, ispClientErr = ipisp.NewDNSClient()
+	})
+	if ispClientErr != nil {
+		return IPInfo{}, ispClientErr
+	}
+	s.origins.slots <- struct{}{}
+	s.origins.wait()
+	resp, err := ispClient.LookupIP(ip)
+	<-s.origins.slots
+	if err != nil {
+		return IPInfo{}, err
+	}
+	info = IPInfo{ip, resp.Country, resp.ASN, resp.Name.Raw}
+	s.origins.Lock()
+	s.origins.infos[ip.String()] = info
+	s.origins.Unlock()
+	return info, nil
+}
+
+// ipinfos looks up the origin of all ips at once. It returns the infos found
+// and the first error.
+func (s *session) ipinfos(ips []net.IP) (map[string]IPInfo, error) {
+	infos := make(map[string]IPInfo)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var first error
+	for _, ip := range ips {
+		wg.Add(1)
+		go func(ip net.IP) {
+			defer wg.Done()
+			info, err := s.ipinfo(ip)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if first == nil {
+					first = err
+				}
+				return
+			}
+			infos[ip.String()] = info
+		}(ip)
+	}
+	wg.Wait()
+	return infos, first
+}
@@ -0,0 +1,129 @@
+package dt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/miekg/dns"
+)
+
+type LoadResult struct {
+	Name   string
+	IP     string
+	Sent   int
+	Errors int
+	Rtt    []time.Duration
+}
+
+// percentile returns the p-th percentile of the sorted durations.
+func percentile(rtts []time.Duration, p int) time.Duration {
+	if len(rtts) == 0 {
+		return 0
+	}
+	return rtts[(len(rtts)-1)*p/100]
+}
+
+// loadServer sends queries for qtypes in turn to server at qps for duration.
+func (s *session) loadServer(domain string, qtypes []uint16, server string, qps int, duration time.Duration) LoadResult {
+	res := LoadResult{IP: server}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	ticker := time.NewTicker(time.Second / time.Duration(qps))
+	defer ticker.Stop()
+	end := time.After(duration)
+loop:
+	for i := 0; ; i++ {
+		select {
+		case <-end:
+			break loop
+		case <-ticker.C:
+		}
+		res.Sent++
+		wg.Add(1)
+		go func(qtype uint16) {
+			defer wg.Done()
+			r, err := s.query(domain, qtype, server, false)
+			mu.Lock()
+			if err != nil {
+				res.Errors++
+			} else {
+				res.Rtt = append(res.Rtt, r.Rtt)
+			}
+			mu.Unlock()
+		}(qtypes[i%len(qtypes)])
+	}
+	wg.Wait()
+	sort.Slice(res.Rtt, func(i, j int) bool { return res.Rtt[i] < res.Rtt[j] })
+	return res
+}
+
+// LoadTest sends queries of types to the nameservers of domain for duration
+// and prints the latency and loss per nameserver.
+func LoadTest(domain string, types string, duration time.Duration, opts Options) error {
+	s, err := newSession(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	var qtypes []uint16
+	for _, t := range strings.Split(types, ",") {
+		qtype, ok := dns.StringToType[strings.ToUpper(strings.TrimSpace(t))]
+		if !ok {
+			return fmt.Errorf("unknown query type %s", t)
+		}
+		qtypes = append(qtypes, qtype)
+	}
+	nsdatas, err := s.findNS(dns.Fqdn(domain))
+	if err != nil {
+		return err
+	}
+
+	spin := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	spin.Suffix = fmt.Sprintf(" Sending %v qps per nameserver for %v...", s.qps, duration)
+	if !s.debug {
+		spin.Start()
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var results []LoadResult
+	for _, ns := range nsdatas {
+		for _, ip := range ns.IP {
+			wg.Add(1)
+			go func(name, server string) {
+				res := s.loadServer(domain, qtypes, server, s.qps, duration)
+				res.Name = name
+				mu.Lock()
+				results = append(results, res)
+				mu.Unlock()
+				wg.Done()
+			}(ns.Name, ns.addr(ip))
+		}
+	}
+	wg.Wait()
+	spin.Stop()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Name != results[j].Name {
+			return results[i].Name < results[j].Name
+		}
+		return results[i].IP < results[j].IP
+	})
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+	fmt.Fprintf(w, "NS\tIP\tSent\tQPS\tErrors\tp50\tp90\tp99\n")
+	for _, r := range results {
+		errRate := 0.0
+		if r.Sent > 0 {
+			errRate = float64(r.Errors) * 100 / float64(r.Sent)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%v\t%.1f\t%v (%.1f%%)\t%v\t%v\t%v\n", r.Name, r.IP, r.Sent, float64(len(r.Rtt))/duration.Seconds(),
+			r.Errors, errRate, percentile(r.Rtt, 50), percentile(r.Rtt, 90), percentile(r.Rtt, 99))
+	}
+	w.Flush()
+	return nil
+}
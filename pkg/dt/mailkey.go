@@ -0,0 +1,308 @@
+package dt
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// mailKeyUsers are common local parts probed for OPENPGPKEY and SMIMEA
+// records.
+var mailKeyUsers = []string{
+	"postmaster", "hostmaster", "webmaster", "abuse", "security", "admin", "info", "contact",
+	"support", "noc", "mail", "office", "sales",
+}
+
+// mailKeyTypes are the per address key types and the label their records
+// are published under.
+var mailKeyTypes = []struct {
+	Type  uint16
+	Label string
+}{
+	{dns.TypeOPENPGPKEY, "_openpgpkey"},
+	{dns.TypeSMIMEA, "_smimecert"},
+}
+
+// MailKeyCheck looks for the OPENPGPKEY (RFC 7929) and SMIMEA (RFC 8162)
+// records of common addresses and the users given, validates them and
+// checks they are DNSSEC validated, without which clients don't use them.
+type MailKeyCheck struct {
+	NS    []NSData
+	Users []string
+	// Wildcard is set when a random user has records, so the common users
+	// can't be told apart from missing ones.
+	Wildcard bool
+	// Exists are the _openpgpkey and _smimecert names that exist, so
+	// records may be published for users that weren't probed.
+	Exists []string
+	Keys   []MailKeyData
+	Report
+}
+
+// MailKeyData are the records of one type published for User.
+type MailKeyData struct {
+	User string
+	Name string
+	RR   []dns.RR
+	// Secure is set when the resolver validated the records.
+	Secure bool
+}
+
+// mailKeyName returns the owner name of the records of user@domain under
+// label: the first 28 octets of the SHA-256 of the local part in hex.
+func mailKeyName(user, label, domain string) string {
+	sum := sha256.Sum256([]byte(user))
+	return hex.EncodeToString(sum[:28]) + "." + label + "." + dns.Fqdn(domain)
+}
+
+// mailKeyLookup returns the records of qtype of user.
+func (s *session) mailKeyLookup(user string, qtype uint16, label, domain string) MailKeyData {
+	data := MailKeyData{User: user, Name: mailKeyName(user, label, domain)}
+	res, err := s.query(data.Name, qtype, s.resolver, true)
+	if err == nil {
+		data.RR = extractRR(res.Msg.Answer, qtype)
+		data.Secure = res.Msg.AuthenticatedData
+	}
+	return data
+}
+
+// openpgpPackets returns the packet tags of an OpenPGP transferable public
+// key (RFC 4880 section 4.2), with the body of every user ID packet.
+func openpgpPackets(key []byte) (tags []int, uids []string, err error) {
+	for len(key) > 0 {
+		b0 := key[0]
+		if b0&0x80 == 0 {
+			return nil, nil, fmt.Errorf("invalid packet header %#x", b0)
+		}
+		var tag, hdr, length int
+		if b0&0x40 != 0 {
+			tag = int(b0 & 0x3f)
+			switch {
+			case len(key) < 2:
+				return nil, nil, fmt.Errorf("truncated packet header")
+			case key[1] < 192:
+				hdr, length = 2, int(key[1])
+			case key[1] < 224:
+				if len(key) < 3 {
+					return nil, nil, fmt.Errorf("truncated packet header")
+				}
+				hdr, length = 3, (int(key[1])-192)<<8+int(key[2])+192
+			case key[1] == 255:
+				if len(key) < 6 {
+					return nil, nil, fmt.Errorf("truncated packet header")
+				}
+				hdr, length = 6, int(key[2])<<24|int(key[3])<<16|int(key[4])<<8|int(key[5])
+			default:
+				return nil, nil, fmt.Errorf("partial body length in packet %v", tag)
+			}
+		} else {
+			tag = int(b0>>2) & 0xf
+			switch b0 & 3 {
+			case 0:
+				hdr = 2
+			case 1:
+				hdr = 3
+			case 2:
+				hdr = 5
+			default:
+				return nil, nil, fmt.Errorf("indeterminate length in packet %v", tag)
+			}
+			if len(key) < hdr {
+				return nil, nil, fmt.Errorf("truncated packet header")
+			}
+			for _, b := range key[1:hdr] {
+				length = length<<8 | int(b)
+			}
+		}
+		if length < 0 || len(key)-hdr < length {
+			return nil, nil, fmt.Errorf("packet %v is truncated", tag)
+		}
+		tags = append(tags, tag)
+		if tag == 13 {
+			uids = append(uids, string(key[hdr:hdr+length]))
+		}
+		key = key[hdr+length:]
+	}
+	return tags, uids, nil
+}
+
+// openpgpProblem returns what is wrong with the key of address, or "".
+func openpgpProblem(rr *dns.OPENPGPKEY, address string) string {
+	key, err := base64.StdEncoding.DecodeString(rr.PublicKey)
+	if err != nil {
+		return "the key is not valid base64"
+	}
+	tags, uids, err := openpgpPackets(key)
+	switch {
+	case err != nil:
+		return fmt.Sprintf("the key is not an OpenPGP key: %s", err)
+	case len(tags) == 0 || tags[0] != 6:
+		if len(tags) > 0 && tags[0] == 5 {
+			return "the record holds a SECRET key"
+		}
+		return "the key doesn't start with a public key packet"
+	}
+	for _, uid := range uids {
+		if strings.Contains(strings.ToLower(uid), strings.ToLower(address)) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("the key has no user ID for %s", address)
+}
+
+// smimeaProblem returns what is wrong with the SMIMEA record of address, or
+// "".
+func (s *session) smimeaProblem(rr *dns.SMIMEA, address string) string {
+	switch {
+	case rr.Usage > 3:
+		return fmt.Sprintf("unknown usage %v", rr.Usage)
+	case rr.Selector > 1:
+		return fmt.Sprintf("unknown selector %v", rr.Selector)
+	case rr.MatchingType > 2:
+		return fmt.Sprintf("unknown matching type %v", rr.MatchingType)
+	case rr.MatchingType == 1 && len(rr.Certificate) != 2*sha256.Size:
+		return fmt.Sprintf("SHA-256 digest of %v hex digits instead of %v", len(rr.Certificate), 2*sha256.Size)
+	case rr.MatchingType == 2 && len(rr.Certificate) != 2*sha512.Size:
+		return fmt.Sprintf("SHA-512 digest of %v hex digits instead of %v", len(rr.Certificate), 2*sha512.Size)
+	case rr.MatchingType != 0:
+		return ""
+	}
+	der, err := hex.DecodeString(rr.Certificate)
+	if err != nil {
+		return "the certificate data is not hex"
+	}
+	if rr.Selector == 1 {
+		if _, err := x509.ParsePKIXPublicKey(der); err != nil {
+			return fmt.Sprintf("the public key doesn't parse: %s", err)
+		}
+		return ""
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Sprintf("the certificate doesn't parse: %s", err)
+	}
+	if time.Now().After(cert.NotAfter) {
+		return fmt.Sprintf("the certificate expired %s", FormatWhen(cert.NotAfter, s.useUTC))
+	}
+	if rr.Usage == 1 || rr.Usage == 3 {
+		for _, email := range cert.EmailAddresses {
+			if strings.EqualFold(email, address) {
+				return ""
+			}
+		}
+		return fmt.Sprintf("the certificate isn't issued to %s", address)
+	}
+	return ""
+}
+
+func (c *MailKeyCheck) Scan(domain string) {
+	apex := dns.Fqdn(domain)
+	for _, t := range mailKeyTypes {
+		if _, err := c.s.query(t.Label+"."+apex, dns.TypeTXT, c.s.resolver, false); err == nil {
+			c.Exists = append(c.Exists, t.Label+"."+apex)
+		}
+	}
+	users := c.Users
+	random := fmt.Sprintf("dt%v", dns.Id())
+	for _, t := range mailKeyTypes {
+		if len(c.s.mailKeyLookup(random, t.Type, t.Label, domain).RR) > 0 {
+			c.Wildcard = true
+		}
+	}
+	if !c.Wildcard {
+		users = append(users, mailKeyUsers...)
+	}
+	seen := make(map[string]bool)
+	var unique []string
+	for _, user := range users {
+		// addresses are accepted, the records are per local part
+		if i := strings.LastIndex(user, "@"); i >= 0 {
+			user = user[:i]
+		}
+		if !seen[user] {
+			seen[user] = true
+			unique = append(unique, user)
+		}
+	}
+	found := make([]MailKeyData, len(unique)*len(mailKeyTypes))
+	sem := make(chan struct{}, c.s.concurrency)
+	var wg sync.WaitGroup
+	for i, user := range unique {
+		for j, t := range mailKeyTypes {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(n int, user string, qtype uint16, label string) {
+				defer func() { <-sem; wg.Done() }()
+				found[n] = c.s.mailKeyLookup(user, qtype, label, domain)
+			}(i*len(mailKeyTypes)+j, user, t.Type, t.Label)
+		}
+	}
+	wg.Wait()
+	for _, data := range found {
+		if len(data.RR) > 0 {
+			c.Keys = append(c.Keys, data)
+		}
+	}
+}
+
+func (c *MailKeyCheck) Values(domain string) []ReportResult {
+	results := []ReportResult{}
+	if c.Wildcard {
+		results = append(results, ReportResult{Result: "SKIP: A wildcard gives every address OPENPGPKEY or SMIMEA records, only the users given are checked (use -mail-keys)",
+			Status: true, Name: "MailKeys"})
+	}
+	if len(c.Keys) == 0 {
+		if len(c.Exists) > 0 && !c.Wildcard {
+			results = append(results, ReportResult{Result: fmt.Sprintf("SKIP: %s exists but none of %v common users has records, pass the addresses to check with -mail-keys", strings.Join(c.Exists, " and "), len(mailKeyUsers)+len(c.Users)),
+				Status: true, Name: "MailKeys"})
+		}
+		return results
+	}
+	apex := strings.TrimSuffix(dns.Fqdn(domain), ".")
+	for _, key := range c.Keys {
+		address := key.User + "@" + apex
+		qtype := dns.TypeToString[key.RR[0].Header().Rrtype]
+		var records, problems []string
+		for _, rr := range key.RR {
+			records = append(records, rr.String())
+			problem := ""
+			switch rr := rr.(type) {
+			case *dns.OPENPGPKEY:
+				problem = openpgpProblem(rr, address)
+			case *dns.SMIMEA:
+				problem = c.s.smimeaProblem(rr, address)
+			}
+			if problem != "" {
+				problems = append(problems, problem)
+			}
+		}
+		if len(problems) > 0 {
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: %s records of %s are invalid: %s", qtype, address, strings.Join(problems, ", ")),
+				Status: false, Name: qtype, Records: records, Remediation: "Publish the records as generated by gpg --export-options export-dane or openssl, see RFC 7929 and RFC 8162."})
+		}
+		if !key.Secure {
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: %s records of %s are not DNSSEC validated, clients ignore them", qtype, address),
+				Status: false, Name: qtype, Records: records, Remediation: "Sign the zone, OPENPGPKEY and SMIMEA records require DNSSEC."})
+		}
+		if len(problems) == 0 && key.Secure {
+			results = append(results, ReportResult{Result: fmt.Sprintf("OK  : %s records of %s are valid and DNSSEC validated", qtype, address),
+				Status: true, Name: qtype})
+		}
+	}
+	return results
+}
+
+func (c *MailKeyCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "Mail keys"
+	c.Report.Result = append(c.Report.Result, c.Values(domain)...)
+	return c.Report
+}
@@ -0,0 +1,88 @@
+package dt
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Version is the version of dt recorded in the metadata of scans, set when
+// building with -ldflags "-X github.com/42wim/dt/pkg/dt.Version=v1.2.3".
+var Version = "dev"
+
+// Meta describes how a scan was made, so stored results are self-describing.
+type Meta struct {
+	// ScanID is a random UUID, also set on every report of the scan.
+	ScanID   string
+	Start    time.Time
+	End      time.Time
+	Version  string
+	Resolver string
+	// Fallbacks are the other resolvers given, queried when Resolver
+	// doesn't answer.
+	Fallbacks []string `json:",omitempty"`
+	Transport Transport
+}
+
+// Transport are the query settings of a scan.
+type Transport struct {
+	DoT    bool   `json:",omitempty"`
+	DoTSNI string `json:",omitempty"`
+	// Family is IPv4 or IPv6 with -4 or -6, empty when both are used.
+	Family string `json:",omitempty"`
+	Class  string
+	// Timeout and Probes are 0 for the defaults.
+	Timeout     time.Duration
+	Probes      int
+	QPS         int
+	Concurrency int
+}
+
+// newScanID returns a random (version 4) UUID.
+func (s *session) newScanID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		s.log.Debugf("can't read random bytes for the scan ID: %s", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// newMeta returns the metadata of a scan of s started at start.
+func (s *session) newMeta(start time.Time) *Meta {
+	meta := &Meta{ScanID: s.newScanID(), Start: inZone(start, s.useUTC), End: inZone(time.Now(), s.useUTC), Version: Version, Resolver: serverAddr(s.resolver),
+		Transport: Transport{Class: dns.ClassToString[s.qclass], Timeout: s.queryTimeout, Probes: s.probes, QPS: s.qps, Concurrency: s.concurrency}}
+	for _, r := range s.resolverFallbacks {
+		meta.Fallbacks = append(meta.Fallbacks, serverAddr(r))
+	}
+	if s.dotConfig != nil {
+		meta.Transport.DoT, meta.Transport.DoTSNI = true, s.dotConfig.ServerName
+	}
+	if s.ipFamily != 0 {
+		meta.Transport.Family = fmt.Sprintf("IPv%v", s.ipFamily)
+	}
+	return meta
+}
+
+// stamp sets the metadata of result and the scan ID on all its reports,
+// reports that weren't timed by runEach get the times of the scan.
+func (s *session) stamp(result *Result, meta *Meta) {
+	result.Meta = meta
+	s.stampReports(result.Reports, meta)
+	for i := range result.Subzones {
+		s.stampReports(result.Subzones[i].Reports, meta)
+	}
+}
+
+func (s *session) stampReports(reports []Report, meta *Meta) {
+	for i := range reports {
+		reports[i].ScanID = meta.ScanID
+		if reports[i].Start.IsZero() {
+			reports[i].Start, reports[i].End = meta.Start, meta.End
+		}
+		reports[i].Start, reports[i].End = inZone(reports[i].Start, s.useUTC), inZone(reports[i].End, s.useUTC)
+	}
+}
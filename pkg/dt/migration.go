@@ -0,0 +1,113 @@
+package dt
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// parseCutover parses a planned cutover as an RFC 3339 timestamp or as a
+// duration from now.
+func parseCutover(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return t, fmt.Errorf("invalid cutover %q: use a duration (72h) or an RFC 3339 time", s)
+	}
+	return t, nil
+}
+
+type MigrationCheck struct {
+	NS      []NSData
+	Cutover time.Time
+	TTL     []MigrationTTL
+	Report
+}
+
+type MigrationTTL struct {
+	Record string
+	TTL    uint32
+	Parent bool
+}
+
+func (t MigrationTTL) duration() time.Duration {
+	return time.Duration(t.TTL) * time.Second
+}
+
+func (c *MigrationCheck) Scan(domain string) {
+	if parent, err := c.s.parentReferral(domain); err == nil {
+		if ns := extractRR(parent.Ns, dns.TypeNS); len(ns) > 0 {
+			c.TTL = append(c.TTL, MigrationTTL{Record: "NS at the parent", TTL: ns[0].Header().Ttl, Parent: true})
+		}
+		for _, glue := range extractRR(parent.Extra, dns.TypeA, dns.TypeAAAA) {
+			c.TTL = append(c.TTL, MigrationTTL{Record: fmt.Sprintf("glue %s %s at the parent", glue.Header().Name, dns.TypeToString[glue.Header().Rrtype]),
+				TTL: glue.Header().Ttl, Parent: true})
+		}
+	}
+	if len(c.NS) == 0 || len(c.NS[0].IP) == 0 {
+		return
+	}
+	server := c.NS[0].addr(c.NS[0].IP[0])
+	for _, qtype := range []uint16{dns.TypeNS, dns.TypeA, dns.TypeAAAA, dns.TypeMX} {
+		rrset, _, err := c.s.queryRRset(domain, qtype, server, false)
+		if err != nil {
+			continue
+		}
+		c.TTL = append(c.TTL, MigrationTTL{Record: fmt.Sprintf("apex %s", dns.TypeToString[qtype]), TTL: rrset[0].Header().Ttl})
+	}
+	for _, ns := range c.NS {
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			rrset, _, err := c.s.queryRRset(ns.Name, qtype, server, false)
+			if err != nil {
+				continue
+			}
+			c.TTL = append(c.TTL, MigrationTTL{Record: fmt.Sprintf("%s %s", ns.Name, dns.TypeToString[qtype]), TTL: rrset[0].Header().Ttl})
+		}
+	}
+}
+
+// Values orders the TTLs by the latest moment they must be lowered, so the
+// records to lower first are listed first.
+func (c *MigrationCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	if len(c.TTL) == 0 {
+		return results
+	}
+	left := time.Until(c.Cutover).Round(time.Minute)
+	sort.SliceStable(c.TTL, func(i, j int) bool { return c.TTL[i].TTL > c.TTL[j].TTL })
+	var stale time.Duration
+	for _, t := range c.TTL {
+		if t.duration() > stale {
+			stale = t.duration()
+		}
+		deadline := c.Cutover.Add(-t.duration())
+		switch {
+		case t.Parent:
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: %s has TTL %s and is set by the parent. Stale answers may persist until %s.", t.Record, FormatTTL(t.TTL), FormatTime(c.Cutover.Add(t.duration()), c.s.useUTC)),
+				Status: false, Name: "Parent", Remediation: "Ask your registrar whether the TTL of the parent records can be lowered, or plan for the old answers until then."})
+		case t.duration() > left:
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: %s has TTL %s, longer than the %s left before the cutover. Lower it now.", t.Record, FormatTTL(t.TTL), FormatDuration(left)),
+				Status: false, Name: "Lower", Remediation: "Lower the TTL now and move the cutover so at least the old TTL passes first."})
+		case t.TTL > 300:
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: %s has TTL %s. Lower it before %s.", t.Record, FormatTTL(t.TTL), FormatWhen(deadline, c.s.useUTC)),
+				Status: false, Name: "Lower", Remediation: "Lower the TTL to 300 seconds or less before the deadline."})
+		default:
+			results = append(results, ReportResult{Result: fmt.Sprintf("OK  : %s has TTL %s.", t.Record, FormatTTL(t.TTL)),
+				Status: true, Name: "Lower"})
+		}
+	}
+	results = append(results, ReportResult{Result: fmt.Sprintf("OK  : Without lowering any TTL, stale answers may persist up to %s after the cutover.", FormatDuration(stale)),
+		Status: true, Name: "Stale"})
+	return results
+}
+
+func (c *MigrationCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "Migration"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
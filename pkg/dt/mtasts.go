@@ -0,0 +1,242 @@
+package dt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// mtaSTSMaxPolicy is the size of the MTA-STS policy file read.
+const mtaSTSMaxPolicy = 64 * 1024
+
+// MTASTSCheck validates the MTA-STS policy (RFC 8461) and TLS-RPT record
+// (RFC 8460) of a domain.
+type MTASTSCheck struct {
+	NS     []NSData
+	STS    []string
+	TLSRPT []string
+	MX     []string
+	// URL is the policy fetched, Policy its content and Error why it
+	// couldn't be fetched.
+	URL    string
+	Policy string
+	Error  string
+	Report
+}
+
+// txtRecords returns the TXT values of name starting with prefix.
+func (s *session) txtRecords(name, prefix string) []string {
+	txt, _, _ := s.queryRRset(name, dns.TypeTXT, s.resolver, false)
+	var records []string
+	for _, rr := range txt {
+		if value := txtString(rr.(*dns.TXT)); strings.HasPrefix(value, prefix) {
+			records = append(records, value)
+		}
+	}
+	return records
+}
+
+func (c *MTASTSCheck) Scan(domain string) {
+	apex := strings.TrimSuffix(dns.Fqdn(domain), ".")
+	c.STS = c.s.txtRecords("_mta-sts."+apex+".", "v=STSv1")
+	c.TLSRPT = c.s.txtRecords("_smtp._tls."+apex+".", "v=TLSRPTv1")
+	mx, _, _ := c.s.queryRRset(apex+".", dns.TypeMX, c.s.resolver, false)
+	for _, rr := range mx {
+		c.MX = append(c.MX, strings.ToLower(strings.TrimSuffix(rr.(*dns.MX).Mx, ".")))
+	}
+	if len(c.STS) == 0 {
+		return
+	}
+
+	// the policy must be served directly, redirects are not followed
+	// (RFC 8461 section 3.3)
+	var redirects int
+	client := c.s.httpClient(&redirects)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse }
+	c.URL = "https://mta-sts." + apex + "/.well-known/mta-sts.txt"
+	resp, err := client.Get(c.URL)
+	if err != nil {
+		c.Error = err.Error()
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		c.Error = fmt.Sprintf("HTTP status %s", resp.Status)
+		return
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		c.Error = fmt.Sprintf("content type is %q instead of text/plain", ct)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, mtaSTSMaxPolicy))
+	if err != nil {
+		c.Error = err.Error()
+		return
+	}
+	c.Policy = string(body)
+}
+
+// stsPolicy parses an MTA-STS policy into its keys, mx may repeat.
+func stsPolicy(policy string) (map[string]string, []string, error) {
+	keys := make(map[string]string)
+	var mx []string
+	scanner := bufio.NewScanner(strings.NewReader(policy))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			return nil, nil, fmt.Errorf("%q is not a key: value line", line)
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if key == "mx" {
+			mx = append(mx, strings.ToLower(strings.TrimSuffix(value, ".")))
+			continue
+		}
+		keys[key] = value
+	}
+	if keys["version"] != "STSv1" {
+		return nil, nil, fmt.Errorf("version is %q instead of STSv1", keys["version"])
+	}
+	switch keys["mode"] {
+	case "enforce", "testing", "none":
+	default:
+		return nil, nil, fmt.Errorf("invalid mode %q", keys["mode"])
+	}
+	if age, err := strconv.Atoi(keys["max_age"]); err != nil || age < 0 || age > 31557600 {
+		return nil, nil, fmt.Errorf("max_age %q is not between 0 and 31557600 seconds", keys["max_age"])
+	}
+	if len(mx) == 0 && keys["mode"] != "none" {
+		return nil, nil, fmt.Errorf("no mx patterns")
+	}
+	return keys, mx, nil
+}
+
+// stsMatch reports whether host matches an MTA-STS mx pattern, where a
+// leading *. matches exactly one label.
+func stsMatch(pattern, host string) bool {
+	if strings.HasPrefix(pattern, "*.") {
+		i := strings.Index(host, ".")
+		return i > 0 && host[i+1:] == pattern[2:]
+	}
+	return pattern == host
+}
+
+func (c *MTASTSCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	switch {
+	case len(c.STS) == 0:
+		results = append(results, ReportResult{Result: "WARN: No MTA-STS record found. Sending servers fall back to opportunistic TLS, which an attacker can strip.",
+			Status: false, Name: "MTASTS", Remediation: "Publish _mta-sts TXT \"v=STSv1; id=<timestamp>\" and a policy at https://mta-sts.<domain>/.well-known/mta-sts.txt."})
+	case len(c.STS) > 1:
+		results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: %v MTA-STS records found, senders treat this as no policy", len(c.STS)),
+			Status: false, Name: "MTASTS", Records: c.STS, Remediation: "Keep a single _mta-sts TXT record."})
+	default:
+		tags := dmarcTags(c.STS[0])
+		id := tags["id"]
+		valid := id != "" && len(id) <= 32
+		for _, r := range id {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+				valid = false
+			}
+		}
+		if !valid {
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: MTA-STS record has an invalid id %q, 1 to 32 letters and digits required", id),
+				Status: false, Name: "MTASTS", Records: c.STS, Remediation: "Set id= to a timestamp like 20240101T000000 and change it with every policy update."})
+		} else {
+			results = append(results, ReportResult{Result: "OK  : MTA-STS record found.", Status: true, Name: "MTASTS", Records: c.STS})
+		}
+		results = append(results, c.policyResults()...)
+	}
+
+	switch {
+	case len(c.TLSRPT) == 0:
+		results = append(results, ReportResult{Result: "WARN: No TLS-RPT record found, you don't receive reports of TLS failures delivering your mail.",
+			Status: false, Name: "TLSRPT", Remediation: "Publish _smtp._tls TXT \"v=TLSRPTv1; rua=mailto:tlsrpt@<domain>\"."})
+	case len(c.TLSRPT) > 1:
+		results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: %v TLS-RPT records found, senders ignore them all", len(c.TLSRPT)),
+			Status: false, Name: "TLSRPT", Records: c.TLSRPT, Remediation: "Keep a single _smtp._tls TXT record."})
+	default:
+		rua := dmarcTags(c.TLSRPT[0])["rua"]
+		var bad []string
+		for _, uri := range strings.Split(rua, ",") {
+			u, err := url.Parse(strings.TrimSpace(uri))
+			if err != nil || (u.Scheme != "mailto" || u.Opaque == "") && (u.Scheme != "https" || u.Host == "") {
+				bad = append(bad, strings.TrimSpace(uri))
+			}
+		}
+		if rua == "" || len(bad) > 0 {
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: TLS-RPT record has invalid rua destinations %q, mailto: or https: URIs required", strings.Join(bad, ",")),
+				Status: false, Name: "TLSRPT", Records: c.TLSRPT, Remediation: "Set rua= to a mailto: or https: URI (RFC 8460 section 3)."})
+		} else {
+			results = append(results, ReportResult{Result: "OK  : TLS-RPT record found.", Status: true, Name: "TLSRPT", Records: c.TLSRPT})
+		}
+	}
+	return results
+}
+
+// policyResults validates the MTA-STS policy file against the MX records.
+func (c *MTASTSCheck) policyResults() []ReportResult {
+	results := []ReportResult{}
+	if c.Error != "" {
+		return append(results, ReportResult{Result: fmt.Sprintf("FAIL: MTA-STS policy %s can't be fetched: %s", c.URL, c.Error),
+			Status: false, Name: "MTASTSPolicy", Remediation: "Serve the policy as text/plain over HTTPS with a valid certificate for mta-sts.<domain>, without redirects."})
+	}
+	keys, patterns, err := stsPolicy(c.Policy)
+	if err != nil {
+		return append(results, ReportResult{Result: fmt.Sprintf("FAIL: MTA-STS policy %s is invalid: %s", c.URL, err),
+			Status: false, Name: "MTASTSPolicy", Remediation: "Fix the policy, see RFC 8461 section 3.2."})
+	}
+	switch keys["mode"] {
+	case "enforce":
+		results = append(results, ReportResult{Result: "OK  : MTA-STS policy is in enforce mode.", Status: true, Name: "MTASTSPolicy"})
+	case "testing":
+		results = append(results, ReportResult{Result: "WARN: MTA-STS policy is in testing mode, failures are only reported.",
+			Status: false, Name: "MTASTSPolicy", Remediation: "Switch to mode: enforce once TLS-RPT reports show no failures."})
+	case "none":
+		results = append(results, ReportResult{Result: "WARN: MTA-STS policy mode is none, the policy is withdrawn.",
+			Status: false, Name: "MTASTSPolicy", Remediation: "Switch to mode: testing or enforce, or remove the _mta-sts record."})
+	}
+	if age, _ := strconv.Atoi(keys["max_age"]); age < 86400 && keys["mode"] != "none" {
+		results = append(results, ReportResult{Result: fmt.Sprintf("WARN: MTA-STS max_age is %v seconds, senders recheck often and are exposed once it expires", age),
+			Status: false, Name: "MTASTSPolicy", Remediation: "Raise max_age to at least a week (604800) once the policy is stable."})
+	}
+	var unmatched []string
+	for _, mx := range c.MX {
+		matched := false
+		for _, p := range patterns {
+			if stsMatch(p, mx) {
+				matched = true
+			}
+		}
+		if !matched {
+			unmatched = append(unmatched, mx)
+		}
+	}
+	if len(unmatched) > 0 && keys["mode"] != "none" {
+		effect := "senders report failures delivering there"
+		if keys["mode"] == "enforce" {
+			effect = "senders will refuse to deliver there"
+		}
+		results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: MX %s not covered by the MTA-STS mx patterns %s, %s", strings.Join(unmatched, ", "), strings.Join(patterns, ", "), effect),
+			Status: false, Name: "MTASTSPolicy", Remediation: "Add an mx: line for every MX host to the policy and change the id of the _mta-sts record."})
+	} else if keys["mode"] != "none" {
+		results = append(results, ReportResult{Result: "OK  : All MX hosts match the MTA-STS mx patterns.", Status: true, Name: "MTASTSPolicy"})
+	}
+	return results
+}
+
+func (c *MTASTSCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "MTA-STS"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
@@ -1,4 +1,4 @@
-package main
+package dt
 
 import (
 	"fmt"
@@ -23,19 +23,23 @@ type MXData struct {
 }
 
 func (c *MXCheck) Scan(domain string) {
-	for _, ns := range c.NS {
-		for _, nsip := range ns.IP {
-			data := MXData{Name: ns.Name, IP: nsip.String(), MXIP: make(map[string][]net.IP)}
-			mx, _, err := queryRRset(domain, dns.TypeMX, nsip.String(), true)
-			if !scanerror(&c.Report, "MX scan", ns.Name, nsip.String(), domain, mx, err) {
-				data.MX = mx
-				// TODO only lookup once
-				for _, mx := range data.MX {
-					data.MXIP[mx.(*dns.MX).Mx] = append(data.MXIP[mx.(*dns.MX).Mx], getIP(mx.(*dns.MX).Mx, dns.TypeA, resolver)...)
-					data.MXIP[mx.(*dns.MX).Mx] = append(data.MXIP[mx.(*dns.MX).Mx], getIP(mx.(*dns.MX).Mx, dns.TypeAAAA, resolver)...)
-				}
-				c.MX = append(c.MX, data)
+	datas := make([]*MXData, serverCount(c.NS))
+	c.s.eachServer(c.NS, &c.Report, func(i int, ns NSData, nsip net.IP, r *Report) {
+		data := MXData{Name: ns.Name, IP: ns.addr(nsip), MXIP: make(map[string][]net.IP)}
+		mx, _, err := c.s.queryRRset(domain, dns.TypeMX, ns.addr(nsip), true)
+		if !scanerror(r, "MX scan", ns.Name, ns.addr(nsip), domain, mx, err) {
+			data.MX = mx
+			// TODO only lookup once
+			for _, mx := range data.MX {
+				data.MXIP[mx.(*dns.MX).Mx] = append(data.MXIP[mx.(*dns.MX).Mx], c.s.getIP(mx.(*dns.MX).Mx, dns.TypeA, c.s.resolver)...)
+				data.MXIP[mx.(*dns.MX).Mx] = append(data.MXIP[mx.(*dns.MX).Mx], c.s.getIP(mx.(*dns.MX).Mx, dns.TypeAAAA, c.s.resolver)...)
 			}
+			datas[i] = &data
+		}
+	})
+	for _, data := range datas {
+		if data != nil {
+			c.MX = append(c.MX, *data)
 		}
 	}
 }
@@ -56,6 +60,7 @@ func (c *MXCheck) Identical() ReportResult {
 	if len(m) > 1 {
 		res.Result = fmt.Sprintf("FAIL: MX not identical\n")
 		res.Status = false
+		res.Remediation = "Make sure all nameservers serve the same zone version. Check zone transfers (NOTIFY, AXFR/IXFR) to the secondaries."
 		res.Name = "Identical"
 		for k, v := range m {
 			res.Result += fmt.Sprintf("\t %s\n\t %s\n", v, k)
@@ -106,25 +111,25 @@ func (c *MXCheck) CheckCNAME() []ReportResult {
 			for mxName := range mx.MXIP {
 				// skip lookup if already done
 				if _, ok := m[mxName]; ok {
-					break
+					continue
 				}
-				res, err := query(dns.Fqdn(mxName), dns.TypeA, resolver, true)
+				res, err := c.s.query(dns.Fqdn(mxName), dns.TypeA, c.s.resolver, true)
 				if err != nil {
-					break
+					continue
 				}
 				cname := extractRR(res.Msg.Answer, dns.TypeCNAME)
 				if len(cname) > 0 {
 					rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: Your MX (%s) is a CNAME.", mxName),
-						Status: false, Name: "CNAME"})
+						Status: false, Name: "CNAME", Remediation: "Point the MX record at the canonical hostname (the CNAME target) instead of the alias."})
 				}
-				res, err = query(dns.Fqdn(mxName), dns.TypeAAAA, resolver, true)
+				res, err = c.s.query(dns.Fqdn(mxName), dns.TypeAAAA, c.s.resolver, true)
 				if err != nil {
-					break
+					continue
 				}
 				cname = extractRR(res.Msg.Answer, dns.TypeCNAME)
 				if len(cname) > 0 {
 					rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: Your MX (%s) is a CNAME.", mxName),
-						Status: false, Name: "CNAME"})
+						Status: false, Name: "CNAME", Remediation: "Point the MX record at the canonical hostname (the CNAME target) instead of the alias."})
 				}
 				m[mxName] = true
 			}
@@ -145,9 +150,9 @@ func (c *MXCheck) CheckReverse() []ReportResult {
 			for name, ips := range mx.MXIP {
 				for _, ip := range ips {
 					rev, _ := dns.ReverseAddr(ip.String())
-					res, _, err := queryRRset(rev, dns.TypePTR, resolver, true)
+					res, _, err := c.s.queryRRset(rev, dns.TypePTR, c.s.resolver, true)
 					if err != nil {
-						break
+						continue
 					}
 					if len(res) > 0 {
 						m[name] = true
@@ -162,7 +167,7 @@ func (c *MXCheck) CheckReverse() []ReportResult {
 	for name, reverse := range m {
 		if !reverse {
 			rep = append(rep, ReportResult{Result: fmt.Sprintf("WARN: Reverse PTR lookup for MX %s failed.", name),
-				Status: false, Name: "Reverse"})
+				Status: false, Name: "Reverse", Remediation: "Ask the owner of the IP address (usually your hosting provider) to add a PTR record matching the MX hostname."})
 		}
 	}
 	if len(rep) == 0 {
@@ -190,7 +195,7 @@ func (c *MXCheck) Values() []ReportResult {
 			Status: true, Records: records, Name: "Multiple"})
 	} else {
 		results = append(results, ReportResult{Result: fmt.Sprintf("WARN: Only %v MX record found. Extra records increases reliability", len(rrset)),
-			Status: false, Name: "Multiple"})
+			Status: false, Name: "Multiple", Remediation: "Add a backup MX record with a higher preference value."})
 	}
 
 	if !c.checkRFC1918() {
@@ -198,7 +203,7 @@ func (c *MXCheck) Values() []ReportResult {
 			Status: true, Name: "RFC1918"})
 	} else {
 		results = append(results, ReportResult{Result: "FAIL: Some of your MX records have non-routable (RFC1918) addresses.",
-			Status: false, Name: "RFC1918"})
+			Status: false, Name: "RFC1918", Remediation: "Give the MX hosts public addresses or use an internal split-horizon view for private ones."})
 	}
 
 	m := c.checkDuplicateIP()
@@ -206,7 +211,7 @@ func (c *MXCheck) Values() []ReportResult {
 	for k, v := range m {
 		if len(v) > 1 {
 			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: Same IP %s is used by multiple MX records %v.", k, v),
-				Status: false, Name: "DuplicateIP"})
+				Status: false, Name: "DuplicateIP", Remediation: "Use MX hosts on different IP addresses so one failure does not take down mail delivery."})
 			duplicate = true
 		}
 	}
@@ -218,6 +223,10 @@ func (c *MXCheck) Values() []ReportResult {
 	return results
 }
 
+func (c *MXCheck) Dependency() Dependency {
+	return Dependency{Type: "MX", Requires: []string{"MX"}}
+}
+
 func (c *MXCheck) CreateReport(domain string) Report {
 	c.Scan(domain)
 	c.Report.Type = "MX"
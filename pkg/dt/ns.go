@@ -0,0 +1,551 @@
+package dt
+
+import (
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+	"sort"
+	"strings"
+)
+
+type NSCheck struct {
+	NS           []NSData
+	NSCheck      []NSCheckData
+	MinProviders int
+	// MinCountries and DeniedCountries are the policy on the countries the
+	// nameserver addresses are located in.
+	MinCountries    int
+	DeniedCountries []string
+	// NoOrigin skips the checks needing the origin (AS, country) of the
+	// nameserver addresses.
+	NoOrigin bool
+	Report
+}
+
+type NSCheckData struct {
+	Name      string
+	IP        string
+	NS        []dns.RR
+	CNAME     []dns.RR
+	Error     string
+	Auth      bool
+	Recursive bool
+	// OpenResolver is set when the server resolved openResolverProbes for
+	// anyone.
+	OpenResolver bool
+}
+
+// openResolverProbes are names no authoritative server of the checked domain
+// serves, the first one outside of the domain is asked recursively.
+var openResolverProbes = []string{"a.root-servers.net.", "www.iana.org."}
+
+// openResolver reports whether server answers a recursive query for a name
+// outside of domain, which only a resolver does.
+func (s *session) openResolver(domain, server string) bool {
+	probe := openResolverProbes[0]
+	if dns.IsSubDomain("root-servers.net.", dns.Fqdn(domain)) {
+		probe = openResolverProbes[1]
+	}
+	res, err := s.query(probe, dns.TypeA, server, false)
+	if err != nil {
+		return false
+	}
+	return res.Msg.RecursionAvailable && !res.Msg.Authoritative && len(extractRR(res.Msg.Answer, dns.TypeA)) > 0
+}
+
+func (c *NSCheck) Scan(domain string) {
+	c.NSCheck = make([]NSCheckData, serverCount(c.NS))
+	c.s.eachServer(c.NS, &c.Report, func(i int, ns NSData, nsip net.IP, r *Report) {
+		data := NSCheckData{Name: ns.Name, IP: ns.addr(nsip)}
+		res, err := c.s.query(domain, dns.TypeNS, ns.addr(nsip), true)
+		rrset := extractRRMsg(res.Msg, dns.TypeNS)
+		if !scanerror(r, "NS scan", ns.Name, ns.addr(nsip), domain, rrset, err) {
+			data.NS = rrset
+			data.Auth = res.Msg.Authoritative
+			data.Recursive = res.Msg.RecursionAvailable
+			data.OpenResolver = c.s.openResolver(domain, ns.addr(nsip))
+		}
+		c.NSCheck[i] = data
+	})
+}
+
+func (c *NSCheck) CheckCNAME() []ReportResult {
+	rep := []ReportResult{}
+	m := make(map[string]bool)
+	for _, ns := range c.NSCheck {
+		// skip lookup if already done
+		if _, ok := m[ns.Name]; ok {
+			continue
+		}
+		// asking recursor for now
+		res, err := c.s.query(dns.Fqdn(ns.Name), dns.TypeA, c.s.resolver, true)
+		if err != nil {
+			continue
+		}
+		cname := extractRR(res.Msg.Answer, dns.TypeCNAME)
+		if len(cname) > 0 {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: Your nameserver (%s) is a CNAME.", ns.Name),
+				Status: false, Remediation: "Point the NS record at the canonical hostname (the CNAME target) instead of the alias."})
+		}
+		res, err = c.s.query(dns.Fqdn(ns.Name), dns.TypeAAAA, c.s.resolver, true)
+		if err != nil {
+			continue
+		}
+		cname = extractRR(res.Msg.Answer, dns.TypeCNAME)
+		if len(cname) > 0 {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: Your nameserver (%s) is a CNAME.", ns.Name),
+				Status: false, Remediation: "Point the NS record at the canonical hostname (the CNAME target) instead of the alias."})
+		}
+		m[ns.Name] = true
+	}
+	if len(rep) == 0 {
+		rep = append(rep, ReportResult{Result: "OK  : No CNAMEs found for your NS records",
+			Status: true})
+	}
+	return rep
+}
+
+func (c *NSCheck) CheckParent(domain string) []ReportResult {
+	var rep []ReportResult
+	nsdata, err := c.s.findNS(c.s.parentZone(domain))
+	if err != nil {
+		return []ReportResult{}
+	}
+	var rrset []dns.RR
+loop:
+	for _, ns := range nsdata {
+		for _, nsip := range ns.IP {
+			res, err := c.s.query(dns.Fqdn(domain), dns.TypeNS, ns.addr(nsip), true)
+			if err != nil {
+				break
+			}
+			rrset = extractRR(res.Msg.Ns, dns.TypeNS)
+			if len(rrset) > 0 {
+				break loop
+			}
+		}
+	}
+	m := make(map[string]bool)
+	for _, rr := range rrset {
+		m[dns.Fqdn(rr.(*dns.NS).Ns)] = true
+	}
+	missing := []string{}
+	for _, ns := range c.NS {
+		if _, ok := m[ns.Name]; !ok {
+			missing = append(missing, ns.Name)
+		} else {
+			m[ns.Name] = false
+		}
+
+	}
+	if len(missing) > 0 {
+		rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: The following nameservers are not listed as NS at the parent nameservers: %s", missing),
+			Status: false, Remediation: "Update the delegation at your registrar so it lists the same nameservers as your zone."})
+	} else {
+		rep = append(rep, ReportResult{Result: "OK  : Your nameservers are also listed as NS at the parent nameservers",
+			Status: true})
+	}
+
+	// find the records that are sent by parent NS but arent in the domain NS
+	missing = []string{}
+	for k, v := range m {
+		if v == true {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) > 0 {
+		rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: The following nameservers are listed at the parent but not as NS at your nameservers: %s", missing),
+			Status: false, Remediation: "Add the missing NS records to your zone, or remove the nameservers from the delegation at your registrar."})
+	} else {
+		rep = append(rep, ReportResult{Result: "OK  : Your parent nameservers are also listed as NS at your nameservers",
+			Status: true})
+	}
+	return rep
+}
+
+// ttlMismatchFactor is the ratio between two TTLs that is reported.
+const ttlMismatchFactor = 10
+
+// ttlMismatch reports whether one TTL is more than ttlMismatchFactor times
+// the other.
+func ttlMismatch(a, b uint32) bool {
+	return a > b*ttlMismatchFactor || b > a*ttlMismatchFactor
+}
+
+// CheckParentTTL compares the TTLs of the NS and glue records served by the
+// parent with the ones served by the nameservers of the domain.
+func (c *NSCheck) CheckParentTTL(domain string) []ReportResult {
+	rep := []ReportResult{}
+	parent, err := c.s.parentReferral(domain)
+	if err != nil {
+		return rep
+	}
+	var childNS []dns.RR
+	var childIP string
+	for _, ns := range c.NSCheck {
+		if len(ns.NS) > 0 {
+			childNS, childIP = ns.NS, ns.IP
+			break
+		}
+	}
+	if len(childNS) == 0 {
+		return rep
+	}
+	parentNS := extractRR(parent.Ns, dns.TypeNS)
+	pttl, cttl := parentNS[0].Header().Ttl, childNS[0].Header().Ttl
+	if ttlMismatch(pttl, cttl) {
+		rep = append(rep, ReportResult{Result: fmt.Sprintf("WARN: NS TTL at the parent (%s) and at your nameservers (%s) differ a lot. Caches will behave unpredictably during migrations.", FormatTTL(pttl), FormatTTL(cttl)),
+			Status: false, Name: "TTL", Remediation: "Set the NS TTL in your zone close to the parent TTL, typically 86400 (1 day)."})
+	} else {
+		rep = append(rep, ReportResult{Result: fmt.Sprintf("OK  : NS TTL at the parent (%s) and at your nameservers (%s) are comparable", FormatTTL(pttl), FormatTTL(cttl)),
+			Status: true, Name: "TTL"})
+	}
+
+	for _, glue := range extractRR(parent.Extra, dns.TypeA, dns.TypeAAAA) {
+		child, _, err := c.s.queryRRset(glue.Header().Name, glue.Header().Rrtype, childIP, false)
+		if err != nil {
+			continue
+		}
+		pttl, cttl := glue.Header().Ttl, child[0].Header().Ttl
+		if ttlMismatch(pttl, cttl) {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("WARN: Glue TTL of %s at the parent (%s) and at your nameservers (%s) differ a lot.", glue.Header().Name, FormatTTL(pttl), FormatTTL(cttl)),
+				Status: false, Name: "GlueTTL", Remediation: "Align the TTL of the address records in your zone with the glue TTL at the parent."})
+		}
+	}
+	return rep
+}
+
+// mixCase alternates the case of the letters in name, like a resolver using
+// 0x20 encoding would.
+func mixCase(name string) string {
+	b := []byte(strings.ToLower(name))
+	upper := false
+	for i, ch := range b {
+		if ch >= 'a' && ch <= 'z' {
+			if upper {
+				b[i] = ch - 'a' + 'A'
+			}
+			upper = !upper
+		}
+	}
+	return string(b)
+}
+
+// CheckCase verifies every nameserver echoes the question name with its case
+// intact, which resolvers need for 0x20 spoofing protection.
+func (c *NSCheck) CheckCase(domain string) []ReportResult {
+	rep := []ReportResult{}
+	qname := mixCase(dns.Fqdn(domain))
+	checked := 0
+	for _, ns := range c.NSCheck {
+		if len(ns.NS) == 0 {
+			continue
+		}
+		server := fmt.Sprintf("%s (%s)", ns.Name, ns.IP)
+		res, err := c.s.query(qname, dns.TypeSOA, ns.IP, false)
+		if err == nil && len(res.Msg.Question) == 0 {
+			err = fmt.Errorf("no question in the answer")
+		}
+		if err != nil {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("ERR : Case check failed on %s: %s", server, err),
+				Name: "Case", Error: err.Error(), Server: server, Unreachable: true})
+			continue
+		}
+		checked++
+		if res.Msg.Question[0].Name != qname {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("WARN: %s (%s) does not preserve the case of the question (asked %s, got %s). This weakens resolver spoofing protection (0x20).", ns.Name, ns.IP, qname, res.Msg.Question[0].Name),
+				Status: false, Name: "Case", Remediation: "Upgrade the nameserver software or disable case normalization so 0x20 encoding keeps working."})
+		}
+	}
+	if len(rep) == 0 && checked > 0 {
+		rep = append(rep, ReportResult{Result: "OK  : All nameservers preserve the case of the question.",
+			Status: true, Name: "Case"})
+	}
+	return rep
+}
+
+func (c *NSCheck) Identical() ReportResult {
+	m := make(map[string][]string)
+	for _, ns := range c.NSCheck {
+		nsstr := []string{}
+		if ns.NS != nil && ns.Error == "" {
+			for _, nsc := range ns.NS {
+				nsstr = append(nsstr, nsc.String()+"\n\t ")
+			}
+			sort.Strings(nsstr)
+			m[strings.Join(nsstr, "")] = append(m[strings.Join(nsstr, "")], ns.IP)
+		}
+	}
+	res := ReportResult{}
+	if len(m) > 1 {
+		res.Result = fmt.Sprintf("FAIL: NS not identical\n")
+		res.Status = false
+		res.Remediation = "Make sure all nameservers serve the same zone version. Check zone transfers (NOTIFY, AXFR/IXFR) to the secondaries."
+		for k, v := range m {
+			res.Result += fmt.Sprintf("\t %s\n\t %s\n", v, k)
+		}
+	} else {
+		res.Result = "OK  : NS of all nameservers are identical"
+		res.Status = true
+	}
+	return res
+}
+
+func (c *NSCheck) ASN() ReportResult {
+	m := make(map[string][]string)
+	var failed []string
+	for _, ns := range c.NSCheck {
+		ip := net.ParseIP(serverHost(ns.IP))
+		info, err := c.s.ipinfo(ip)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%s)", ns.IP, err))
+			continue
+		}
+		m[info.ASN.String()] = append(m[info.ASN.String()], ns.IP)
+	}
+	res := ReportResult{Name: "ASN"}
+	if len(m) == 0 {
+		res.Result = fmt.Sprintf("ERR : AS lookup failed for all nameservers: %s", strings.Join(failed, ", "))
+	} else if len(m) > 1 {
+		res.Result = "OK  : Nameservers are spread over multiple AS"
+		res.Status = true
+	} else {
+		as := ""
+		for k := range m {
+			as = k
+			break
+		}
+		res.Result = fmt.Sprintf("WARN: Nameservers are all on the same AS (%s). This is a single point of failure.", as)
+		res.Status = false
+		res.Remediation = "Add a secondary nameserver hosted in a different network (AS)."
+	}
+	return res
+}
+
+// CheckProviders maps the nameservers to DNS providers and reports whether
+// the domain depends on a single provider.
+func (c *NSCheck) CheckProviders() []ReportResult {
+	res := []ReportResult{}
+	m := make(map[string]bool)
+	var providers []string
+	for _, ns := range c.NS {
+		p := c.s.nsProvider(ns)
+		if !m[p] {
+			m[p] = true
+			providers = append(providers, p)
+		}
+	}
+	sort.Strings(providers)
+	if len(providers) > 1 {
+		res = append(res, ReportResult{Result: fmt.Sprintf("OK  : Nameservers are spread over multiple providers %v", providers),
+			Status: true, Name: "Provider"})
+	} else {
+		res = append(res, ReportResult{Result: fmt.Sprintf("WARN: All nameservers are hosted by a single provider %v. This is a single point of failure.", providers),
+			Status: false, Name: "Provider", Remediation: "Add a secondary DNS provider and list its nameservers in the delegation."})
+	}
+	if c.MinProviders > 0 && len(providers) < c.MinProviders {
+		res = append(res, ReportResult{Result: fmt.Sprintf("FAIL: Only %v DNS provider(s) found, policy requires at least %v", len(providers), c.MinProviders),
+			Status: false, Name: "ProviderPolicy", Remediation: "Add nameservers from another DNS provider to meet the policy."})
+	}
+	return res
+}
+
+// CheckCountries evaluates the country policy against the location of the
+// nameserver addresses.
+func (c *NSCheck) CheckCountries() []ReportResult {
+	res := []ReportResult{}
+	denied := make(map[string]bool)
+	for _, country := range c.DeniedCountries {
+		if country = strings.ToUpper(strings.TrimSpace(country)); country != "" {
+			denied[country] = true
+		}
+	}
+	if c.MinCountries == 0 && len(denied) == 0 {
+		return res
+	}
+	m := make(map[string][]string)
+	var countries []string
+	unknown := 0
+	for _, ns := range c.NSCheck {
+		server := fmt.Sprintf("%s (%s)", ns.Name, ns.IP)
+		info, err := c.s.ipinfo(net.ParseIP(serverHost(ns.IP)))
+		if err != nil {
+			res = append(res, ReportResult{Result: fmt.Sprintf("ERR : Location lookup of %s failed, country policy not verified for it: %s", server, err),
+				Name: "CountryPolicy", Error: err.Error()})
+			unknown++
+			continue
+		}
+		if info.Loc == "" {
+			res = append(res, ReportResult{Result: fmt.Sprintf("WARN: Location of %s is unknown, country policy not verified for it", server),
+				Status: false, Name: "CountryPolicy", Remediation: "Check the location of this nameserver by hand, or use addresses with registered geolocation."})
+			unknown++
+			continue
+		}
+		country := strings.ToUpper(info.Loc)
+		if _, ok := m[country]; !ok {
+			countries = append(countries, country)
+		}
+		m[country] = append(m[country], fmt.Sprintf("%s (%s)", ns.Name, ns.IP))
+	}
+	sort.Strings(countries)
+	if c.MinCountries > 0 {
+		if len(countries) < c.MinCountries {
+			res = append(res, ReportResult{Result: fmt.Sprintf("FAIL: Nameservers are located in %v country(s) %v, policy requires at least %v", len(countries), countries, c.MinCountries),
+				Status: false, Name: "CountryPolicy", Remediation: "Add nameservers hosted in another country to meet the policy."})
+		} else {
+			res = append(res, ReportResult{Result: fmt.Sprintf("OK  : Nameservers are located in %v countries %v", len(countries), countries),
+				Status: true, Name: "CountryPolicy"})
+		}
+	}
+	ok := true
+	for _, country := range countries {
+		if denied[country] {
+			res = append(res, ReportResult{Result: fmt.Sprintf("FAIL: Nameservers %v are located in %s, which policy doesn't allow", m[country], country),
+				Status: false, Name: "CountryPolicy", Remediation: "Move these nameservers to a provider or location outside the denied jurisdictions."})
+			ok = false
+		}
+	}
+	// a server of unknown location may be in a denied country
+	if ok && unknown == 0 && len(denied) > 0 {
+		res = append(res, ReportResult{Result: "OK  : No nameservers are located in denied countries",
+			Status: true, Name: "CountryPolicy"})
+	}
+	return res
+}
+
+func (c *NSCheck) IPCheck() []ReportResult {
+	m := make(map[string]int)
+	for _, ns := range c.NSCheck {
+		if strings.Contains(ns.IP, ":") {
+			m["ipv6"]++
+		} else {
+			m["ipv4"]++
+		}
+	}
+
+	res := []ReportResult{}
+	if m["ipv6"] == 0 {
+		res = append(res, ReportResult{Result: "WARN: No IPv6 nameservers found. IPv6-only users will have problems.",
+			Status: false, Remediation: "Give at least one nameserver an AAAA record (and glue if it is in-bailiwick)."})
+	}
+
+	// I wonder when this will ever happen :)
+	if m["ipv4"] == 0 {
+		res = append(res, ReportResult{Result: "WARN: No IPv4 nameservers found. IPv4-only users will have problems.",
+			Status: false, Remediation: "Give at least one nameserver an A record (and glue if it is in-bailiwick)."})
+	}
+	if (m["ipv4"] > 0) && (m["ipv6"] > 0) {
+		res = append(res, ReportResult{Result: "OK  : IPv4 and IPv6 nameservers found.",
+			Status: true})
+	}
+	return res
+}
+
+func (c *NSCheck) Auth() []ReportResult {
+	res := []ReportResult{}
+	ok := true
+	for _, ns := range c.NSCheck {
+		if len(ns.NS) > 0 && !ns.Auth {
+			res = append(res, ReportResult{Result: fmt.Sprintf("FAIL: %s (%s) is not authoritative.", ns.Name, ns.IP),
+				Status: false, Remediation: "Configure the zone on this nameserver or remove it from the NS records and the delegation."})
+			ok = false
+		}
+	}
+	if ok {
+		res = append(res, ReportResult{Result: "OK  : All nameservers are authoritative.",
+			Status: true})
+	}
+	return res
+}
+
+func (c *NSCheck) Recursive() []ReportResult {
+	res := []ReportResult{}
+	ok := true
+	for _, ns := range c.NSCheck {
+		switch {
+		case len(ns.NS) == 0:
+		case ns.OpenResolver:
+			res = append(res, ReportResult{Result: fmt.Sprintf("FAIL: %s (%s) is an open resolver, it resolves names outside of its zones for anyone. It can be abused for DDoS amplification and cache poisoning.", ns.Name, ns.IP),
+				Status: false, Name: "OpenRecursion", Remediation: "Disable recursion on authoritative servers (BIND: recursion no;), or restrict it to your own networks (allow-recursion)."})
+			ok = false
+		case ns.Recursive:
+			res = append(res, ReportResult{Result: fmt.Sprintf("WARN: %s (%s) allows recursive queries.", ns.Name, ns.IP),
+				Status: false, Remediation: "Disable recursion on authoritative servers (BIND: recursion no;)."})
+			ok = false
+		}
+	}
+	if ok {
+		res = append(res, ReportResult{Result: "OK  : All nameservers report they are not allowing recursive queries.",
+			Status: true})
+	}
+	return res
+}
+
+func (c *NSCheck) checkSameSubnet() bool {
+	var ips []net.IP
+	for _, ns := range c.NS {
+		ips = append(ips, ns.IP...)
+	}
+	return isSameSubnet(ips...)
+}
+
+func (c *NSCheck) Values() []ReportResult {
+	var results []ReportResult
+	var rrset []dns.RR
+	for _, ns := range c.NSCheck {
+		if ns.NS != nil {
+			rrset = ns.NS
+			break
+		}
+	}
+	if len(rrset) > 1 {
+		records := []string{}
+		for _, rr := range rrset {
+			records = append(records, rr.String())
+		}
+		results = append(results, ReportResult{Result: "OK  : Multiple nameservers found",
+			Status: true, Records: records, Name: "Multiple"})
+	} else {
+		results = append(results, ReportResult{Result: fmt.Sprintf("WARN: Only %v nameserver found. Extra nameservers increases reliability", len(rrset)),
+			Status: false, Name: "Multiple", Remediation: "Add at least one more nameserver, preferably in another network."})
+	}
+
+	for _, ns := range c.NSCheck {
+		if ns.NS != nil {
+			if len(ns.CNAME) > 1 {
+				results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: NS %s is a CNAME for %s", ns.Name, ns.CNAME[0].(*dns.CNAME).Target),
+					Status: false, Name: "NSCNAME", Remediation: "Point the NS record at the canonical hostname (the CNAME target) instead of the alias."})
+			}
+		}
+	}
+	if !c.checkSameSubnet() {
+		results = append(results, ReportResult{Result: "OK  : Your nameservers are in different subnets.",
+			Status: true, Name: "Subnet"})
+	} else {
+		results = append(results, ReportResult{Result: "WARN: Your nameservers are in the same subnet.",
+			Status: false, Name: "Subnet", Remediation: "Move a nameserver to a different subnet or network."})
+	}
+	return results
+}
+
+func (c *NSCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "NS"
+	c.Report.Result = append(c.Report.Result, c.Identical())
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	if !c.NoOrigin {
+		c.Report.Result = append(c.Report.Result, c.ASN())
+	}
+	c.Report.Result = append(c.Report.Result, c.CheckProviders()...)
+	if !c.NoOrigin {
+		c.Report.Result = append(c.Report.Result, c.CheckCountries()...)
+	}
+	c.Report.Result = append(c.Report.Result, c.IPCheck()...)
+	c.Report.Result = append(c.Report.Result, c.Auth()...)
+	c.Report.Result = append(c.Report.Result, c.Recursive()...)
+	c.Report.Result = append(c.Report.Result, c.CheckParent(domain)...)
+	c.Report.Result = append(c.Report.Result, c.CheckParentTTL(domain)...)
+	c.Report.Result = append(c.Report.Result, c.CheckCase(domain)...)
+	c.Report.Result = append(c.Report.Result, c.CheckCNAME()...)
+	return c.Report
+}
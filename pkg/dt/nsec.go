@@ -0,0 +1,143 @@
+package dt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// nsec3MaxIterations is the iteration count above which validators may
+// treat the zone as insecure (RFC 9276 section 3.2).
+const nsec3MaxIterations = 100
+
+// NSECCheck finds out how the zone proves names don't exist: NSEC, which
+// lets anyone list the zone unless it is signed online with minimal covering
+// records, or NSEC3, whose parameters are checked against RFC 9276.
+type NSECCheck struct {
+	NS     []NSData
+	Server string
+	// NSEC and NSEC3 are the records proving a random name doesn't exist.
+	NSEC       []*dns.NSEC
+	NSEC3      []*dns.NSEC3
+	NSEC3PARAM *dns.NSEC3PARAM
+	Err        string
+	Report
+}
+
+func (c *NSECCheck) Scan(domain string) {
+	zone := dns.Fqdn(domain)
+	server, ok := c.s.respondingServer(c.NS, zone)
+	if !ok {
+		c.Err = "no nameserver answers"
+		return
+	}
+	c.Server = server
+	if rrs, _, err := c.s.queryRRset(zone, dns.TypeNSEC3PARAM, server, true); err == nil {
+		for _, rr := range rrs {
+			c.NSEC3PARAM = rr.(*dns.NSEC3PARAM)
+		}
+	}
+	// NXDOMAIN is an error to query, the response still holds the proof
+	res, err := c.s.query(fmt.Sprintf("dt%v.%s", dns.Id(), zone), dns.TypeA, server, true)
+	if res.Msg == nil {
+		c.Err = err.Error()
+		return
+	}
+	for _, rr := range res.Msg.Ns {
+		switch rr := rr.(type) {
+		case *dns.NSEC:
+			c.NSEC = append(c.NSEC, rr)
+		case *dns.NSEC3:
+			c.NSEC3 = append(c.NSEC3, rr)
+		}
+	}
+}
+
+// minimalCovering reports whether the NSEC records only cover the name
+// asked, as online signers do (RFC 4470): their next names are made up by
+// adding a zero octet, so they reveal no other names.
+func minimalCovering(nsecs []*dns.NSEC) bool {
+	for _, nsec := range nsecs {
+		if !strings.Contains(nsec.NextDomain, `\000`) {
+			return false
+		}
+	}
+	return len(nsecs) > 0
+}
+
+func (c *NSECCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	switch {
+	case c.Err != "":
+		return append(results, ReportResult{Result: fmt.Sprintf("ERR : Denial of existence not checked: %s", c.Err),
+			Status: false, Name: "Denial", Error: c.Err})
+	case len(c.NSEC) > 0 && minimalCovering(c.NSEC):
+		return append(results, ReportResult{Result: fmt.Sprintf("OK  : %s proves names don't exist with minimal NSEC records signed online, the zone can't be walked", c.Server),
+			Status: true, Name: "Denial"})
+	case len(c.NSEC) > 0:
+		var records []string
+		for _, nsec := range c.NSEC {
+			records = append(records, nsec.String())
+		}
+		return append(results, ReportResult{Result: fmt.Sprintf("WARN: The zone uses NSEC, anyone can list every name in it by walking the chain (%s to %s)", c.NSEC[0].Hdr.Name, c.NSEC[0].NextDomain),
+			Status: false, Name: "NSECWalk", Records: records, Remediation: "Switch to NSEC3 (without salt and iterations) if the names in the zone are not public."})
+	case len(c.NSEC3) == 0 && c.NSEC3PARAM == nil:
+		return append(results, ReportResult{Result: fmt.Sprintf("WARN: %s returns no NSEC or NSEC3 records for a name that doesn't exist, validators can't verify the denial", c.Server),
+			Status: false, Name: "Denial", Remediation: "Check the signer, a signed zone proves names don't exist with NSEC or NSEC3 records."})
+	}
+	hash, iterations, salt, optOut := uint8(0), uint16(0), "", false
+	if p := c.NSEC3PARAM; p != nil {
+		hash, iterations, salt = p.Hash, p.Iterations, p.Salt
+	}
+	// the NSEC3 records are what validators use, opt-out is only there
+	for _, n := range c.NSEC3 {
+		hash, iterations, salt = n.Hash, n.Iterations, n.Salt
+		optOut = optOut || n.Flags&1 != 0
+	}
+	if salt == "-" {
+		salt = ""
+	}
+	params := fmt.Sprintf("hash %v, %v iterations, salt %s", hash, iterations, salt)
+	if salt == "" {
+		params = fmt.Sprintf("hash %v, %v iterations, no salt", hash, iterations)
+	}
+	if hash != dns.SHA1 {
+		results = append(results, ReportResult{Result: fmt.Sprintf("WARN: NSEC3 uses unknown hash algorithm %v, validators treat the zone as insecure", hash),
+			Status: false, Name: "NSEC3Hash", Remediation: "Use hash algorithm 1 (SHA-1), the only one defined."})
+	}
+	switch {
+	case iterations > nsec3MaxIterations:
+		results = append(results, ReportResult{Result: fmt.Sprintf("WARN: NSEC3 uses %v iterations, validators may treat the zone as insecure above %v (RFC 9276)", iterations, nsec3MaxIterations),
+			Status: false, Name: "NSEC3Iterations", Remediation: "Set the NSEC3 iterations to 0."})
+	case iterations > 0:
+		results = append(results, ReportResult{Result: fmt.Sprintf("WARN: NSEC3 uses %v iterations, RFC 9276 says 0 as extra iterations cost resolvers without hindering zone walking", iterations),
+			Status: false, Name: "NSEC3Iterations", Remediation: "Set the NSEC3 iterations to 0."})
+	}
+	if salt != "" {
+		results = append(results, ReportResult{Result: fmt.Sprintf("WARN: NSEC3 uses a %v byte salt, RFC 9276 recommends none as it adds no protection", len(salt)/2),
+			Status: false, Name: "NSEC3Salt", Remediation: "Set the NSEC3 salt to - (empty)."})
+	}
+	if optOut {
+		results = append(results, ReportResult{Result: "WARN: NSEC3 uses opt-out, unsigned delegations can be inserted without detection, it is only meant for large delegation-centric zones (RFC 9276)",
+			Status: false, Name: "NSEC3OptOut", Remediation: "Sign without opt-out unless the zone has very many unsigned delegations."})
+	}
+	if len(results) == 0 {
+		results = append(results, ReportResult{Result: fmt.Sprintf("OK  : The zone uses NSEC3 with the parameters RFC 9276 recommends (%s)", params),
+			Status: true, Name: "Denial"})
+	} else {
+		results = append(results, ReportResult{Records: []string{fmt.Sprintf("NSEC3 %s", params)}})
+	}
+	return results
+}
+
+func (c *NSECCheck) Dependency() Dependency {
+	return Dependency{Type: "NSEC", Requires: []string{"DNSKEY"}}
+}
+
+func (c *NSECCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "NSEC"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
@@ -0,0 +1,137 @@
+package dt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// errOffline is returned by every query made in offline mode.
+var errOffline = errors.New("offline mode, no queries are sent")
+
+// offlineChecks are the checks of a full scan that need the network, with the
+// report type they are skipped under.
+var offlineChecks = []struct {
+	Type    string
+	Checker Checker
+}{
+	{"Apex", &ApexCheck{}},
+	{"ROOT", &RootCheck{}},
+	{"Parent zone", &ParentCheck{}},
+	{"NS", &NSCheck{}},
+	{"GLUE", &Glue{}},
+	{"Delegation", &DelegationCheck{}},
+	{"DS", &DSCheck{}},
+	{"RRSIG expiry", &RRSIGCheck{}},
+	{"NSEC", &NSECCheck{}},
+	{"ENT", &ENTCheck{}},
+	{"AXFR", &AXFRCheck{}},
+	{"Subzones", &SubzoneCheck{}},
+	{"Responses", &ResponseCheck{}},
+	{"EDNS", &EDNSCheck{}},
+	{"TCP", &TCPCheck{}},
+	{"SSHFP", &SSHFPCheck{}},
+	{"MX", &MXCheck{}},
+	{"Web", &WebCheck{}},
+	{"CAA", &CAACheck{}},
+	{"GeoDNS", &GeoDNSCheck{}},
+	{"DKIM", &DKIMCheck{}},
+	{"MTA-STS", &MTASTSCheck{}},
+	{"DANE", &DANECheck{}},
+	{"Mail keys", &MailKeyCheck{}},
+	{"ACME", &AcmeCheck{}},
+	{"Confusables", &ConfusableCheck{}},
+}
+
+// zoneSpam returns the SPF and DMARC records of domain in the zone records.
+func zoneSpam(records []ZoneRecord, domain, source string) SpamData {
+	apex := strings.ToLower(dns.Fqdn(domain))
+	data := SpamData{Name: source}
+	for _, r := range records {
+		if r.RR.Header().Rrtype != dns.TypeTXT {
+			continue
+		}
+		switch strings.ToLower(r.RR.Header().Name) {
+		case apex:
+			if strings.Contains(r.RR.String(), "v=spf") {
+				data.Spf = append(data.Spf, r.RR)
+			}
+		case "_dmarc." + apex:
+			data.Dmarc = append(data.Dmarc, r.RR)
+		}
+	}
+	return data
+}
+
+// offlineScan runs the checks that need no network on the zone file of opts:
+// the zone data lint, the signature timing of the RRSIGs in the file, the
+// SPF and DMARC syntax and the serial change from opts.LastSerial. The other
+// checks are reported as skipped.
+func (s *session) offlineScan(domain string, opts Options, profile Profile) (*Result, error) {
+	if opts.ZoneFile == "" {
+		return nil, fmt.Errorf("offline mode needs a zone file")
+	}
+	// only the apex and _dmarc records are kept, the zone data and
+	// signature checks stream the file themselves
+	apex := strings.ToLower(dns.Fqdn(domain))
+	var records []ZoneRecord
+	err := streamZoneFile(opts.ZoneFile, domain, func(r ZoneRecord) {
+		if name := strings.ToLower(r.RR.Header().Name); name == apex || name == "_dmarc."+apex {
+			records = append(records, r)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reports := []Report{{Type: "DNSSEC", Result: []ReportResult{{Result: "SKIP: Chain of trust needs the network, not validated offline",
+		Status: true, Name: "Offline"}}}}
+	signed := false
+	for _, r := range records {
+		if r.RR.Header().Rrtype == dns.TypeDNSKEY && strings.EqualFold(r.RR.Header().Name, dns.Fqdn(domain)) {
+			signed = true
+		}
+	}
+	for _, c := range []Checker{&ZoneDataCheck{File: opts.ZoneFile}, &ZoneSigCheck{File: opts.ZoneFile}} {
+		if s.ctx.Err() != nil || !profile.Enabled(c) {
+			continue
+		}
+		if _, ok := c.(*ZoneSigCheck); ok && !signed {
+			reports = append(reports, Report{Type: "ZoneSignatures", Result: []ReportResult{{Result: "SKIP: No DNSKEY record at the apex",
+				Status: true, Name: "Skipped"}}})
+			continue
+		}
+		reports = append(reports, isolate(s.createReport(c, domain)))
+	}
+
+	soa := &SOACheck{LastSerial: opts.LastSerial}
+	for _, r := range records {
+		if s, ok := r.RR.(*dns.SOA); ok && strings.EqualFold(s.Hdr.Name, dns.Fqdn(domain)) {
+			soa.SOA = append(soa.SOA, SOAData{Name: r.Source, SOA: s})
+		}
+	}
+	if profile.Enabled(soa) && opts.LastSerial != 0 {
+		reports = append(reports, Report{Type: "SOA", Result: soa.CheckSerialChange()})
+	}
+
+	spam := &SpamCheck{Spam: []SpamData{zoneSpam(records, domain, opts.ZoneFile)}}
+	spam.bind(s)
+	if profile.Enabled(spam) {
+		report := Report{Type: "Spam", Result: spam.Values()}
+		report.Result = append(report.Result, spam.CheckDMARC(domain)...)
+		report.Result = append(report.Result, spam.CheckSPF(domain)...)
+		reports = append(reports, report)
+	}
+
+	for _, c := range offlineChecks {
+		if profile.Enabled(c.Checker) {
+			reports = append(reports, Report{Type: c.Type, Result: []ReportResult{{Result: "SKIP: Needs the network, not run offline",
+				Status: true, Name: "Offline"}}})
+		}
+	}
+	result := &Result{Domain: domain, Reports: reports}
+	result.Summary = summarize(result.Reports)
+	return result, s.ctx.Err()
+}
@@ -0,0 +1,96 @@
+package dt
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+type ParentCheck struct {
+	NS     []NSData
+	Parent []NSData
+	Zone   string
+	Data   []ParentData
+	Report
+}
+
+type ParentData struct {
+	Name   string
+	IP     string
+	UDP    bool
+	TCP    bool
+	EDNS   bool
+	DNSSEC bool
+	Error  string
+}
+
+func (c *ParentCheck) Scan(domain string) {
+	c.Zone = c.s.parentZone(domain)
+	parent, err := c.s.findNS(c.Zone)
+	if err != nil {
+		c.Report.Result = append(c.Report.Result, ReportResult{Result: fmt.Sprintf("ERR : Finding nameservers of %s failed: %s", c.Zone, err)})
+		return
+	}
+	c.Parent = parent
+	c.Data = make([]ParentData, serverCount(parent))
+	c.s.eachServer(parent, &c.Report, func(i int, ns NSData, nsip net.IP, r *Report) {
+		data := ParentData{Name: ns.Name, IP: ns.addr(nsip)}
+		res, err := c.s.query(c.Zone, dns.TypeSOA, ns.addr(nsip), true)
+		if err != nil {
+			data.Error = err.Error()
+			c.Data[i] = data
+			return
+		}
+		data.UDP = true
+		data.EDNS = res.Msg.IsEdns0() != nil
+		if _, err := c.s.queryNet(c.Zone, dns.TypeSOA, ns.addr(nsip), false, "tcp"); err == nil {
+			data.TCP = true
+		}
+		res, err = c.s.query(c.Zone, dns.TypeDNSKEY, ns.addr(nsip), true)
+		if err == nil && len(extractRR(res.Msg.Answer, dns.TypeRRSIG)) > 0 {
+			data.DNSSEC = true
+		}
+		c.Data[i] = data
+	})
+}
+
+func (c *ParentCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	ok := true
+	for _, data := range c.Data {
+		switch {
+		case !data.UDP:
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: %s (%s) is not reachable: %s", data.Name, data.IP, data.Error),
+				Status: false, Name: "Reachable", Remediation: "Report the unreachable parent nameserver to the registry or the operator of the parent zone."})
+			ok = false
+			continue
+		case !data.TCP:
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: %s (%s) does not answer over TCP", data.Name, data.IP),
+				Status: false, Name: "TCP", Remediation: "Report the missing TCP support to the registry or the operator of the parent zone."})
+			ok = false
+		}
+		if !data.EDNS {
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: %s (%s) does not support EDNS", data.Name, data.IP),
+				Status: false, Name: "EDNS", Remediation: "Report the missing EDNS support to the registry or the operator of the parent zone."})
+			ok = false
+		}
+		if !data.DNSSEC {
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: %s (%s) returns no signed DNSKEY for %s", data.Name, data.IP, c.Zone),
+				Status: false, Name: "DNSSEC", Remediation: "Expected for an unsigned parent. Otherwise report it to the operator of the parent zone."})
+			ok = false
+		}
+	}
+	if ok && len(c.Data) > 0 {
+		results = append(results, ReportResult{Result: fmt.Sprintf("OK  : All %v nameservers of %s are reachable over UDP and TCP, support EDNS and serve DNSSEC", len(c.Data), c.Zone),
+			Status: true, Name: "Health"})
+	}
+	return results
+}
+
+func (c *ParentCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = fmt.Sprintf("Parent zone (%s)", c.Zone)
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
@@ -0,0 +1,94 @@
+package dt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// parkingSuffixes are the nameserver and SOA hostnames of registrar parking
+// and domain marketplace services.
+var parkingSuffixes = []string{
+	".sedoparking.com.", ".parkingcrew.net.", ".bodis.com.", ".above.com.",
+	".dan.com.", ".afternic.com.", ".uniregistrymarket.link.", ".cashparking.com.",
+	".parklogic.com.", ".pendingrenewaldeletion.com.", ".internettraffic.com.",
+	".fabulous.com.", ".voodoo.com.", ".ztomy.com.",
+}
+
+// parkingService returns the parking service name is part of, or "".
+func parkingService(name string) string {
+	name = strings.ToLower(dns.Fqdn(name))
+	for _, suffix := range parkingSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return strings.Trim(suffix, ".")
+		}
+	}
+	return ""
+}
+
+// parkedSignals returns why domain looks parked: nameservers or a placeholder
+// SOA of a parking service, or a wildcard answering every name with the apex
+// address of a zone without mail. service is true when a parking service was
+// found, the wildcard alone is also how some live zones are set up.
+func (s *session) parkedSignals(domain string, nsdatas []NSData) (signals []string, service bool) {
+	services := make(map[string]bool)
+	for _, ns := range nsdatas {
+		if p := parkingService(ns.Name); p != "" && !services[p] {
+			services[p] = true
+			signals = append(signals, fmt.Sprintf("nameserver %s belongs to parking service %s", ns.Name, p))
+		}
+	}
+	server, ok := s.respondingServer(nsdatas, domain)
+	if !ok {
+		return signals, len(services) > 0
+	}
+	fqdn := dns.Fqdn(domain)
+	if soa, _, err := s.queryRRset(fqdn, dns.TypeSOA, server, false); err == nil && len(soa) > 0 {
+		rr := soa[0].(*dns.SOA)
+		for _, name := range []string{rr.Ns, rr.Mbox} {
+			if p := parkingService(name); p != "" && !services[p] {
+				services[p] = true
+				signals = append(signals, fmt.Sprintf("placeholder SOA (%s %s) of parking service %s", rr.Ns, rr.Mbox, p))
+			}
+		}
+	}
+
+	service = len(services) > 0
+	apex, _, err := s.queryRRset(fqdn, dns.TypeA, server, false)
+	if err != nil || len(apex) == 0 {
+		return signals, service
+	}
+	random, _, err := s.queryRRset(fmt.Sprintf("dt%v.%s", dns.Id(), fqdn), dns.TypeA, server, false)
+	if err != nil || len(random) == 0 {
+		return signals, service
+	}
+	apexIPs, randomIPs := ipStrings(extractIP(apex)), ipStrings(extractIP(random))
+	sort.Strings(apexIPs)
+	sort.Strings(randomIPs)
+	if strings.Join(apexIPs, " ") != strings.Join(randomIPs, " ") {
+		return signals, service
+	}
+	if mx, _, _ := s.queryRRset(fqdn, dns.TypeMX, server, false); len(mx) == 0 {
+		signals = append(signals, fmt.Sprintf("wildcard answers every name with the apex address %s and there is no MX", strings.Join(apexIPs, " ")))
+	}
+	return signals, service
+}
+
+// parkedReport replaces the checks of a parked domain.
+func parkedReport(domain string, signals []string) Report {
+	return Report{Type: "Parked", Result: []ReportResult{{
+		Result: fmt.Sprintf("WARN: %s looks parked, checks skipped (use -check-parked to run them):\n\t   %s", domain, strings.Join(signals, "\n\t   ")),
+		Status: false, Name: "Parked",
+		Remediation: "Delegate the domain to your own nameservers when it goes into use."}}}
+}
+
+// parkedHint labels a domain that only looks parked by its wildcard, its
+// checks still run.
+func parkedHint(domain string, signals []string) Report {
+	return Report{Type: "Parked", Result: []ReportResult{{
+		Result: fmt.Sprintf("WARN: %s may be parked:\n\t   %s", domain, strings.Join(signals, "\n\t   ")),
+		Status: false, Name: "Parked",
+		Remediation: "Remove the wildcard or add an MX record if the domain is in use."}}}
+}
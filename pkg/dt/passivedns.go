@@ -0,0 +1,156 @@
+package dt
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// pdnsRecent is the window in which changes seen in passive DNS are reported.
+const pdnsRecent = 30 * 24 * time.Hour
+
+// PassiveDNSProvider returns historical resolutions for a name.
+type PassiveDNSProvider interface {
+	Lookup(name string) ([]PassiveRecord, error)
+}
+
+type PassiveRecord struct {
+	Name      string
+	Type      string
+	Value     string
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// COFProvider queries a passive DNS server speaking the Common Output Format
+// (draft-dulaunoy-dnsop-passive-dns-cof), like CIRCL. Credentials can be
+// given as userinfo in the URL.
+type COFProvider struct {
+	URL string
+}
+
+type cofRecord struct {
+	RRName    string `json:"rrname"`
+	RRType    string `json:"rrtype"`
+	RData     string `json:"rdata"`
+	TimeFirst int64  `json:"time_first"`
+	TimeLast  int64  `json:"time_last"`
+}
+
+func (p *COFProvider) Lookup(name string) ([]PassiveRecord, error) {
+	var records []PassiveRecord
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(strings.TrimSuffix(p.URL, "/") + "/" + strings.TrimSuffix(name, "."))
+	if err != nil {
+		return records, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return records, fmt.Errorf("passive DNS lookup failed: %s", resp.Status)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var rec cofRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		records = append(records, PassiveRecord{Name: dns.Fqdn(rec.RRName), Type: rec.RRType, Value: rec.RData,
+			FirstSeen: time.Unix(rec.TimeFirst, 0), LastSeen: time.Unix(rec.TimeLast, 0)})
+	}
+	return records, scanner.Err()
+}
+
+type PassiveDNSCheck struct {
+	NS       []NSData
+	Provider PassiveDNSProvider
+	History  map[string][]PassiveRecord
+	Report
+}
+
+func (c *PassiveDNSCheck) Scan(domain string) {
+	c.History = make(map[string][]PassiveRecord)
+	names := []string{dns.Fqdn(domain)}
+	for _, ns := range c.NS {
+		names = append(names, dns.Fqdn(ns.Name))
+	}
+	for _, name := range names {
+		records, err := c.Provider.Lookup(name)
+		if err != nil {
+			c.Report.Result = append(c.Report.Result, ReportResult{Result: fmt.Sprintf("ERR : Passive DNS lookup of %s failed: %s", name, err)})
+			continue
+		}
+		if len(records) > 0 {
+			c.History[name] = records
+		}
+	}
+}
+
+// compare reports values of qtype at name that appeared or disappeared within
+// pdnsRecent, given the values currently served.
+func (c *PassiveDNSCheck) compare(name, qtype string, current []string) []ReportResult {
+	rep := []ReportResult{}
+	cur := make(map[string]bool)
+	for _, v := range current {
+		cur[strings.ToLower(dns.Fqdn(v))] = true
+	}
+	seen := make(map[string]bool)
+	for _, rec := range c.History[name] {
+		if rec.Type != qtype {
+			continue
+		}
+		value := strings.ToLower(dns.Fqdn(rec.Value))
+		seen[value] = true
+		if cur[value] && time.Since(rec.FirstSeen) < pdnsRecent {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("WARN: %s %s %s was first seen %s", name, qtype, rec.Value, FormatWhen(rec.FirstSeen, c.s.useUTC)),
+				Status: false, Name: "Recent", Remediation: "Verify this change was made by you, an unexpected new value can mean a hijack."})
+		}
+		if !cur[value] && time.Since(rec.LastSeen) < pdnsRecent {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("WARN: %s %s %s is no longer served (last seen %s)", name, qtype, rec.Value, FormatWhen(rec.LastSeen, c.s.useUTC)),
+				Status: false, Name: "Removed", Remediation: "Verify this removal was intended."})
+		}
+	}
+	if len(c.History[name]) > 0 {
+		for _, v := range current {
+			if !seen[strings.ToLower(dns.Fqdn(v))] {
+				rep = append(rep, ReportResult{Result: fmt.Sprintf("WARN: %s %s %s was never seen in passive DNS", name, qtype, v),
+					Status: false, Name: "Unseen", Remediation: "Verify this value was added by you, passive DNS may lag behind recent changes."})
+			}
+		}
+	}
+	return rep
+}
+
+func (c *PassiveDNSCheck) Values(domain string) []ReportResult {
+	results := []ReportResult{}
+	var names []string
+	for _, ns := range c.NS {
+		names = append(names, ns.Name)
+	}
+	results = append(results, c.compare(dns.Fqdn(domain), "NS", names)...)
+	for _, ns := range c.NS {
+		var ips []string
+		for _, ip := range ns.IP {
+			if ip.To4() != nil {
+				ips = append(ips, ip.String())
+			}
+		}
+		results = append(results, c.compare(dns.Fqdn(ns.Name), "A", ips)...)
+	}
+	if len(results) == 0 && len(c.History) > 0 {
+		results = append(results, ReportResult{Result: "OK  : No recent changes in passive DNS for your NS records",
+			Status: true, Name: "Recent"})
+	}
+	return results
+}
+
+func (c *PassiveDNSCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "Passive DNS"
+	c.Report.Result = append(c.Report.Result, c.Values(domain)...)
+	return c.Report
+}
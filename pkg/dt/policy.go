@@ -0,0 +1,165 @@
+package dt
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// grades are the summary grades from best to worst.
+var grades = []string{"A", "B", "C", "D", "F"}
+
+// Policy is what an organization requires of a domain, a compliance gate
+// for changes.
+type Policy struct {
+	// MinGrade is the worst acceptable grade, "" for any.
+	MinGrade string
+	// Require are checks that must run and pass.
+	Require []string
+	// Forbid are findings that must not occur.
+	Forbid []PolicyFinding
+	// Max is the maximum number of results of a severity.
+	Max map[string]int
+}
+
+// PolicyFinding is a finding of Level or worse, in Check or in any check
+// when Check is empty.
+type PolicyFinding struct {
+	Level string
+	Check string
+}
+
+// Verdict is the outcome of a policy.
+type Verdict struct {
+	Pass       bool
+	Violations []string `json:",omitempty"`
+}
+
+// LoadPolicy reads a policy with one rule per line:
+//
+//	grade B            the grade must be B or better
+//	require DNSSEC     the DNSSEC check must run and pass
+//	forbid FAIL        no FAIL in any check
+//	forbid WARN SPF    no WARN or worse in the SPF check
+//	max WARN 5         at most 5 WARN results
+//
+// Checks are a report type, or a report type and result name ("MX Multiple")
+// as in compare and diff.
+func LoadPolicy(file string) (*Policy, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p := &Policy{Max: make(map[string]int)}
+	level := func(s string) (string, error) {
+		s = strings.ToUpper(s)
+		if _, ok := resultSeverity[s]; !ok || s == "SKIP" || s == "OK" {
+			return "", fmt.Errorf("unknown severity %s in %s (use WARN, ERR or FAIL)", s, file)
+		}
+		return s, nil
+	}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%v: %s needs an argument", file, n, fields[0])
+		}
+		switch strings.ToLower(fields[0]) {
+		case "grade":
+			p.MinGrade = strings.ToUpper(fields[1])
+			if gradeRank(p.MinGrade) < 0 {
+				return nil, fmt.Errorf("%s:%v: unknown grade %s (use %s)", file, n, fields[1], strings.Join(grades, ", "))
+			}
+		case "require":
+			p.Require = append(p.Require, strings.Join(fields[1:], " "))
+		case "forbid":
+			l, err := level(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			p.Forbid = append(p.Forbid, PolicyFinding{Level: l, Check: strings.Join(fields[2:], " ")})
+		case "max":
+			l, err := level(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("%s:%v: max needs a severity and a count", file, n)
+			}
+			if p.Max[l], err = strconv.Atoi(fields[2]); err != nil {
+				return nil, fmt.Errorf("%s:%v: %s is not a count", file, n, fields[2])
+			}
+		default:
+			return nil, fmt.Errorf("%s:%v: unknown rule %s (use grade, require, forbid or max)", file, n, fields[0])
+		}
+	}
+	return p, scanner.Err()
+}
+
+// gradeRank returns the position of grade in grades, -1 for none like the
+// grade of a parked domain.
+func gradeRank(grade string) int {
+	for i, g := range grades {
+		if g == grade {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkMatches reports whether check selects the result res of report.
+func checkMatches(check string, report Report, res ReportResult) bool {
+	return strings.EqualFold(check, report.Type) || strings.EqualFold(check, report.Type+" "+res.Name)
+}
+
+// Evaluate checks result against the policy.
+func (p *Policy) Evaluate(result *Result) Verdict {
+	var violations []string
+	if p.MinGrade != "" {
+		if rank := gradeRank(result.Summary.Grade); rank < 0 || rank > gradeRank(p.MinGrade) {
+			violations = append(violations, fmt.Sprintf("grade %s is worse than the required %s", result.Summary.Grade, p.MinGrade))
+		}
+	}
+	for _, check := range p.Require {
+		ran := false
+		for _, report := range result.Reports {
+			for _, res := range report.Result {
+				status := resultStatus(res)
+				if status == "" || !checkMatches(check, report, res) {
+					continue
+				}
+				ran = true
+				if status != "OK" {
+					violations = append(violations, fmt.Sprintf("required %s: %s", check, strings.SplitN(res.Result, "\n", 2)[0]))
+				}
+			}
+		}
+		if !ran {
+			violations = append(violations, fmt.Sprintf("required %s didn't run", check))
+		}
+	}
+	for _, f := range p.Forbid {
+		for _, report := range result.Reports {
+			for _, res := range report.Result {
+				if f.Check != "" && !checkMatches(f.Check, report, res) {
+					continue
+				}
+				if resultSeverity[resultStatus(res)] >= resultSeverity[f.Level] {
+					violations = append(violations, fmt.Sprintf("forbidden %s: %s: %s", f.Level, report.Type, strings.SplitN(res.Result, "\n", 2)[0]))
+				}
+			}
+		}
+	}
+	for _, l := range []string{"WARN", "ERR", "FAIL"} {
+		if max, ok := p.Max[l]; ok && result.Summary.Counts[l] > max {
+			violations = append(violations, fmt.Sprintf("%v %s results, at most %v allowed", result.Summary.Counts[l], l, max))
+		}
+	}
+	return Verdict{Pass: len(violations) == 0, Violations: violations}
+}
@@ -0,0 +1,88 @@
+package dt
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		want   *Policy
+		err    string
+	}{
+		{"empty", "# nothing\n\n", &Policy{Max: map[string]int{}}, ""},
+		{"rules", "grade b\nrequire DNSSEC\nrequire MX Multiple\nforbid fail\nforbid WARN SPF\nmax warn 5\n",
+			&Policy{MinGrade: "B", Require: []string{"DNSSEC", "MX Multiple"},
+				Forbid: []PolicyFinding{{Level: "FAIL"}, {Level: "WARN", Check: "SPF"}}, Max: map[string]int{"WARN": 5}}, ""},
+		{"unknown grade", "grade E", nil, "unknown grade E"},
+		{"unknown rule", "allow FAIL", nil, "unknown rule allow"},
+		{"no argument", "require", nil, "require needs an argument"},
+		{"ok is no finding", "forbid OK", nil, "unknown severity OK"},
+		{"max without count", "max WARN", nil, "max needs a severity and a count"},
+		{"max not a count", "max ERR many", nil, "many is not a count"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "policy")
+			if err := os.WriteFile(file, []byte(tt.policy), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadPolicy(file)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	result := &Result{
+		Summary: Summary{Grade: "C", Counts: map[string]int{"OK": 2, "WARN": 2, "FAIL": 1}},
+		Reports: []Report{
+			{Type: "MX", Result: []ReportResult{
+				{Result: "OK  : Multiple MX records", Status: true, Name: "Multiple"},
+				{Result: "WARN: MX points at a CNAME", Name: "CNAME"},
+			}},
+			{Type: "SPF", Result: []ReportResult{{Result: "FAIL: 11 DNS lookups", Name: "SPF"}}},
+			{Type: "DNSSEC", Result: []ReportResult{{Result: "OK  : DNSSEC validated", Status: true}}},
+			{Type: "CAA", Result: []ReportResult{{Result: "WARN: No CAA records"}}},
+		},
+	}
+	tests := []struct {
+		name       string
+		policy     Policy
+		violations []string
+	}{
+		{"none", Policy{}, nil},
+		{"grade met", Policy{MinGrade: "C"}, nil},
+		{"grade", Policy{MinGrade: "B"}, []string{"grade C is worse than the required B"}},
+		{"require", Policy{Require: []string{"dnssec", "MX Multiple"}}, nil},
+		{"require failing", Policy{Require: []string{"MX"}}, []string{"required MX: WARN: MX points at a CNAME"}},
+		{"require missing", Policy{Require: []string{"DANE"}}, []string{"required DANE didn't run"}},
+		{"forbid", Policy{Forbid: []PolicyFinding{{Level: "FAIL"}}}, []string{"forbidden FAIL: SPF: FAIL: 11 DNS lookups"}},
+		{"forbid in check", Policy{Forbid: []PolicyFinding{{Level: "WARN", Check: "MX"}}}, []string{"forbidden WARN: MX: WARN: MX points at a CNAME"}},
+		{"max", Policy{Max: map[string]int{"WARN": 1, "FAIL": 1}}, []string{"2 WARN results, at most 1 allowed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.Evaluate(result)
+			if !reflect.DeepEqual(got.Violations, tt.violations) || got.Pass != (len(tt.violations) == 0) {
+				t.Errorf("got %+v, want violations %q", got, tt.violations)
+			}
+		})
+	}
+}
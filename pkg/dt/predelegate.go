@@ -0,0 +1,231 @@
+package dt
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// PredelegateCheck audits nameservers that aren't delegated yet, see -ns.
+type PredelegateCheck struct {
+	NS   []NSData
+	Data []PredelegateData
+	DS   []dns.RR
+	Report
+}
+
+type PredelegateData struct {
+	Name   string
+	IP     string
+	Auth   bool
+	SOA    *dns.SOA
+	NS     []dns.RR
+	Keys   []dns.RR
+	Signed []dns.RR
+	Error  string
+}
+
+func (c *PredelegateCheck) Scan(domain string) {
+	c.Data = make([]PredelegateData, serverCount(c.NS))
+	c.s.eachServer(c.NS, &c.Report, func(i int, ns NSData, nsip net.IP, r *Report) {
+		data := PredelegateData{Name: ns.Name, IP: ns.addr(nsip)}
+		res, err := c.s.query(domain, dns.TypeSOA, ns.addr(nsip), true)
+		if err != nil {
+			data.Error = err.Error()
+			c.Data[i] = data
+			return
+		}
+		data.Auth = res.Msg.Authoritative
+		if soa := extractRR(res.Msg.Answer, dns.TypeSOA); len(soa) > 0 {
+			data.SOA = soa[0].(*dns.SOA)
+		}
+		data.Signed = res.Msg.Answer
+		data.NS, _, _ = c.s.queryRRset(domain, dns.TypeNS, ns.addr(nsip), false)
+		data.Keys, _, _ = c.s.queryRRset(domain, dns.TypeDNSKEY, ns.addr(nsip), true)
+		c.Data[i] = data
+	})
+	c.DS, _, _ = c.s.queryRRset(domain, dns.TypeDS, c.s.resolver, false)
+}
+
+func rrStrings(rrset []dns.RR) string {
+	var s []string
+	for _, rr := range rrset {
+		s = append(s, strings.ToLower(rr.String()[len(rr.Header().String()):]))
+	}
+	sort.Strings(s)
+	return strings.Join(s, " ")
+}
+
+// CheckServers verifies every server answers authoritatively with the same
+// SOA and NS records.
+func (c *PredelegateCheck) CheckServers() []ReportResult {
+	rep := []ReportResult{}
+	serials := make(map[uint32]bool)
+	nssets := make(map[string]bool)
+	for _, data := range c.Data {
+		switch {
+		case data.Error != "":
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: %s (%s) does not answer: %s", data.Name, data.IP, data.Error),
+				Status: false, Name: "SOA", Remediation: "Make sure the new nameserver is running and reachable before changing the delegation."})
+			continue
+		case data.SOA == nil:
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: %s (%s) has no SOA record", data.Name, data.IP),
+				Status: false, Name: "SOA", Remediation: "Load the zone on the new nameserver before changing the delegation."})
+			continue
+		case !data.Auth:
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: %s (%s) does not answer authoritatively", data.Name, data.IP),
+				Status: false, Name: "Auth", Remediation: "Configure the new nameserver as authoritative for the zone."})
+		}
+		serials[data.SOA.Serial] = true
+		nssets[rrStrings(data.NS)] = true
+	}
+	if len(serials) > 1 {
+		rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: Servers answer with %v different SOA serials", len(serials)),
+			Status: false, Name: "Consistent", Remediation: "Wait for the zone to transfer to all new nameservers, or fix the zone transfers."})
+	}
+	if len(nssets) > 1 {
+		rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: Servers answer with %v different NS RRsets", len(nssets)),
+			Status: false, Name: "Consistent", Remediation: "Serve the same NS records on all new nameservers."})
+	}
+	if len(rep) == 0 {
+		rep = append(rep, ReportResult{Result: "OK  : All servers answer authoritatively with the same SOA and NS records",
+			Status: true, Name: "Consistent"})
+	}
+	return rep
+}
+
+// CheckNS verifies the NS RRset served lists the servers being delegated to
+// and that in-bailiwick nameservers have the address records needed as glue.
+func (c *PredelegateCheck) CheckNS(domain string) []ReportResult {
+	rep := []ReportResult{}
+	var data *PredelegateData
+	for i := range c.Data {
+		if len(c.Data[i].NS) > 0 {
+			data = &c.Data[i]
+			break
+		}
+	}
+	if data == nil {
+		return append(rep, ReportResult{Result: "FAIL: None of the servers serve NS records",
+			Status: false, Name: "NS", Remediation: "Add NS records for the new nameservers to the zone."})
+	}
+	served := make(map[string]bool)
+	for _, rr := range data.NS {
+		served[strings.ToLower(rr.(*dns.NS).Ns)] = true
+	}
+	for _, ns := range c.NS {
+		if net.ParseIP(ns.Name) == nil && !served[strings.ToLower(ns.Name)] {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: %s is not listed in the NS records served", ns.Name),
+				Status: false, Name: "NS", Remediation: "Add the nameserver to the NS records of the zone."})
+		}
+	}
+	for _, rr := range data.NS {
+		name := rr.(*dns.NS).Ns
+		if !dns.IsSubDomain(dns.Fqdn(domain), name) {
+			continue
+		}
+		var glue []dns.RR
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			rrset, _, _ := c.s.queryRRset(name, qtype, data.IP, false)
+			glue = append(glue, rrset...)
+		}
+		if len(glue) == 0 {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: %s needs glue but has no address records on %s", name, data.Name),
+				Status: false, Name: "Glue", Remediation: "Add A/AAAA records for the in-zone nameserver and register them as glue."})
+			continue
+		}
+		var ips []string
+		for _, ip := range extractIP(glue) {
+			ips = append(ips, ip.String())
+		}
+		rep = append(rep, ReportResult{Result: fmt.Sprintf("OK  : Register glue %s %s at the parent", name, strings.Join(ips, " ")),
+			Status: true, Name: "Glue"})
+	}
+	if len(rep) == 0 {
+		rep = append(rep, ReportResult{Result: "OK  : NS records list all servers and no glue is needed",
+			Status: true, Name: "NS"})
+	}
+	return rep
+}
+
+// CheckDNSSEC verifies the DNSKEYs served validate and match the DS records
+// currently published at the parent.
+func (c *PredelegateCheck) CheckDNSSEC() []ReportResult {
+	rep := []ReportResult{}
+	for _, data := range c.Data {
+		if data.SOA == nil {
+			continue
+		}
+		if len(data.Keys) == 0 {
+			if len(c.DS) > 0 {
+				rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: %s (%s) serves no DNSKEY but the parent has DS records. Validation will fail after the cutover.", data.Name, data.IP),
+					Status: false, Name: "DNSSEC", Remediation: "Sign the zone on the new nameservers, or remove the DS records at the parent first."})
+			}
+			continue
+		}
+		if valid, _, err := c.s.validateRRSIG(data.Keys, data.Signed); !valid {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: %s (%s) serves a SOA that doesn't validate: %v", data.Name, data.IP, err),
+				Status: false, Name: "DNSSEC", Remediation: "Sign the zone on the new nameservers with valid signatures before the cutover."})
+		}
+		if len(c.DS) > 0 && !dsMatches(c.DS, data.Keys) {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: DS records at the parent don't match the DNSKEYs of %s (%s). Validation will fail after the cutover.", data.Name, data.IP),
+				Status: false, Name: "DNSSEC", Remediation: "Publish the DS records of the new keys at the parent before the cutover."})
+		}
+	}
+	if len(rep) == 0 {
+		switch {
+		case len(c.DS) > 0:
+			rep = append(rep, ReportResult{Result: "OK  : DNSKEYs are signed and match the DS records at the parent",
+				Status: true, Name: "DNSSEC"})
+		case len(c.Data) > 0 && len(c.Data[0].Keys) > 0:
+			rep = append(rep, ReportResult{Result: "OK  : DNSKEYs are signed. Publish the DS records after the cutover.",
+				Status: true, Name: "DNSSEC"})
+		default:
+			rep = append(rep, ReportResult{Result: "OK  : Zone is unsigned and the parent has no DS records",
+				Status: true, Name: "DNSSEC"})
+		}
+	}
+	return rep
+}
+
+// dsMatches reports whether one of the DS records matches one of the keys.
+func dsMatches(dsset []dns.RR, keys []dns.RR) bool {
+	for _, rr := range dsset {
+		ds := rr.(*dns.DS)
+		for _, k := range keys {
+			key := k.(*dns.DNSKEY)
+			if key.KeyTag() != ds.KeyTag {
+				continue
+			}
+			if kds := key.ToDS(ds.DigestType); kds != nil && strings.EqualFold(kds.Digest, ds.Digest) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *PredelegateCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "Predelegation"
+	c.Report.Result = append(c.Report.Result, c.CheckServers()...)
+	c.Report.Result = append(c.Report.Result, c.CheckNS(domain)...)
+	c.Report.Result = append(c.Report.Result, c.CheckDNSSEC()...)
+	failed := 0
+	for _, res := range c.Report.Result {
+		if strings.HasPrefix(res.Result, "FAIL") {
+			failed++
+		}
+	}
+	if failed > 0 {
+		c.Report.Result = append(c.Report.Result, ReportResult{Result: fmt.Sprintf("FAIL: NO-GO, %v readiness checks failed", failed),
+			Status: false, Name: "Summary", Remediation: "Fix the failed readiness checks above before changing the delegation."})
+	} else {
+		c.Report.Result = append(c.Report.Result, ReportResult{Result: "OK  : GO, the servers are ready to be delegated to",
+			Status: true, Name: "Summary"})
+	}
+	return c.Report
+}
@@ -0,0 +1,86 @@
+package dt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Profile is a named preset of check groups and flag values.
+type Profile struct {
+	// Groups are the check groups to run, all groups when empty.
+	Groups []string
+	// Flags are applied by the dt command unless set explicitly on the
+	// command line.
+	Flags map[string]string
+}
+
+// Profiles are the presets selectable by name.
+var Profiles = map[string]Profile{
+	"quick": {
+		Groups: []string{"apex", "delegation", "mail", "web"},
+		Flags:  map[string]string{"probes": "1", "timeout": "1s"},
+	},
+	"standard": {},
+	"paranoid": {
+		Flags: map[string]string{"probes": "10", "timeout": "5s", "web": "true", "tls": "true",
+			"autodiscover": "true", "resolvertest": "true", "scan": "true", "ct": "true"},
+	},
+	"mail-only": {
+		Groups: []string{"apex", "mail"},
+		Flags:  map[string]string{"autodiscover": "true"},
+	},
+	"dnssec-only": {
+		Groups: []string{"apex", "dnssec"},
+	},
+}
+
+// checkerGroups returns the check groups a checker belongs to.
+func checkerGroups(c Checker) []string {
+	switch c.(type) {
+	case *ApexCheck:
+		return []string{"apex"}
+	case *ParentCheck, *DSCheck:
+		return []string{"delegation", "dnssec"}
+	case *ZoneSigCheck, *RRSIGCheck, *NSECCheck:
+		return []string{"dnssec"}
+	case *MXCheck, *SpamCheck, *DKIMCheck, *MTASTSCheck, *DANECheck, *MailKeyCheck, *AutodiscoverCheck:
+		return []string{"mail"}
+	case *WebCheck, *HTTPCheck, *TLSCheck, *AcmeCheck, *CAACheck:
+		return []string{"web"}
+	case *AXFRCheck, *SSHFPCheck, *ConfusableCheck, *ThreatCheck:
+		return []string{"security"}
+	case *EntropyCheck:
+		return []string{"resolver"}
+	}
+	return []string{"delegation"}
+}
+
+// LookupProfile returns the profile called name.
+func LookupProfile(name string) (Profile, error) {
+	p, ok := Profiles[name]
+	if !ok {
+		var names []string
+		for n := range Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return p, fmt.Errorf("unknown profile %s (use one of %s)", name, strings.Join(names, ", "))
+	}
+	return p, nil
+}
+
+// Enabled reports whether checker c is part of the profile.
+func (p Profile) Enabled(c Checker) bool {
+	if len(p.Groups) == 0 {
+		return true
+	}
+	for _, g := range checkerGroups(c) {
+		for _, pg := range p.Groups {
+			if g == pg {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package dt
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// dnsProviders maps nameserver hostname suffixes to the DNS provider.
+var dnsProviders = []struct {
+	Suffix   string
+	Provider string
+}{
+	{"awsdns", "Amazon Route 53"},
+	{".cloudflare.com.", "Cloudflare"},
+	{".domaincontrol.com.", "GoDaddy"},
+	{".azure-dns.", "Azure DNS"},
+	{".googledomains.com.", "Google Cloud DNS"},
+	{".google.com.", "Google"},
+	{".nsone.net.", "NS1"},
+	{".dynect.net.", "Oracle Dyn"},
+	{".ultradns.", "UltraDNS"},
+	{".akam.net.", "Akamai"},
+	{".dnsmadeeasy.com.", "DNS Made Easy"},
+	{".digitalocean.com.", "DigitalOcean"},
+	{".linode.com.", "Linode"},
+	{".hetzner.com.", "Hetzner"},
+	{".hetzner.de.", "Hetzner"},
+	{".ovh.net.", "OVH"},
+	{".registrar-servers.com.", "Namecheap"},
+	{".name-services.com.", "eNom"},
+	{".worldnic.com.", "Network Solutions"},
+	{".dnsimple.com.", "DNSimple"},
+	{".gandi.net.", "Gandi"},
+	{".he.net.", "Hurricane Electric"},
+	{".transip.net.", "TransIP"},
+	{".transip.nl.", "TransIP"},
+	{".easydns.", "easyDNS"},
+	{".cscdns.net.", "CSC"},
+	{".markmonitor.com.", "MarkMonitor"},
+	{".verisigndns.com.", "Verisign"},
+	{".vercel-dns.com.", "Vercel"},
+	{".netlify.com.", "Netlify"},
+}
+
+// providerStatusPages are the Statuspage status APIs of DNS providers.
+var providerStatusPages = map[string]string{
+	"Cloudflare":   "https://www.cloudflarestatus.com/api/v2/status.json",
+	"DigitalOcean": "https://status.digitalocean.com/api/v2/status.json",
+	"Linode":       "https://status.linode.com/api/v2/status.json",
+	"DNSimple":     "https://dnsimplestatus.com/api/v2/status.json",
+	"NS1":          "https://status.ns1.com/api/v2/status.json",
+	"Netlify":      "https://www.netlifystatus.com/api/v2/status.json",
+	"Vercel":       "https://www.vercel-status.com/api/v2/status.json",
+}
+
+// providerFailures is the number of failing checks after which the status
+// pages of the providers are consulted.
+const providerFailures = 3
+
+// fetchProviderStatus returns the indicator (none, minor, major, critical)
+// and description of a Statuspage status API.
+func (s *session) fetchProviderStatus(url string) (string, string, error) {
+	if s.intranet {
+		return "", "", errIntranet
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("fetching %s failed: %s", url, resp.Status)
+	}
+	var status struct {
+		Status struct {
+			Indicator   string `json:"indicator"`
+			Description string `json:"description"`
+		} `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return "", "", err
+	}
+	return status.Status.Indicator, status.Status.Description, nil
+}
+
+// providerStatus adds the status of the DNS providers of nsdatas when many
+// checks in reports failed, so failures caused by a provider incident are
+// recognized as such.
+func (s *session) providerStatus(nsdatas []NSData, reports []Report) (Report, bool) {
+	report := Report{Type: "Provider status"}
+	failed := 0
+	for _, r := range reports {
+		for _, res := range r.Result {
+			if strings.HasPrefix(res.Result, "FAIL") || strings.HasPrefix(res.Result, "ERR") {
+				failed++
+			}
+		}
+	}
+	if failed < providerFailures {
+		return report, false
+	}
+	m := make(map[string]bool)
+	for _, ns := range nsdatas {
+		m[s.nsProvider(ns)] = true
+	}
+	var providers []string
+	for p := range m {
+		if _, ok := providerStatusPages[p]; ok {
+			providers = append(providers, p)
+		}
+	}
+	sort.Strings(providers)
+	for _, p := range providers {
+		indicator, description, err := s.fetchProviderStatus(providerStatusPages[p])
+		switch {
+		case err != nil:
+			report.Result = append(report.Result, ReportResult{Result: fmt.Sprintf("ERR : Fetching the status of %s failed: %s", p, err)})
+		case indicator != "none":
+			report.Result = append(report.Result, ReportResult{Result: fmt.Sprintf("WARN: %s reports an incident (%s): %s. %v failing checks may be caused by it.", p, indicator, description, failed),
+				Status: false, Name: "Incident", Remediation: "Follow the status page of the provider and check the failing results again after the incident."})
+		default:
+			report.Result = append(report.Result, ReportResult{Result: fmt.Sprintf("OK  : %s reports no incident: %s", p, description),
+				Status: true, Name: "Incident"})
+		}
+	}
+	return report, len(report.Result) > 0
+}
+
+// nsProvider returns the DNS provider of a nameserver. Unknown nameservers
+// are identified by the organizational domain of their hostname, or by the AS
+// of their addresses when the hostname has none, like an address given with
+// -ns.
+func (s *session) nsProvider(ns NSData) string {
+	name := strings.ToLower(dns.Fqdn(ns.Name))
+	for _, p := range dnsProviders {
+		if strings.Contains(name, p.Suffix) {
+			return p.Provider
+		}
+	}
+	host, org := strings.TrimSuffix(name, "."), strings.TrimSuffix(s.orgDomain(name), ".")
+	if net.ParseIP(host) != nil {
+		org = host
+	} else if org != host {
+		return org
+	}
+	for _, ip := range ns.IP {
+		if info, err := s.ipinfo(ip); err == nil {
+			return info.ASN.String()
+		}
+	}
+	return org
+}
@@ -0,0 +1,97 @@
+package dt
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// builtinPSL are the multi-label public suffixes used when no public suffix
+// list is given. Single label TLDs are covered by the implicit "*" rule.
+const builtinPSL = `
+co.uk org.uk ac.uk gov.uk me.uk ltd.uk plc.uk net.uk sch.uk nhs.uk
+com.au net.au org.au edu.au gov.au asn.au id.au
+co.nz org.nz net.nz govt.nz ac.nz
+co.jp ne.jp or.jp ac.jp go.jp
+co.za org.za net.za gov.za
+com.br net.br org.br gov.br
+com.cn net.cn org.cn gov.cn edu.cn
+com.mx org.mx gob.mx
+co.in net.in org.in gov.in
+co.kr or.kr go.kr
+com.tr org.tr gov.tr
+com.tw org.tw
+com.sg org.sg gov.sg
+com.hk org.hk
+co.il org.il ac.il
+com.ar gob.ar
+co.id or.id go.id
+com.my org.my gov.my
+com.ph com.vn com.pl com.es com.ua com.ru
+co.at or.at gv.at
+github.io herokuapp.com blogspot.com cloudfront.net azurewebsites.net appspot.com
+`
+
+// defaultPSL holds the builtin public suffix rules.
+var defaultPSL = parsePSL(strings.NewReader(builtinPSL))
+
+// parsePSL reads rules in public_suffix_list.dat format.
+func parsePSL(r io.Reader) map[string]bool {
+	rules := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		for _, rule := range strings.Fields(line) {
+			rules[strings.ToLower(rule)] = true
+		}
+	}
+	return rules
+}
+
+// loadPSL replaces the builtin public suffix rules with the ones in file.
+func (s *session) loadPSL(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s.psl = parsePSL(f)
+	return nil
+}
+
+// publicSuffix returns the number of labels of the public suffix of labels,
+// following the PSL algorithm (exceptions, wildcards, implicit "*").
+func (s *session) publicSuffix(labels []string) int {
+	best := 1
+	for i := range labels {
+		suffix := strings.Join(labels[i:], ".")
+		n := len(labels) - i
+		if s.psl["!"+suffix] {
+			return n - 1
+		}
+		if s.psl[suffix] && n > best {
+			best = n
+		}
+		if i+1 < len(labels) && s.psl["*."+strings.Join(labels[i+1:], ".")] && n > best {
+			best = n
+		}
+	}
+	return best
+}
+
+// orgDomain returns the organizational domain of name (RFC 7489 section 3.2),
+// the public suffix plus one label.
+func (s *session) orgDomain(name string) string {
+	labels := dns.SplitDomainName(strings.ToLower(dns.Fqdn(name)))
+	n := s.publicSuffix(labels) + 1
+	if n > len(labels) {
+		return dns.Fqdn(name)
+	}
+	return dns.Fqdn(strings.Join(labels[len(labels)-n:], "."))
+}
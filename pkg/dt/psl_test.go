@@ -0,0 +1,44 @@
+package dt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOrgDomain(t *testing.T) {
+	rules := parsePSL(strings.NewReader(`// comment
+co.uk
+*.ck
+!www.ck
+
+Github.IO appspot.com
+`))
+	for _, rule := range []string{"co.uk", "*.ck", "!www.ck", "github.io", "appspot.com"} {
+		if !rules[rule] {
+			t.Errorf("rule %s not parsed", rule)
+		}
+	}
+	if rules["// comment"] || rules["//"] || len(rules) != 5 {
+		t.Errorf("parsed %v rules: %v", len(rules), rules)
+	}
+	s := &session{psl: rules}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"example.com", "example.com."},
+		{"mail.example.com.", "example.com."},
+		{"Mail.Example.CO.UK", "example.co.uk."},
+		{"co.uk", "co.uk."},
+		{"a.b.example.ck", "b.example.ck."},
+		{"www.ck", "www.ck."},
+		{"a.www.ck", "www.ck."},
+		{"user.github.io", "user.github.io."},
+		{"com", "com."},
+	}
+	for _, tt := range tests {
+		if got := s.orgDomain(tt.name); got != tt.want {
+			t.Errorf("orgDomain(%s) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package dt
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// parseType parses a record type by name (AAAA), in the generic TYPE123
+// notation of RFC 3597 or as a number.
+func parseType(s string) (uint16, error) {
+	s = strings.ToUpper(s)
+	if t, ok := dns.StringToType[s]; ok {
+		return t, nil
+	}
+	t, err := strconv.ParseUint(strings.TrimPrefix(s, "TYPE"), 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unknown type %s", s)
+	}
+	return uint16(t), nil
+}
+
+// Query asks the resolver, or the nameservers in opts.NS (host[:port]), for
+// the records of name and prints the answer. Types dt doesn't know are shown
+// in the generic notation of RFC 3597 (\# length hex-rdata).
+func Query(name, qtype string, opts Options) error {
+	t, err := parseType(qtype)
+	if err != nil {
+		return err
+	}
+	s, err := newSession(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	targets := []string{s.resolver}
+	if len(opts.NS) > 0 {
+		nsdatas, err := s.overrideNS(opts.NS)
+		if err != nil {
+			return err
+		}
+		targets = nil
+		for _, ns := range nsdatas {
+			for _, ip := range ns.IP {
+				targets = append(targets, ns.addr(ip))
+			}
+		}
+	}
+	for _, server := range targets {
+		res, err := s.query(name, t, server, false)
+		if res.Msg == nil {
+			fmt.Printf("ERR : %s: %s\n\n", server, err)
+			continue
+		}
+		fmt.Printf(";; %s %s from %s in %v: %s\n", dns.Fqdn(name), dns.Type(t), server, res.Rtt, dns.RcodeToString[res.Msg.Rcode])
+		for _, rr := range append(res.Msg.Answer, res.Msg.Ns...) {
+			fmt.Println(rr)
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package dt
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// RawMessage is a response received during a check, kept with -include-raw
+// so a failed scan can be analyzed without running it again.
+type RawMessage struct {
+	Server   string
+	Net      string
+	Question string
+	Rtt      time.Duration `json:",omitempty"`
+	// Error is set when no response was received.
+	Error      string   `json:",omitempty"`
+	Rcode      string   `json:",omitempty"`
+	Flags      []string `json:",omitempty"`
+	Answer     []string `json:",omitempty"`
+	Authority  []string `json:",omitempty"`
+	Additional []string `json:",omitempty"`
+	// EDNS is the OPT record: version, flags, UDP size and options.
+	EDNS []string `json:",omitempty"`
+}
+
+// rawBuffer holds the messages recorded during a scan.
+type rawBuffer struct {
+	sync.Mutex
+	msgs []RawMessage
+}
+
+// recordRaw keeps the response in to the query m sent to server when
+// -include-raw is set.
+func (s *session) recordRaw(m, in *dns.Msg, server, proto string, rtt time.Duration, err error) {
+	if !s.includeRaw {
+		return
+	}
+	raw := RawMessage{Server: server, Net: proto, Rtt: rtt}
+	if len(m.Question) > 0 {
+		q := m.Question[0]
+		raw.Question = fmt.Sprintf("%s %s %s", q.Name, dns.ClassToString[q.Qclass], dns.TypeToString[q.Qtype])
+	}
+	if err != nil {
+		raw.Error = err.Error()
+	}
+	if in != nil {
+		raw.Rcode = dns.RcodeToString[ednsRcode(in)]
+		// in header order, like dig
+		for _, f := range []struct {
+			Name string
+			Set  bool
+		}{{"qr", in.Response}, {"aa", in.Authoritative}, {"tc", in.Truncated}, {"rd", in.RecursionDesired},
+			{"ra", in.RecursionAvailable}, {"ad", in.AuthenticatedData}, {"cd", in.CheckingDisabled}} {
+			if f.Set {
+				raw.Flags = append(raw.Flags, f.Name)
+			}
+		}
+		for _, rr := range in.Answer {
+			raw.Answer = append(raw.Answer, rr.String())
+		}
+		for _, rr := range in.Ns {
+			raw.Authority = append(raw.Authority, rr.String())
+		}
+		for _, rr := range in.Extra {
+			if opt, ok := rr.(*dns.OPT); ok {
+				for _, line := range strings.Split(strings.TrimSpace(opt.String()), "\n")[1:] {
+					raw.EDNS = append(raw.EDNS, strings.TrimPrefix(line, "; "))
+				}
+				continue
+			}
+			raw.Additional = append(raw.Additional, rr.String())
+		}
+	}
+	s.raw.Lock()
+	s.raw.msgs = append(s.raw.msgs, raw)
+	s.raw.Unlock()
+}
+
+// rawMark returns the position of the next message recorded, for rawSince.
+func (s *session) rawMark() int {
+	s.raw.Lock()
+	defer s.raw.Unlock()
+	return len(s.raw.msgs)
+}
+
+// rawSince returns the messages recorded since mark. Like the query counts
+// of the timings, messages of checks running at the same time mix.
+func (s *session) rawSince(mark int) []RawMessage {
+	s.raw.Lock()
+	defer s.raw.Unlock()
+	if mark >= len(s.raw.msgs) {
+		return nil
+	}
+	return append([]RawMessage(nil), s.raw.msgs[mark:]...)
+}
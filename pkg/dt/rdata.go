@@ -0,0 +1,87 @@
+package dt
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// rdataTypes are the less common types checked by rdataProblem, queried at
+// the apex when there is no zone data.
+var rdataTypes = []uint16{dns.TypeLOC, dns.TypeDNAME, dns.TypeRP, dns.TypeHINFO, dns.TypeURI}
+
+const (
+	// locEquator is the LOC latitude and longitude of 0 degrees, both are in
+	// thousandths of an arc second (RFC 1876).
+	locEquator = 1 << 31
+	locDegree  = 3600 * 1000
+)
+
+// rdataProblem returns what is bogus about the rdata of rr, or "" when it
+// looks sane or its type isn't checked.
+func rdataProblem(rr dns.RR) string {
+	owner := strings.ToLower(rr.Header().Name)
+	switch rr := rr.(type) {
+	case *dns.LOC:
+		lat, lon := int64(rr.Latitude)-locEquator, int64(rr.Longitude)-locEquator
+		switch {
+		case rr.Version != 0:
+			return fmt.Sprintf("LOC version %v, only 0 is defined", rr.Version)
+		case lat > 90*locDegree || lat < -90*locDegree:
+			return "latitude beyond the poles"
+		case lon > 180*locDegree || lon < -180*locDegree:
+			return "longitude beyond 180 degrees"
+		case lat == 0 && lon == 0:
+			return "coordinates 0 0, a placeholder rather than a location"
+		}
+	case *dns.DNAME:
+		target := strings.ToLower(rr.Target)
+		switch {
+		case target == owner:
+			return "DNAME pointing at itself"
+		case dns.IsSubDomain(owner, target):
+			return "DNAME pointing below itself, every lookup loops"
+		}
+	case *dns.RP:
+		switch {
+		case rr.Mbox == "." && rr.Txt == ".":
+			return "RP without a mailbox and without a TXT name"
+		case strings.Contains(rr.Mbox, "@"):
+			return "RP mailbox with an @, it is written as a name (hostmaster.example.com.)"
+		}
+	case *dns.HINFO:
+		if strings.TrimSpace(rr.Cpu) == "" && strings.TrimSpace(rr.Os) == "" {
+			return "HINFO with empty CPU and OS"
+		}
+	case *dns.URI:
+		u, err := url.Parse(rr.Target)
+		switch {
+		case rr.Target == "":
+			return "URI without a target"
+		case err != nil:
+			return fmt.Sprintf("URI target is not a URI: %s", err)
+		case u.Scheme == "":
+			return "URI target without a scheme, it must be absolute"
+		}
+	}
+	return ""
+}
+
+// apexRdata checks the rdataTypes records at the apex on server.
+func (s *session) apexRdata(apex, server string) []string {
+	var problems []string
+	for _, qtype := range rdataTypes {
+		rrset, _, err := s.queryRRset(apex, qtype, server, false)
+		if err != nil {
+			continue
+		}
+		for _, rr := range rrset {
+			if problem := rdataProblem(rr); problem != "" {
+				problems = append(problems, fmt.Sprintf("%s: %s", rr, problem))
+			}
+		}
+	}
+	return problems
+}
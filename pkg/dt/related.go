@@ -0,0 +1,165 @@
+package dt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// verificationPrefixes are TXT prefixes of site verification tokens, which
+// are usually unique per organization.
+var verificationPrefixes = []string{"google-site-verification=", "MS=", "facebook-domain-verification=",
+	"apple-domain-verification=", "atlassian-domain-verification=", "docusign=", "adobe-idp-site-verification="}
+
+// ctSiblings returns the registrable domains other than domain found in the
+// certificates issued for domain.
+func (s *session) ctSiblings(domain string) ([]string, error) {
+	if s.intranet {
+		return nil, errIntranet
+	}
+	domain = strings.ToLower(strings.TrimSuffix(dns.Fqdn(domain), "."))
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(ctURL + url.QueryEscape(domain))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CT lookup failed: %s", resp.Status)
+	}
+	var entries []ctEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+	own := s.orgDomain(domain)
+	m := make(map[string]bool)
+	for _, entry := range entries {
+		for _, name := range strings.Split(entry.NameValue, "\n") {
+			name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "*."))
+			if name == "" {
+				continue
+			}
+			if org := s.orgDomain(name); org != own {
+				m[org] = true
+			}
+		}
+	}
+	var siblings []string
+	for name := range m {
+		siblings = append(siblings, name)
+	}
+	return siblings, nil
+}
+
+// pdnsSiblings returns the domains passive DNS has seen with rdata value for
+// the given record type.
+func (s *session) pdnsSiblings(provider PassiveDNSProvider, value, rrtype string) ([]string, error) {
+	records, err := provider.Lookup(value)
+	if err != nil {
+		return nil, err
+	}
+	var siblings []string
+	for _, rec := range records {
+		if rec.Type == rrtype && strings.Contains(strings.ToLower(rec.Value), strings.ToLower(strings.TrimSuffix(value, "."))) {
+			siblings = append(siblings, s.orgDomain(rec.Name))
+		}
+	}
+	return siblings, nil
+}
+
+// Related lists the domains sharing certificates, nameservers, SOA RNAME or
+// verification tokens with domain.
+func Related(domain string, provider PassiveDNSProvider, opts Options) error {
+	s, err := newSession(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	domain = strings.ToLower(dns.Fqdn(domain))
+	own := s.orgDomain(domain)
+	shared := make(map[string][]string)
+	add := func(siblings []string, reason string) {
+		seen := make(map[string]bool)
+		for _, sibling := range siblings {
+			sibling = dns.Fqdn(sibling)
+			if sibling == own || seen[sibling] {
+				continue
+			}
+			seen[sibling] = true
+			shared[sibling] = append(shared[sibling], reason)
+		}
+	}
+
+	if siblings, err := s.ctSiblings(domain); err != nil {
+		fmt.Println("CT lookup failed:", err)
+	} else {
+		add(siblings, "certificate")
+	}
+
+	if provider != nil {
+		ns, _, _ := s.queryRRset(domain, dns.TypeNS, s.resolver, false)
+		counts := make(map[string]int)
+		for _, rr := range ns {
+			siblings, err := s.pdnsSiblings(provider, rr.(*dns.NS).Ns, "NS")
+			if err != nil {
+				fmt.Println("Passive DNS lookup failed:", err)
+				break
+			}
+			seen := make(map[string]bool)
+			for _, sibling := range siblings {
+				if !seen[sibling] {
+					seen[sibling] = true
+					counts[sibling]++
+				}
+			}
+		}
+		var sameNS []string
+		for sibling, n := range counts {
+			if n == len(ns) {
+				sameNS = append(sameNS, sibling)
+			}
+		}
+		add(sameNS, "NS set")
+
+		if soa, _, err := s.queryRRset(domain, dns.TypeSOA, s.resolver, false); err == nil {
+			rname := soa[0].(*dns.SOA).Mbox
+			if siblings, err := s.pdnsSiblings(provider, rname, "SOA"); err == nil {
+				add(siblings, "SOA RNAME "+rname)
+			}
+		}
+
+		txt, _, _ := s.queryRRset(domain, dns.TypeTXT, s.resolver, false)
+		for _, rr := range txt {
+			value := txtString(rr.(*dns.TXT))
+			for _, prefix := range verificationPrefixes {
+				if strings.HasPrefix(value, prefix) {
+					if siblings, err := s.pdnsSiblings(provider, value, "TXT"); err == nil {
+						add(siblings, "verification token")
+					}
+				}
+			}
+		}
+	}
+
+	var names []string
+	for name := range shared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+	fmt.Fprintf(w, "Domain\tShares\n")
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%s\n", name, strings.Join(shared[name], ", "))
+	}
+	w.Flush()
+	fmt.Printf("\n%v related domains found for %s\n", len(names), own)
+	return nil
+}
@@ -0,0 +1,86 @@
+package dt
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+type ResponseCheck struct {
+	NS       []NSData
+	Response []ResponseData
+	Referral int
+	Report
+}
+
+type ResponseData struct {
+	Name       string
+	IP         string
+	Size       int
+	Authority  int
+	Additional int
+}
+
+// extraRR returns the additional records of msg, not counting the OPT record.
+func extraRR(msg *dns.Msg) []dns.RR {
+	var rrs []dns.RR
+	for _, rr := range msg.Extra {
+		if rr.Header().Rrtype != dns.TypeOPT {
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs
+}
+
+func (c *ResponseCheck) Scan(domain string) {
+	datas := make([]*ResponseData, serverCount(c.NS))
+	c.s.eachServer(c.NS, &c.Report, func(i int, ns NSData, nsip net.IP, r *Report) {
+		res, err := c.s.query(domain, dns.TypeSOA, ns.addr(nsip), true)
+		if err != nil || len(res.Msg.Answer) == 0 {
+			return
+		}
+		datas[i] = &ResponseData{Name: ns.Name, IP: ns.addr(nsip), Size: res.Msg.Len(),
+			Authority: len(res.Msg.Ns), Additional: len(extraRR(res.Msg))}
+	})
+	for _, data := range datas {
+		if data != nil {
+			c.Response = append(c.Response, *data)
+		}
+	}
+	if referral, err := c.s.parentReferral(domain); err == nil {
+		c.Referral = referral.Len()
+	}
+}
+
+func (c *ResponseCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	if len(c.Response) == 0 {
+		return results
+	}
+	total := 0
+	for _, data := range c.Response {
+		total += data.Size
+		if data.Authority > 0 || data.Additional > 0 {
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: %s (%s) adds %v authority and %v additional records to a %v byte answer. Consider enabling minimal-responses.", data.Name, data.IP, data.Authority, data.Additional, data.Size),
+				Status: false, Name: "Minimal", Remediation: "Enable minimal-responses on the nameserver to keep answers small."})
+		}
+	}
+	if len(results) == 0 {
+		results = append(results, ReportResult{Result: "OK  : All nameservers send minimal responses",
+			Status: true, Name: "Minimal"})
+	}
+	info := fmt.Sprintf("OK  : Average SOA response size is %v bytes", total/len(c.Response))
+	if c.Referral > 0 {
+		info += fmt.Sprintf(", referral from the parent is %v bytes", c.Referral)
+	}
+	results = append(results, ReportResult{Result: info, Status: true, Name: "Size"})
+	return results
+}
+
+func (c *ResponseCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "Responses"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
@@ -0,0 +1,155 @@
+package dt
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/miekg/dns"
+)
+
+// maxReverseZones is the maximum number of reverse zones generated for a prefix.
+const maxReverseZones = 256
+
+// reverseZones returns the in-addr.arpa/ip6.arpa zone cuts covering cidr.
+// Prefixes longer than /24 (IPv4) are delegated classless (RFC 2317) from
+// the /24, which is returned with classless set.
+func reverseZones(cidr string) (zones []string, classless bool, err error) {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, false, err
+	}
+	ones, bits := ipnet.Mask.Size()
+	step, suffix := 8, "in-addr.arpa."
+	if bits == 128 {
+		step, suffix = 4, "ip6.arpa."
+	}
+	if bits == 32 && ones > 24 {
+		ones, classless = 24, true
+	}
+	cut := (ones + step - 1) / step * step
+	if cut == 0 {
+		return nil, false, fmt.Errorf("prefix %s is too short", cidr)
+	}
+	count := 1 << uint(cut-ones)
+	if count > maxReverseZones {
+		return nil, false, fmt.Errorf("prefix %s splits into %v reverse zones, more than %v", cidr, count, maxReverseZones)
+	}
+	ip := ipnet.IP.To16()
+	if bits == 32 {
+		ip = ipnet.IP.To4()
+	}
+	base := new(big.Int).SetBytes(ip)
+	for i := 0; i < count; i++ {
+		n := new(big.Int).Add(base, new(big.Int).Lsh(big.NewInt(int64(i)), uint(bits-cut)))
+		b := n.FillBytes(make([]byte, len(ip)))
+		var labels []string
+		if bits == 32 {
+			for _, octet := range b[:cut/8] {
+				labels = append([]string{fmt.Sprintf("%d", octet)}, labels...)
+			}
+		} else {
+			for j := 0; j < cut/4; j++ {
+				nibble := b[j/2] >> 4
+				if j%2 == 1 {
+					nibble = b[j/2] & 0xf
+				}
+				labels = append([]string{fmt.Sprintf("%x", nibble)}, labels...)
+			}
+		}
+		zones = append(zones, strings.Join(labels, ".")+"."+suffix)
+	}
+	return zones, classless, nil
+}
+
+// ReverseCheck verifies the reverse delegations of cidr and reports the
+// nameservers they are delegated to, compared with the ones in opts.NS.
+func ReverseCheck(cidr string, opts Options) error {
+	s, err := newSession(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	zones, classless, err := reverseZones(cidr)
+	if err != nil {
+		return err
+	}
+	want := make(map[string]bool)
+	for _, ns := range opts.NS {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			want[strings.ToLower(dns.Fqdn(ns))] = true
+		}
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+	fmt.Fprintf(w, "Zone\tDelegated at\tNS\tStatus\n")
+	var remediations []string
+	remediate := func(r string) {
+		for _, seen := range remediations {
+			if seen == r {
+				return
+			}
+		}
+		remediations = append(remediations, r)
+	}
+	for _, zone := range zones {
+		at := s.findZone(zone)
+		var names []string
+		status := "OK"
+		rrset, _, _ := s.queryRRset(at, dns.TypeNS, s.resolver, false)
+		got := make(map[string]bool)
+		for _, rr := range rrset {
+			ns := strings.ToLower(rr.(*dns.NS).Ns)
+			names = append(names, ns)
+			got[ns] = true
+		}
+		switch {
+		case at != zone:
+			status = "FAIL: no zone cut here, served by the parent zone"
+			remediate("Ask the holder of the parent reverse zone (your RIR or upstream provider) to delegate the zone to your nameservers.")
+		case len(want) > 0 && !sameSet(want, got):
+			status = "FAIL: unexpected nameservers"
+			remediate("Update the reverse delegation at your RIR or upstream provider to the expected nameservers.")
+		default:
+			for _, ns := range names {
+				for _, ip := range s.resolveHost(ns) {
+					if res, err := s.query(zone, dns.TypeSOA, ip.String(), false); err != nil || !res.Msg.Authoritative {
+						status = fmt.Sprintf("FAIL: %s (%s) is lame", ns, ip)
+						remediate("Load the reverse zone on the lame nameservers or remove them from the delegation.")
+					}
+				}
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", zone, at, strings.Join(names, " "), status)
+	}
+	w.Flush()
+	for _, r := range remediations {
+		fmt.Println("\t   Fix:", r)
+	}
+	if classless {
+		_, ipnet, _ := net.ParseCIDR(cidr)
+		first, _ := dns.ReverseAddr(ipnet.IP.String())
+		res, err := s.query(first, dns.TypePTR, s.resolver, false)
+		if cname := extractRR(res.Msg.Answer, dns.TypeCNAME); err == nil && len(cname) > 0 {
+			target := cname[0].(*dns.CNAME).Target
+			fmt.Printf("\nOK  : %s is delegated classless (RFC 2317) via CNAME to %s\n", cidr, target)
+		} else {
+			fmt.Printf("\nWARN: %s is longer than /24 but %s has no RFC 2317 CNAME\n", cidr, first)
+		}
+	}
+	return nil
+}
+
+func sameSet(a, b map[string]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if !b[k] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,207 @@
+package dt
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// bundledRootHints is the IANA named.root file shipped with dt.
+const bundledRootHints = `.                        3600000      NS    A.ROOT-SERVERS.NET.
+A.ROOT-SERVERS.NET.      3600000      A     198.41.0.4
+A.ROOT-SERVERS.NET.      3600000      AAAA  2001:503:ba3e::2:30
+.                        3600000      NS    B.ROOT-SERVERS.NET.
+B.ROOT-SERVERS.NET.      3600000      A     170.247.170.2
+B.ROOT-SERVERS.NET.      3600000      AAAA  2801:1b8:10::b
+.                        3600000      NS    C.ROOT-SERVERS.NET.
+C.ROOT-SERVERS.NET.      3600000      A     192.33.4.12
+C.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:2::c
+.                        3600000      NS    D.ROOT-SERVERS.NET.
+D.ROOT-SERVERS.NET.      3600000      A     199.7.91.13
+D.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:2d::d
+.                        3600000      NS    E.ROOT-SERVERS.NET.
+E.ROOT-SERVERS.NET.      3600000      A     192.203.230.10
+E.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:a8::e
+.                        3600000      NS    F.ROOT-SERVERS.NET.
+F.ROOT-SERVERS.NET.      3600000      A     192.5.5.241
+F.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:2f::f
+.                        3600000      NS    G.ROOT-SERVERS.NET.
+G.ROOT-SERVERS.NET.      3600000      A     192.112.36.4
+G.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:12::d0d
+.                        3600000      NS    H.ROOT-SERVERS.NET.
+H.ROOT-SERVERS.NET.      3600000      A     198.97.190.53
+H.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:1::53
+.                        3600000      NS    I.ROOT-SERVERS.NET.
+I.ROOT-SERVERS.NET.      3600000      A     192.36.148.17
+I.ROOT-SERVERS.NET.      3600000      AAAA  2001:7fe::53
+.                        3600000      NS    J.ROOT-SERVERS.NET.
+J.ROOT-SERVERS.NET.      3600000      A     192.58.128.30
+J.ROOT-SERVERS.NET.      3600000      AAAA  2001:503:c27::2:30
+.                        3600000      NS    K.ROOT-SERVERS.NET.
+K.ROOT-SERVERS.NET.      3600000      A     193.0.14.129
+K.ROOT-SERVERS.NET.      3600000      AAAA  2001:7fd::1
+.                        3600000      NS    L.ROOT-SERVERS.NET.
+L.ROOT-SERVERS.NET.      3600000      A     199.7.83.42
+L.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:9f::42
+.                        3600000      NS    M.ROOT-SERVERS.NET.
+M.ROOT-SERVERS.NET.      3600000      A     202.12.27.33
+M.ROOT-SERVERS.NET.      3600000      AAAA  2001:dc3::35
+`
+
+// rootNSData turns NS records and their address records into NSData.
+func (s *session) rootNSData(ns []dns.RR, glue []dns.RR) []NSData {
+	var nsdatas []NSData
+	for _, rr := range ns {
+		name := strings.ToLower(rr.(*dns.NS).Ns)
+		nsdata := NSData{Name: name}
+		for _, g := range glue {
+			if strings.ToLower(g.Header().Name) == name {
+				nsdata.IP = append(nsdata.IP, s.inFamily(extractIP([]dns.RR{g}))...)
+			}
+		}
+		for _, ip := range nsdata.IP {
+			nsdata.Info = append(nsdata.Info, NSInfo{IPInfo: IPInfo{IP: ip}, Name: name})
+		}
+		nsdatas = append(nsdatas, nsdata)
+	}
+	return nsdatas
+}
+
+// loadRootHints parses the root hints in named.root format from file, or the
+// bundled hints when file is empty.
+func (s *session) loadRootHints(file string) ([]NSData, error) {
+	var r io.Reader = strings.NewReader(bundledRootHints)
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var rrs []dns.RR
+	for t := range dns.ParseZone(r, ".", file) {
+		if t.Error != nil {
+			return nil, t.Error
+		}
+		rrs = append(rrs, t.RR)
+	}
+	hints := s.rootNSData(extractRR(rrs, dns.TypeNS), extractRR(rrs, dns.TypeA, dns.TypeAAAA))
+	if len(hints) == 0 {
+		return nil, fmt.Errorf("no root servers found in hints")
+	}
+	return hints, nil
+}
+
+// primeRoot sends a priming query (RFC 8109) to the hints until one answers.
+func (s *session) primeRoot(hints []NSData) (*dns.Msg, string, error) {
+	for _, hint := range hints {
+		for _, ip := range hint.IP {
+			res, err := s.query(".", dns.TypeNS, ip.String(), true)
+			if err != nil {
+				s.log.Debugf("Priming query to %s (%s) failed: %s", hint.Name, ip, err)
+				continue
+			}
+			if len(extractRR(res.Msg.Answer, dns.TypeNS)) > 0 {
+				return res.Msg, ip.String(), nil
+			}
+		}
+	}
+	return nil, "", fmt.Errorf("no root server answered the priming query")
+}
+
+type RootCheck struct {
+	NS     []NSData
+	Hints  []NSData
+	Roots  []NSData
+	Server string
+	File   string
+	Report
+}
+
+func (c *RootCheck) Scan(domain string) {
+	hints, err := c.s.loadRootHints(c.File)
+	if err != nil {
+		c.Report.Result = append(c.Report.Result, ReportResult{Result: fmt.Sprintf("ERR : Loading root hints failed: %s", err)})
+		return
+	}
+	c.Hints = hints
+	msg, server, err := c.s.primeRoot(hints)
+	if err != nil {
+		c.Report.Result = append(c.Report.Result, ReportResult{Result: fmt.Sprintf("FAIL: Root priming failed: %s", err),
+			Status: false, Name: "Priming", Remediation: "Check that the resolver can reach the root servers over UDP and TCP port 53."})
+		return
+	}
+	c.Server = server
+	c.Roots = c.s.rootNSData(extractRR(msg.Answer, dns.TypeNS), extractRR(msg.Extra, dns.TypeA, dns.TypeAAAA))
+}
+
+func ipStrings(ips []net.IP) []string {
+	var s []string
+	for _, ip := range ips {
+		s = append(s, ip.String())
+	}
+	sort.Strings(s)
+	return s
+}
+
+func (c *RootCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	if len(c.Roots) == 0 {
+		return results
+	}
+	results = append(results, ReportResult{Result: fmt.Sprintf("OK  : Root priming via %s returned %v root servers", c.Server, len(c.Roots)),
+		Status: true, Name: "Priming"})
+
+	var noglue []string
+	for _, root := range c.Roots {
+		if len(root.IP) == 0 {
+			noglue = append(noglue, root.Name)
+		}
+	}
+	if len(noglue) > 0 {
+		results = append(results, ReportResult{Result: fmt.Sprintf("WARN: Priming response has no addresses for %v", noglue),
+			Status: false, Name: "Glue", Remediation: "Check for a middlebox stripping the additional section of priming responses."})
+	}
+
+	hints := make(map[string]string)
+	for _, hint := range c.Hints {
+		hints[hint.Name] = strings.Join(ipStrings(hint.IP), " ")
+	}
+	var stale []string
+	for _, root := range c.Roots {
+		ips := strings.Join(ipStrings(root.IP), " ")
+		hint, ok := hints[root.Name]
+		switch {
+		case !ok:
+			stale = append(stale, fmt.Sprintf("%s is missing", root.Name))
+		case len(root.IP) > 0 && hint != ips:
+			stale = append(stale, fmt.Sprintf("%s is %s instead of %s", root.Name, ips, hint))
+		}
+		delete(hints, root.Name)
+	}
+	for name := range hints {
+		stale = append(stale, fmt.Sprintf("%s is no longer a root server", name))
+	}
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		results = append(results, ReportResult{Result: fmt.Sprintf("WARN: Root hints are stale: %s", strings.Join(stale, ", ")),
+			Status: false, Name: "Hints", Remediation: "Update the root hints file from https://www.internic.net/domain/named.root."})
+	} else {
+		results = append(results, ReportResult{Result: "OK  : Root hints match the priming response",
+			Status: true, Name: "Hints"})
+	}
+	return results
+}
+
+func (c *RootCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "ROOT"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
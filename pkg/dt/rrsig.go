@@ -0,0 +1,171 @@
+package dt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// rrsigQtypes are the apex RRsets whose signatures are checked for expiry,
+// a zone stops resolving once one of them has no valid signature.
+var rrsigQtypes = []uint16{dns.TypeSOA, dns.TypeDNSKEY, dns.TypeNS}
+
+// rrsigWarnDefault is how long before expiry a signature is reported when no
+// window is given.
+const rrsigWarnDefault = 7 * 24 * time.Hour
+
+// parseWindow parses a duration, also accepted in days (7d).
+func parseWindow(s string) (time.Duration, error) {
+	if days, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil && strings.HasSuffix(s, "d") && days >= 0 {
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid window %q: use days (7d) or a duration (36h)", s)
+	}
+	return d, nil
+}
+
+// windowString returns d in days when it is a whole number of them.
+func windowString(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%vd", int64(d/(24*time.Hour)))
+	}
+	return d.String()
+}
+
+// RRSIGCheck reports signatures over the SOA, DNSKEY and NS RRsets of every
+// nameserver address that expire within Warn, before the zone goes bogus.
+type RRSIGCheck struct {
+	NS   []NSData
+	Warn time.Duration
+	Sigs []RRSIGData
+	Report
+}
+
+type RRSIGData struct {
+	Name string
+	IP   string
+	// Sigs are the signatures that expire first per RRset.
+	Sigs  []RRSIGTimes
+	Error string
+}
+
+type RRSIGTimes struct {
+	Qtype      string
+	KeyTag     uint16
+	Inception  time.Time
+	Expiration time.Time
+}
+
+// rrsigTimes returns the signature expiring first over every qtype, from
+// server.
+func (s *session) rrsigTimes(zone string, server string) ([]RRSIGTimes, error) {
+	var times []RRSIGTimes
+	for _, qtype := range rrsigQtypes {
+		res, err := s.query(zone, qtype, server, true)
+		if err == dns.ErrTruncated || err == nil && res.Msg.Truncated {
+			res, err = s.queryNet(zone, qtype, server, true, "tcp")
+		}
+		if err != nil {
+			return nil, err
+		}
+		var first *RRSIGTimes
+		for _, rr := range extractRR(res.Msg.Answer, dns.TypeRRSIG) {
+			sig := rr.(*dns.RRSIG)
+			if sig.TypeCovered != qtype {
+				continue
+			}
+			start, end := explicitValid(sig)
+			t := RRSIGTimes{Qtype: dns.TypeToString[qtype], KeyTag: sig.KeyTag, Inception: time.Unix(start, 0), Expiration: time.Unix(end, 0)}
+			if first == nil || t.Expiration.Before(first.Expiration) {
+				first = &t
+			}
+		}
+		if first != nil {
+			times = append(times, *first)
+		}
+	}
+	return times, nil
+}
+
+func (c *RRSIGCheck) Scan(domain string) {
+	if c.Warn == 0 {
+		c.Warn = rrsigWarnDefault
+	}
+	zone := dns.Fqdn(domain)
+	for _, ns := range c.NS {
+		for _, ip := range ns.IP {
+			c.Sigs = append(c.Sigs, RRSIGData{Name: ns.Name, IP: ns.addr(ip)})
+		}
+	}
+	sem := make(chan struct{}, c.s.concurrency)
+	var wg sync.WaitGroup
+	for i := range c.Sigs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(d *RRSIGData) {
+			defer func() { <-sem; wg.Done() }()
+			var err error
+			if d.Sigs, err = c.s.rrsigTimes(zone, d.IP); err != nil {
+				d.Error = err.Error()
+			}
+		}(&c.Sigs[i])
+	}
+	wg.Wait()
+}
+
+func (c *RRSIGCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	now := time.Now()
+	signed := 0
+	var first *RRSIGTimes
+	for _, d := range c.Sigs {
+		server := fmt.Sprintf("%s (%s)", d.Name, d.IP)
+		if d.Error != "" {
+			results = append(results, ReportResult{Result: fmt.Sprintf("ERR : %s doesn't answer, signatures not checked: %s", server, d.Error),
+				Status: false, Name: "RRSIGExpiry", Server: d.IP, Error: d.Error, Unreachable: true})
+			continue
+		}
+		if len(d.Sigs) > 0 {
+			signed++
+		}
+		for i, sig := range d.Sigs {
+			switch {
+			case now.After(sig.Expiration):
+				results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: RRSIG over %s (key %v) from %s expired %s, validating resolvers fail the zone", sig.Qtype, sig.KeyTag, server, FormatWhen(sig.Expiration, c.s.useUTC)),
+					Status: false, Name: "RRSIGExpiry", Remediation: "Re-sign the zone now and check why the signer stopped refreshing signatures."})
+			case now.Before(sig.Inception):
+				results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: RRSIG over %s (key %v) from %s isn't valid yet, its inception is %s, check the clock of the signer", sig.Qtype, sig.KeyTag, server, FormatWhen(sig.Inception, c.s.useUTC)),
+					Status: false, Name: "RRSIGExpiry", Remediation: "Sync the clock of the signer and re-sign the zone."})
+			case now.Add(c.Warn).After(sig.Expiration):
+				results = append(results, ReportResult{Result: fmt.Sprintf("WARN: RRSIG over %s (key %v) from %s expires %s", sig.Qtype, sig.KeyTag, server, FormatWhen(sig.Expiration, c.s.useUTC)),
+					Status: false, Name: "RRSIGExpiry", Remediation: "Check that the signer is running and refreshes signatures before they expire."})
+			}
+			if first == nil || sig.Expiration.Before(first.Expiration) {
+				first = &d.Sigs[i]
+			}
+		}
+	}
+	if len(results) > 0 || len(c.Sigs) == 0 {
+		return results
+	}
+	if signed == 0 {
+		return append(results, ReportResult{Result: "SKIP: The zone is not signed, no signatures to expire",
+			Status: true, Name: "RRSIGExpiry"})
+	}
+	return append(results, ReportResult{Result: fmt.Sprintf("OK  : No RRSIG over SOA, DNSKEY or NS on %v nameserver addresses expires within %s, the first (over %s) expires %s",
+		signed, windowString(c.Warn), first.Qtype, FormatWhen(first.Expiration, c.s.useUTC)),
+		Status: true, Name: "RRSIGExpiry"})
+}
+
+func (c *RRSIGCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "RRSIG expiry"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
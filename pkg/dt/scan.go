@@ -1,21 +1,25 @@
-package main
+package dt
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"fmt"
 	"net"
 	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/briandowns/spinner"
 	"github.com/miekg/dns"
 )
 
-var DSP = []struct {
+type ScanEntry struct {
 	Qtype   uint16
 	Entries []string
-}{
+}
+
+var DSP = []ScanEntry{
 	{dns.TypeSOA, []string{""}},
 	{dns.TypeNS, []string{""}},
 	{dns.TypeDS, []string{""}},
@@ -39,49 +43,87 @@ type ScanRequest struct {
 	Domain string
 }
 
-func zoneTransfer(domain, server string) []string {
+func (s *session) zoneTransfer(domain, server string) []string {
 	var records []string
+	for _, rr := range s.zoneTransferRR(domain, server) {
+		records = append(records, rr.String())
+	}
+	sort.Strings(records)
+	return records
+}
+
+func (s *session) zoneTransferRR(domain, server string) []dns.RR {
+	var rrs []dns.RR
+	s.streamTransfer(domain, server, func(envelope []dns.RR) {
+		rrs = append(rrs, envelope...)
+	})
+	return rrs
+}
+
+// streamTransfer passes the zone transfer of domain from server to fn one
+// envelope at a time, so the zone is never held in memory as a whole. The
+// transfer is aborted when the context of s is done.
+func (s *session) streamTransfer(domain, server string, fn func([]dns.RR)) error {
+	if s.offline {
+		return errOffline
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
 	t := new(dns.Transfer)
-	req := prepMsg()
-	req.Question[0] = dns.Question{dns.Fqdn(domain), dns.TypeAXFR, dns.ClassINET}
-	q, err := t.In(req, net.JoinHostPort(server, "53"))
+	req := prepMsg(domain, dns.TypeAXFR, s.qclass)
+	atomic.AddInt64(&s.queryCount, 1)
+	q, err := t.In(req, serverAddr(server))
 	if err != nil {
-		return records
-		//	fmt.Println("error", err)
+		return err
 	}
 	for res := range q {
-		if res.Error != nil {
-			break
+		if err := s.ctx.Err(); err != nil {
+			// the reader fails on the closed connection and ends the stream
+			t.Close()
+			for range q {
+			}
+			return err
 		}
-		for _, rr := range res.RR {
-			records = append(records, rr.String())
+		if res.Error != nil {
+			return res.Error
 		}
+		fn(res.RR)
 	}
-	sort.Strings(records)
-	return records
+	return nil
 }
 
-func domainscan(domain string) {
+// DomainScan looks up common records of domain and prints the ones found.
+func DomainScan(domain string, opts Options) error {
+	s, err := newSession(context.Background(), opts)
+	if err != nil {
+		return err
+	}
 	var ips []net.IP
 	respc := make(chan ScanResponse, 100)
 
-	servers, _ := findNS(dns.Fqdn(domain))
+	servers, _ := s.findNS(dns.Fqdn(domain))
 	for _, server := range servers {
 		for _, info := range server.Info {
 			ips = append(ips, info.IP)
 		}
 	}
-	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	spin := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+
+	scanList := append([]ScanEntry{}, DSP...)
+	if s.ctScan {
+		scanList = append(scanList, ScanEntry{dns.TypeA, s.ctScanEntries(domain)})
+	}
 
 	scanEntries := 0
-	for _, src := range DSP {
+	for _, src := range scanList {
 		scanEntries = scanEntries + len(src.Entries)
 	}
 
-	*flagQPS = *flagQPS * len(servers)
+	rate := s.qps * len(servers)
 
 	for _, ip := range ips {
-		res := zoneTransfer(domain, ip.String())
+		res := s.zoneTransfer(domain, ip.String())
 		if len(res) > 0 {
 			zt := ""
 			for _, rr := range res {
@@ -89,7 +131,7 @@ func domainscan(domain string) {
 			}
 			fmt.Println(zt)
 			// only print one. Further scanning not needed
-			return
+			return nil
 			// TODO compare hashes
 			//	fmt.Printf("%x\n", Hash("key", []byte(zt)))
 		} else {
@@ -98,18 +140,18 @@ func domainscan(domain string) {
 	}
 	fmt.Println(": AXFR denied")
 
-	s.Suffix = " Scanning... will take approx " + fmt.Sprintf("%#v seconds", scanEntries/(len(servers)*(*flagQPS)))
-	s.Start()
+	spin.Suffix = " Scanning... will take approx " + fmt.Sprintf("%#v seconds", scanEntries/(len(servers)*rate))
+	spin.Start()
 
-	res, _, _ := queryRRset(dns.Fqdn("*."+domain), dns.TypeA, ips[0].String(), true)
+	res, _, _ := s.queryRRset(dns.Fqdn("*."+domain), dns.TypeA, ips[0].String(), true)
 	// TODO handle * record correctly
 	if len(res) != 0 {
-		s.Stop()
+		spin.Stop()
 		fmt.Println()
 		for _, rr := range res {
 			fmt.Println(rr.String())
 		}
-		return
+		return nil
 	}
 
 	var nsc []chan ScanRequest
@@ -118,7 +160,7 @@ func domainscan(domain string) {
 		nsc = append(nsc, c)
 		//fmt.Printf("scanning %s\n", server.IP[0])
 		go func(c chan ScanRequest, ns net.IP) {
-			limiter := time.Tick(time.Millisecond * time.Duration(1000/(*flagQPS)))
+			limiter := time.Tick(time.Millisecond * time.Duration(1000/rate))
 			for request := range c {
 				var rrs []dns.RR
 				<-limiter
@@ -126,13 +168,13 @@ func domainscan(domain string) {
 				entry := request.Query
 				domain := request.Domain
 				if qtype == dns.TypeA {
-					res, err := query(dns.Fqdn(entry+domain), dns.TypeA, ns.String(), true)
+					res, err := s.query(dns.Fqdn(entry+domain), dns.TypeA, ns.String(), true)
 					if err != nil {
 						//fmt.Println(err)
 					} else {
 						rrs = extractRR(res.Msg.Answer, dns.TypeA, dns.TypeCNAME)
 					}
-					res2, rtt, err := queryRRset(dns.Fqdn(entry+domain), dns.TypeAAAA, ns.String(), true)
+					res2, rtt, err := s.queryRRset(dns.Fqdn(entry+domain), dns.TypeAAAA, ns.String(), true)
 					if err != nil && len(res2) != 0 {
 						//fmt.Println(err)
 					}
@@ -140,7 +182,7 @@ func domainscan(domain string) {
 					respc <- ScanResponse{RR: rrs, NS: ns.String(), Rtt: rtt}
 					continue
 				}
-				res, rtt, err := queryRRset(dns.Fqdn(entry+domain), qtype, ns.String(), true)
+				res, rtt, err := s.queryRRset(dns.Fqdn(entry+domain), qtype, ns.String(), true)
 				if err != nil && len(res) != 0 {
 					//fmt.Println(err)
 				}
@@ -150,7 +192,7 @@ func domainscan(domain string) {
 	}
 
 	i := -1
-	for _, src := range DSP {
+	for _, src := range scanList {
 		for _, entry := range src.Entries {
 			i++
 			q := ScanRequest{src.Qtype, entry, domain}
@@ -159,11 +201,13 @@ func domainscan(domain string) {
 	}
 
 	var responses []string
+	names := make(map[string]bool)
 	i = 0
 	for resp := range respc {
 		if len(resp.RR) > 0 {
 			for _, rr := range resp.RR {
 				responses = append(responses, rr.String())
+				names[rr.Header().Name] = true
 			}
 		}
 		if i == scanEntries-1 {
@@ -172,12 +216,27 @@ func domainscan(domain string) {
 		i++
 	}
 
-	s.Stop()
+	spin.Stop()
 
 	sort.Strings(responses)
 	for _, response := range responses {
 		fmt.Println(response)
 	}
+
+	var warnings []string
+	for name := range names {
+		for _, problem := range confusableProblems(name) {
+			warnings = append(warnings, fmt.Sprintf("\t WARN: %s: %s", name, problem))
+		}
+	}
+	if len(warnings) > 0 {
+		sort.Strings(warnings)
+		fmt.Println("Confusables")
+		for _, warning := range warnings {
+			fmt.Println(warning)
+		}
+	}
+	return nil
 }
 
 func Hash(tag string, data []byte) []byte {
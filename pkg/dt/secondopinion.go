@@ -0,0 +1,102 @@
+package dt
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+)
+
+// resolverDependent reports whether the results of c depend on the answers
+// of the recursive resolver rather than only on the authoritative servers.
+func resolverDependent(c Checker) bool {
+	switch c.(type) {
+	case *DelegationCheck, *MXCheck, *CAACheck, *SpamCheck, *DKIMCheck, *MTASTSCheck, *DANECheck,
+		*WebCheck, *HTTPCheck, *TLSCheck, *AutodiscoverCheck, *AcmeCheck, *SSHFPCheck, *MailKeyCheck:
+		return true
+	}
+	return false
+}
+
+// secondResolver returns the resolver giving the second opinion: addr as
+// host[:port], or without it the first public resolver other than ours.
+// Intranet mode has no second opinion unless addr is set, "off" has none.
+func (s *session) secondResolver(addr string) string {
+	if addr == "off" {
+		return ""
+	}
+	if addr != "" {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return strings.Trim(addr, "[]")
+		}
+		return hostPort(host, port)
+	}
+	if s.intranet {
+		return ""
+	}
+	for _, r := range publicResolvers {
+		if r != s.resolver {
+			return r
+		}
+	}
+	return ""
+}
+
+// secondOpinion runs the resolver dependent checkers whose report has
+// failures again through second, using the unused checkers of fresh, and
+// annotates every failure with whether second agrees: a failure that
+// disappears is caused by our resolver path, not by the domain. The checkers
+// run in a copy of s querying second, s keeps its resolver.
+func (s *session) secondOpinion(domain string, checkers, fresh []Checker, reports []*Report, second string) {
+	opinion := *s
+	opinion.resolver, opinion.dotConfig, opinion.resolverFallbacks, opinion.queryCount = second, nil, nil, 0
+	defer func() { atomic.AddInt64(&s.queryCount, atomic.LoadInt64(&opinion.queryCount)) }()
+	for i, checker := range checkers {
+		report := reports[i]
+		if report == nil || !resolverDependent(checker) || !failed(*report) {
+			continue
+		}
+		again := isolate(opinion.createReport(fresh[i], domain))
+		failing := make(map[string]bool)
+		for _, res := range again.Result {
+			if problem(res) {
+				failing[checkKey(res)] = true
+			}
+		}
+		for j, res := range report.Result {
+			if !problem(res) {
+				continue
+			}
+			if failing[checkKey(res)] {
+				res.SecondOpinion = fmt.Sprintf("%s agrees, the problem is in the domain", second)
+			} else {
+				res.SecondOpinion = fmt.Sprintf("%s doesn't see this, the problem is in the path through resolver %s", second, s.resolver)
+			}
+			report.Result[j] = res
+		}
+	}
+}
+
+// checkKey identifies the check that produced res within its report. The
+// result text carries the answers, which differ between resolvers, the name,
+// the server and the remediation of a check don't.
+func checkKey(res ReportResult) string {
+	return strings.Join([]string{res.Name, res.Server, res.Remediation}, "\x00")
+}
+
+// failed reports whether report has a problem result.
+func failed(report Report) bool {
+	for _, res := range report.Result {
+		if problem(res) {
+			return true
+		}
+	}
+	return false
+}
+
+// problem reports whether res is a warning, failure or error, some checks
+// leave Status unset on their OK and SKIP results.
+func problem(res ReportResult) bool {
+	return !res.Status && !strings.HasPrefix(res.Result, "OK") && !strings.HasPrefix(res.Result, "SKIP")
+}
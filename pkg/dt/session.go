@@ -0,0 +1,121 @@
+package dt
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/miekg/dns"
+)
+
+// session holds the settings and state of one scan, or of one run of the
+// other entry points, so scans running at the same time don't change each
+// other's resolver or query settings. Checkers reach it through their
+// Report.
+type session struct {
+	// queryCount is the number of queries sent, for the timings. It is
+	// first to keep it 64-bit aligned for the atomic operations.
+	queryCount int64
+
+	ctx      context.Context
+	resolver string
+	// resolverFallbacks are the resolvers given after the first, queried in
+	// order when the resolver doesn't answer.
+	resolverFallbacks []string
+	// dotConfig is the TLS configuration used for queries to the resolver
+	// when DNS over TLS is enabled, nil otherwise.
+	dotConfig    *tls.Config
+	queryTimeout time.Duration
+	probes       int
+	qps          int
+	concurrency  int
+	qclass       uint16
+	ipFamily     int
+	shuffle      bool
+	offline      bool
+	intranet     bool
+	ctScan       bool
+	debug        bool
+	includeRaw   bool
+	// raw holds the messages recorded with -include-raw, dropped with the
+	// session when the scan is done.
+	raw *rawBuffer
+	// useUTC shows times in UTC instead of the local time zone.
+	useUTC       bool
+	trustAnchors []*dns.DS
+	// psl holds the public suffix rules, loaded by loadPSL.
+	psl  map[string]bool
+	cuts *cutCache
+	// origins caches the origin lookups of the nameserver addresses.
+	origins *originCache
+	log     *logrus.Logger
+}
+
+// cutCache holds the zone cuts found by zoneCut.
+type cutCache struct {
+	sync.Mutex
+	zones map[string]string
+}
+
+// newSession returns the session applying the query settings of opts, whose
+// queries stop when ctx is done.
+func newSession(ctx context.Context, opts Options) (*session, error) {
+	s := &session{ctx: ctx, queryTimeout: opts.Timeout, probes: opts.Probes,
+		qps: 10, concurrency: 8, qclass: dns.ClassINET, shuffle: !opts.NoShuffle, offline: opts.Offline, intranet: opts.Intranet,
+		ctScan: opts.CT, debug: opts.Debug, includeRaw: opts.IncludeRaw, useUTC: opts.UTC, psl: defaultPSL,
+		cuts: &cutCache{zones: make(map[string]string)}, raw: &rawBuffer{}, origins: newOriginCache(), log: logrus.New()}
+	if opts.Debug {
+		s.log.Level = logrus.DebugLevel
+	}
+	if opts.Fast && s.probes == 0 {
+		s.probes = 1
+	}
+	if opts.QPS > 0 {
+		s.qps = opts.QPS
+	}
+	if opts.Concurrency > 0 {
+		s.concurrency = opts.Concurrency
+	}
+	if opts.TrustAnchors != "" {
+		anchors, err := loadTrustAnchors(opts.TrustAnchors)
+		if err != nil {
+			return nil, err
+		}
+		s.trustAnchors = anchors
+	}
+	if opts.Class != "" {
+		class, ok := dns.StringToClass[strings.ToUpper(opts.Class)]
+		if !ok {
+			return nil, fmt.Errorf("unknown class %s", opts.Class)
+		}
+		s.qclass = class
+	}
+	if err := s.setResolver(opts.Resolver, opts.DoT, opts.DoTSNI, opts.DoTPins); err != nil {
+		return nil, err
+	}
+	switch {
+	case opts.IPv4 && opts.IPv6:
+		return nil, fmt.Errorf("-4 and -6 can't be combined")
+	case opts.IPv4:
+		s.ipFamily = 4
+	case opts.IPv6:
+		s.ipFamily = 6
+	}
+	for _, r := range append([]string{s.resolver}, s.resolverFallbacks...) {
+		if ip := net.ParseIP(serverHost(r)); ip != nil && len(s.inFamily([]net.IP{ip})) == 0 {
+			return nil, fmt.Errorf("resolver %s is not an IPv%v address, pick one with -resolver", r, s.ipFamily)
+		}
+	}
+	if opts.PSL != "" {
+		if err := s.loadPSL(opts.PSL); err != nil {
+			return nil, err
+		}
+	}
+	s.pickResolver()
+	return s, nil
+}
@@ -0,0 +1,400 @@
+package dt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// syncTypes are the record types compared per name by SyncNames.
+var syncTypes = []uint16{dns.TypeA, dns.TypeAAAA, dns.TypeCNAME, dns.TypeMX, dns.TypeTXT, dns.TypeNS}
+
+type SOACheck struct {
+	NS         []NSData
+	SOA        []SOAData
+	Domain     string
+	LastSerial uint32
+	// SyncNames are compared record by record between the nameservers to
+	// find out of sync secondaries, relative to the domain or absolute.
+	SyncNames []string
+	Report
+}
+
+type SOAData struct {
+	Name  string
+	IP    string
+	SOA   *dns.SOA
+	Error string
+	// Hashes maps every name of SyncNames to a hash of its records.
+	Hashes map[string]string
+}
+
+// syncName returns name as an absolute name, relative names are below domain.
+func syncName(name, domain string) string {
+	switch {
+	case name == "@":
+		return dns.Fqdn(domain)
+	case strings.HasSuffix(name, "."):
+		return name
+	}
+	return dns.Fqdn(name + "." + dns.Fqdn(domain))
+}
+
+// rrsetsHash returns a hash of the syncTypes records of name on server,
+// independent of their order and TTL.
+func (s *session) rrsetsHash(name, server string) string {
+	var rrs []string
+	for _, qtype := range syncTypes {
+		rrset, _, err := s.queryRRset(name, qtype, server, false)
+		if err != nil {
+			continue
+		}
+		for _, rr := range rrset {
+			rr = dns.Copy(rr)
+			rr.Header().Ttl = 0
+			rrs = append(rrs, strings.ToLower(rr.String()))
+		}
+	}
+	sort.Strings(rrs)
+	sum := sha256.Sum256([]byte(strings.Join(rrs, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (c *SOACheck) Scan(domain string) {
+	c.Domain = domain
+	c.SOA = make([]SOAData, serverCount(c.NS))
+	c.s.eachServer(c.NS, &c.Report, func(i int, ns NSData, nsip net.IP, r *Report) {
+		data := SOAData{Name: ns.Name, IP: ns.addr(nsip)}
+		soa, _, err := c.s.queryRRset(domain, dns.TypeSOA, ns.addr(nsip), true)
+		if !scanerror(r, "SOA scan", ns.Name, ns.addr(nsip), domain, soa, err) {
+			data.SOA = soa[0].(*dns.SOA)
+		} else if err != nil {
+			data.Error = err.Error()
+		}
+		if data.SOA != nil && len(c.SyncNames) > 0 {
+			data.Hashes = make(map[string]string)
+			for _, name := range c.SyncNames {
+				data.Hashes[name] = c.s.rrsetsHash(syncName(name, domain), ns.addr(nsip))
+			}
+		}
+		c.SOA[i] = data
+	})
+}
+
+func (c *SOACheck) checkMname(mname string) bool {
+	nsdata, err := c.s.findNS(c.s.parentZone(c.Domain))
+	if err != nil {
+		return false
+	}
+	var rrset []dns.RR
+loop:
+	for _, ns := range nsdata {
+		for _, nsip := range ns.IP {
+			res, err := c.s.query(dns.Fqdn(c.Domain), dns.TypeNS, ns.addr(nsip), true)
+			if err != nil {
+				break
+			}
+			rrset = extractRR(res.Msg.Ns, dns.TypeNS)
+			if len(rrset) > 0 {
+				break loop
+			}
+		}
+	}
+	for _, pns := range rrset {
+		if pns.(*dns.NS).Ns == mname {
+			return true
+		}
+	}
+	return false
+
+}
+
+func (c *SOACheck) Identical() ReportResult {
+	m := make(map[string][]string)
+	for _, ns := range c.SOA {
+		if ns.SOA != nil {
+			m[ns.SOA.String()] = append(m[ns.SOA.String()], ns.Name+"("+ns.IP+")")
+		}
+	}
+	res := ReportResult{}
+	if len(m) > 1 {
+		res.Result = fmt.Sprintf("FAIL: SOA not identical\n")
+		res.Status = false
+		res.Remediation = "Make sure all nameservers serve the same zone version. Check zone transfers (NOTIFY, AXFR/IXFR) to the secondaries."
+		for k, v := range m {
+			res.Result += fmt.Sprintf("\t %s\n\t %s\n", v, k)
+		}
+	} else {
+		res.Result = "OK  : SOA of all nameservers are identical"
+		res.Status = true
+	}
+	return res
+}
+
+// SerialSkew reports the serial of every nameserver and how far it lags
+// behind the newest one.
+func (c *SOACheck) SerialSkew() []ReportResult {
+	var newest *dns.SOA
+	for _, ns := range c.SOA {
+		if ns.SOA == nil {
+			continue
+		}
+		if newest == nil {
+			newest = ns.SOA
+		} else if cmp, _ := serialCompare(newest.Serial, ns.SOA.Serial); cmp < 0 {
+			newest = ns.SOA
+		}
+	}
+	if newest == nil {
+		return []ReportResult{}
+	}
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\t Nameserver\tSerial\tLag")
+	lagging, answered := 0, 0
+	var maxLag uint32
+	for _, ns := range c.SOA {
+		server := fmt.Sprintf("%s (%s)", ns.Name, ns.IP)
+		if ns.SOA == nil {
+			fmt.Fprintf(w, "\t %s\t-\t-\n", server)
+			continue
+		}
+		answered++
+		lag := newest.Serial - ns.SOA.Serial
+		if lag > 0 {
+			lagging++
+		}
+		if lag > maxLag {
+			maxLag = lag
+		}
+		fmt.Fprintf(w, "\t %s\t%v\t%v\n", server, ns.SOA.Serial, lag)
+	}
+	w.Flush()
+	table := strings.TrimRight(buf.String(), "\n")
+	if lagging == 0 {
+		return []ReportResult{{Result: fmt.Sprintf("OK  : All nameservers serve serial %v\n%s", newest.Serial, table),
+			Status: true, Name: "Skew"}}
+	}
+	return []ReportResult{{Result: fmt.Sprintf("FAIL: %v of %v nameservers lag behind serial %v, by up to %v\n%s", lagging, answered, newest.Serial, maxLag, table),
+		Status: false, Name: "Skew", Remediation: "Check NOTIFY and zone transfers (AXFR/IXFR) from the primary to the lagging secondaries."}}
+}
+
+// CheckSync compares the records of SyncNames between the nameservers.
+func (c *SOACheck) CheckSync() []ReportResult {
+	rep := []ReportResult{}
+	if len(c.SyncNames) == 0 {
+		return rep
+	}
+	for _, name := range c.SyncNames {
+		servers := make(map[string][]string)
+		var hashes []string
+		for _, ns := range c.SOA {
+			hash, ok := ns.Hashes[name]
+			if !ok {
+				continue
+			}
+			if _, seen := servers[hash]; !seen {
+				hashes = append(hashes, hash)
+			}
+			servers[hash] = append(servers[hash], fmt.Sprintf("%s (%s)", ns.Name, ns.IP))
+		}
+		if len(hashes) < 2 {
+			continue
+		}
+		res := ReportResult{Result: fmt.Sprintf("FAIL: Records of %s differ between nameservers", syncName(name, c.Domain)),
+			Status: false, Name: "Sync", Remediation: "Make sure all nameservers serve the same zone version. Check zone transfers (NOTIFY, AXFR/IXFR) to the secondaries."}
+		for _, hash := range hashes {
+			res.Result += fmt.Sprintf("\n\t %s: %s", hash, strings.Join(servers[hash], ", "))
+		}
+		rep = append(rep, res)
+	}
+	if len(rep) == 0 {
+		rep = append(rep, ReportResult{Result: fmt.Sprintf("OK  : Records of %v names are identical on all nameservers", len(c.SyncNames)),
+			Status: true, Name: "Sync"})
+	}
+	return rep
+}
+
+func checkSerial(serial uint32) bool {
+	serialstr := fmt.Sprintf("%v", serial)
+	if len(serialstr) != 10 {
+		return false
+	}
+	_, err := time.Parse("20060102", serialstr[:len(serialstr)-2])
+	if err != nil {
+		return false
+	}
+	return true
+}
+
+// serialCompare compares serials a and b using serial number arithmetic
+// (RFC 1982). It returns -1, 0 or 1 and ok is false when the comparison is
+// undefined (the serials are exactly 2^31 apart).
+func serialCompare(a, b uint32) (cmp int, ok bool) {
+	switch d := b - a; {
+	case d == 0:
+		return 0, true
+	case d == 1<<31:
+		return 0, false
+	case d < 1<<31:
+		return -1, true
+	}
+	return 1, true
+}
+
+// CheckSerialChange validates the change from the serial seen on a previous
+// run against the current serial.
+func (c *SOACheck) CheckSerialChange() []ReportResult {
+	rep := []ReportResult{}
+	if c.LastSerial == 0 {
+		return rep
+	}
+	for _, ns := range c.SOA {
+		if ns.SOA == nil {
+			continue
+		}
+		serial := ns.SOA.Serial
+		cmp, ok := serialCompare(c.LastSerial, serial)
+		switch {
+		case !ok:
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: Serial %v on %s is exactly 2^31 away from %v, the comparison is undefined (RFC 1982). Secondaries may refuse the update.", serial, ns.Name, c.LastSerial),
+				Status: false, Name: "SerialChange", Remediation: "Step the serial forward by less than 2^31 first, then to the intended value."})
+		case cmp > 0:
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: Serial %v on %s went backwards from %v (RFC 1982). Secondaries will not transfer the zone.", serial, ns.Name, c.LastSerial),
+				Status: false, Name: "SerialChange", Remediation: "Raise the serial past the old one, or step it forward by at most 2^31-1 twice (RFC 1982 section 7)."})
+		case cmp < 0 && serial < c.LastSerial:
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("OK  : Serial %v on %s wrapped around from %v.", serial, ns.Name, c.LastSerial),
+				Status: true, Name: "SerialChange"})
+		}
+	}
+	if len(rep) == 0 {
+		rep = append(rep, ReportResult{Result: fmt.Sprintf("OK  : Serial change from %v follows serial number arithmetic.", c.LastSerial),
+			Status: true, Name: "SerialChange"})
+	}
+	return rep
+}
+
+// dynamicHints returns the records suggesting the zone receives dynamic
+// updates (RFC 2136), like DHCID records or Active Directory locators.
+func (s *session) dynamicHints(domain string) []string {
+	var hints []string
+	if _, _, err := s.queryRRset(domain, dns.TypeDHCID, s.resolver, false); err == nil {
+		hints = append(hints, "DHCID record at the apex")
+	}
+	for _, name := range []string{"_ldap._tcp.dc._msdcs.", "_kerberos._udp."} {
+		if _, _, err := s.queryRRset(name+dns.Fqdn(domain), dns.TypeSRV, s.resolver, false); err == nil {
+			hints = append(hints, fmt.Sprintf("%s SRV record", name+dns.Fqdn(domain)))
+		}
+	}
+	return hints
+}
+
+// CheckUpdateTarget verifies the MNAME can receive dynamic updates when the
+// zone appears to use them: clients send updates to the MNAME.
+func (c *SOACheck) CheckUpdateTarget() []ReportResult {
+	rep := []ReportResult{}
+	var soa *dns.SOA
+	for _, ns := range c.SOA {
+		if ns.SOA != nil {
+			soa = ns.SOA
+		}
+	}
+	if soa == nil {
+		return rep
+	}
+	hints := c.s.dynamicHints(c.Domain)
+	if len(hints) == 0 {
+		return rep
+	}
+	mname := soa.Ns
+	if cname, _, err := c.s.queryRRset(mname, dns.TypeCNAME, c.s.resolver, false); err == nil {
+		return append(rep, ReportResult{Result: fmt.Sprintf("FAIL: Zone uses dynamic updates (%s) but MNAME %s is a CNAME to %s", strings.Join(hints, ", "), mname, cname[0].(*dns.CNAME).Target),
+			Status: false, Name: "UpdateTarget", Remediation: "Set the SOA MNAME to the canonical hostname of the primary nameserver."})
+	}
+	ips := c.s.resolveHost(mname)
+	if len(ips) == 0 {
+		return append(rep, ReportResult{Result: fmt.Sprintf("FAIL: Zone uses dynamic updates (%s) but MNAME %s doesn't resolve", strings.Join(hints, ", "), mname),
+			Status: false, Name: "UpdateTarget", Remediation: "Set the SOA MNAME to a hostname with A/AAAA records pointing at the primary nameserver."})
+	}
+	for _, ip := range ips {
+		res, err := c.s.queryNet(c.Domain, dns.TypeSOA, ip.String(), false, "tcp")
+		switch {
+		case err != nil:
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: MNAME %s (%s) is not reachable over TCP: %s", mname, ip, err),
+				Status: false, Name: "UpdateTarget", Remediation: "Allow TCP port 53 to the primary nameserver."})
+		case !res.Msg.Authoritative:
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: MNAME %s (%s) is not authoritative for %s", mname, ip, c.Domain),
+				Status: false, Name: "UpdateTarget", Remediation: "Set the SOA MNAME to the primary nameserver that serves the zone."})
+		}
+	}
+	if len(rep) == 0 {
+		rep = append(rep, ReportResult{Result: fmt.Sprintf("OK  : Zone uses dynamic updates (%s) and MNAME %s accepts queries over TCP", strings.Join(hints, ", "), mname),
+			Status: true, Name: "UpdateTarget"})
+	}
+	return rep
+}
+
+func (c *SOACheck) checkRFC1918() bool {
+	for _, ns := range c.NS {
+		for _, ip := range ns.IP {
+			if isRFC1918(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *SOACheck) Values() []ReportResult {
+	var soa *dns.SOA
+	var results []ReportResult
+	for _, ns := range c.SOA {
+		if ns.SOA != nil {
+			soa = ns.SOA
+		}
+	}
+	if soa == nil {
+		return results
+	}
+	if checkSerial(soa.Serial) {
+		results = append(results, ReportResult{Result: "OK  : Serial format appears to be in the recommended format of YYYYMMDDnn.",
+			Status: true, Records: []string{soa.String()}, Name: "Serial"})
+	} else {
+		results = append(results, ReportResult{Result: "WARN: Serial is not in the recommended format of YYYYMMDDnn.",
+			Status: false, Name: "Serial", Remediation: "Use a serial like 2024010100 (date plus a two digit revision)."})
+	}
+	if c.checkMname(soa.Ns) {
+		results = append(results, ReportResult{Result: fmt.Sprintf("OK  : MNAME %s is listed at the parent servers.", soa.Ns),
+			Status: true, Name: "MNAME"})
+	} else {
+		results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: MNAME %s is not listed at the parent servers.", soa.Ns),
+			Status: false, Name: "MNAME", Remediation: "Set the SOA MNAME to one of the nameservers in the delegation."})
+	}
+	if !c.checkRFC1918() {
+		results = append(results, ReportResult{Result: "OK  : Your nameservers have public / routable addresses.",
+			Status: true, Name: "RFC1918"})
+	} else {
+		results = append(results, ReportResult{Result: "FAIL: Some of your nameservers have non-routable (RFC1918) addresses.",
+			Status: false, Name: "RFC1918", Remediation: "Give all listed nameservers public addresses."})
+	}
+	return results
+}
+
+func (c *SOACheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "SOA"
+	c.Report.Result = append(c.Report.Result, c.Identical())
+	c.Report.Result = append(c.Report.Result, c.SerialSkew()...)
+	c.Report.Result = append(c.Report.Result, c.CheckSync()...)
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	c.Report.Result = append(c.Report.Result, c.CheckSerialChange()...)
+	c.Report.Result = append(c.Report.Result, c.CheckUpdateTarget()...)
+	return c.Report
+}
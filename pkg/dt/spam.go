@@ -0,0 +1,219 @@
+package dt
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+type SpamCheck struct {
+	NS        []NSData
+	Spam      []SpamData
+	OrgDomain string
+	OrgDmarc  []dns.RR
+	Report
+}
+
+type SpamData struct {
+	Name  string
+	IP    string
+	Dmarc []dns.RR
+	Spf   []dns.RR
+	Error string
+}
+
+func (c *SpamCheck) Scan(domain string) {
+	c.ScanDmarc(domain)
+	c.ScanSpf(domain)
+}
+
+func (c *SpamCheck) ScanDmarc(domain string) {
+	found := false
+	datas := make([]*SpamData, serverCount(c.NS))
+	c.s.eachServer(c.NS, &c.Report, func(i int, ns NSData, nsip net.IP, r *Report) {
+		data := SpamData{Name: ns.Name, IP: ns.addr(nsip)}
+		dmarc, _, err := c.s.queryRRset("_dmarc."+domain, dns.TypeTXT, ns.addr(nsip), true)
+		if !scanerror(r, "DMARC scan", ns.Name, ns.addr(nsip), domain, dmarc, err) {
+			data.Dmarc = dmarc
+			datas[i] = &data
+		}
+	})
+	for _, data := range datas {
+		if data != nil {
+			c.Spam = append(c.Spam, *data)
+			found = true
+		}
+	}
+	// fall back to the organizational domain (RFC 7489 section 6.6.3)
+	org := c.s.orgDomain(domain)
+	if !found && org != dns.Fqdn(domain) {
+		c.OrgDomain = org
+		c.OrgDmarc, _, _ = c.s.queryRRset("_dmarc."+org, dns.TypeTXT, c.s.resolver, false)
+	}
+}
+
+// dmarcTags parses the tag=value pairs of a DMARC record.
+func dmarcTags(txt string) map[string]string {
+	tags := make(map[string]string)
+	for _, part := range strings.Split(txt, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) == 2 {
+			tags[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
+		}
+	}
+	return tags
+}
+
+func (c *SpamCheck) ScanSpf(domain string) {
+	datas := make([]*SpamData, serverCount(c.NS))
+	c.s.eachServer(c.NS, &c.Report, func(i int, ns NSData, nsip net.IP, r *Report) {
+		data := SpamData{Name: ns.Name, IP: ns.addr(nsip)}
+		txt, _, err := c.s.queryRRset(domain, dns.TypeTXT, ns.addr(nsip), true)
+		if !scanerror(r, "SPF scan", ns.Name, ns.addr(nsip), domain, txt, err) {
+			spf := []dns.RR{}
+			for _, rr := range txt {
+				if strings.Contains(rr.String(), "v=spf") {
+					spf = append(spf, rr)
+				}
+			}
+			data.Spf = spf
+			datas[i] = &data
+		}
+	})
+	for _, data := range datas {
+		if data != nil {
+			c.Spam = append(c.Spam, *data)
+		}
+	}
+}
+
+func (c *SpamCheck) Values() []ReportResult {
+	var results []ReportResult
+	var rrset []dns.RR
+	for _, ns := range c.Spam {
+		if ns.Dmarc != nil {
+			rrset = ns.Dmarc
+			break
+		}
+	}
+	fallback := false
+	if len(rrset) == 0 && len(c.OrgDmarc) > 0 {
+		rrset = c.OrgDmarc
+		fallback = true
+	}
+	if len(rrset) > 0 {
+		if fallback {
+			results = append(results, ReportResult{Result: fmt.Sprintf("OK  : No DMARC records found, the policy of organizational domain %s applies.", c.OrgDomain),
+				Status: true, Name: "DMARC"})
+		} else {
+			results = append(results, ReportResult{Result: "OK  : DMARC records found.",
+				Status: true, Name: "DMARC"})
+		}
+		records := []string{}
+		for _, rr := range rrset {
+			records = append(records, rr.String())
+			tags := dmarcTags(txtString(rr.(*dns.TXT)))
+			policy := tags["p"]
+			if fallback && tags["sp"] != "" {
+				policy = tags["sp"]
+			}
+			switch policy {
+			case "none":
+				results = append(results, ReportResult{Result: "WARN: DMARC with monitoring policy found.",
+					Status: false, Name: "DMARCPolicy", Remediation: "Once reports show legitimate mail passes, move to p=quarantine and then p=reject."})
+			case "quarantine":
+				results = append(results, ReportResult{Result: "WARN: DMARC with quarantine policy found.",
+					Status: false, Name: "DMARCPolicy", Remediation: "Once reports show legitimate mail passes, move to p=reject."})
+			case "reject":
+				results = append(results, ReportResult{Result: "OK  : DMARC with reject policy.",
+					Status: true, Name: "DMARCPolicy"})
+			}
+		}
+		results = append(results, ReportResult{Status: true, Records: records})
+	} else {
+		results = append(results, ReportResult{Result: "WARN: No DMARC records found. Along with DKIM and SPF, DMARC helps prevent spam from your domain.",
+			Status: false, Name: "DMARC", Remediation: "Publish _dmarc TXT \"v=DMARC1; p=none; rua=mailto:dmarc@<domain>\" and tighten the policy later."})
+	}
+
+	var spf []dns.RR
+	for _, ns := range c.Spam {
+		if ns.Spf != nil {
+			spf = ns.Spf
+			break
+		}
+	}
+
+	if len(spf) > 0 {
+		records := []string{}
+		for _, rr := range spf {
+			records = append(records, rr.String())
+		}
+		results = append(results, ReportResult{Result: "OK  : SPF records found.",
+			Status: true, Records: records, Name: "SPF"})
+	} else {
+		results = append(results, ReportResult{Result: "WARN: No SPF records found. Along with DKIM and DMARC, SPF helps prevent spam from your domain.",
+			Status: false, Name: "SPF", Remediation: "Publish a TXT record like \"v=spf1 mx -all\" listing all hosts sending mail for the domain."})
+	}
+
+	for _, rr := range spf {
+		if strings.Contains(rr.String(), "-all") || strings.Contains(rr.String(), "~all") {
+			results = append(results, ReportResult{Result: "OK  : SPF records set up restrictively.",
+				Status: true, Name: "SPF"})
+		}
+		break
+	}
+
+	// TODO
+	// dmarc: p=none recommendation?
+	// spf: further recommendations ?
+
+	return results
+}
+
+// CheckDKIMWildcard asks for a random DKIM selector. When it exists, a
+// wildcard makes every selector resolve to bogus data, which breaks DKIM.
+func (c *SpamCheck) CheckDKIMWildcard(domain string) []ReportResult {
+	results := []ReportResult{}
+	if len(c.NS) == 0 || len(c.NS[0].IP) == 0 {
+		return results
+	}
+	selector := fmt.Sprintf("dt%v._domainkey.%s", dns.Id(), dns.Fqdn(domain))
+	res, err := c.s.query(selector, dns.TypeTXT, c.NS[0].addr(c.NS[0].IP[0]), false)
+	if err != nil {
+		results = append(results, ReportResult{Result: "OK  : No wildcard records under _domainkey found.",
+			Status: true, Name: "DKIMWildcard"})
+		return results
+	}
+	rrset := extractRR(res.Msg.Answer, dns.TypeTXT, dns.TypeCNAME)
+	if len(rrset) == 0 {
+		results = append(results, ReportResult{Result: "OK  : No wildcard records under _domainkey found.",
+			Status: true, Name: "DKIMWildcard"})
+		return results
+	}
+	owner := rrset[0].Header().Name
+	for _, rr := range rrset {
+		if txt, ok := rr.(*dns.TXT); ok {
+			value := txtString(txt)
+			if !strings.Contains(value, "p=") {
+				results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: A wildcard makes every DKIM selector exist with bogus data (%q). DKIM verification of your mail will fail subtly.", value),
+					Status: false, Name: "DKIMWildcard", Remediation: "Add an explicit empty non-terminal under _domainkey or remove the wildcard covering it."})
+				return results
+			}
+		}
+	}
+	results = append(results, ReportResult{Result: fmt.Sprintf("WARN: A wildcard makes every DKIM selector exist (random selector %s resolves). Verifiers can't tell missing selectors from real ones.", owner),
+		Status: false, Name: "DKIMWildcard", Remediation: "Remove the wildcard covering _domainkey or publish your selectors explicitly."})
+	return results
+}
+
+func (c *SpamCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "Spam"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	c.Report.Result = append(c.Report.Result, c.CheckDMARC(domain)...)
+	c.Report.Result = append(c.Report.Result, c.CheckSPF(domain)...)
+	c.Report.Result = append(c.Report.Result, c.CheckDKIMWildcard(domain)...)
+	return c.Report
+}
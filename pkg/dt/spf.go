@@ -0,0 +1,272 @@
+package dt
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// spfLookupLimit is the number of DNS querying terms an SPF evaluation may
+// need (RFC 7208 section 4.6.4).
+const spfLookupLimit = 10
+
+// spfTerm is a mechanism or modifier of an SPF record.
+type spfTerm struct {
+	Qualifier byte
+	Name      string
+	Arg       string
+	Modifier  bool
+}
+
+// spfText returns the SPF records among the TXT records.
+func spfText(rrset []dns.RR) []string {
+	var records []string
+	for _, rr := range rrset {
+		txt, ok := rr.(*dns.TXT)
+		if !ok {
+			continue
+		}
+		s := txtString(txt)
+		if l := strings.ToLower(s); l == "v=spf1" || strings.HasPrefix(l, "v=spf1 ") {
+			records = append(records, s)
+		}
+	}
+	return records
+}
+
+// spfCIDR validates the prefix lengths of a or mx ("/24", "//64", "/24//64").
+func spfCIDR(s string) error {
+	if s == "" {
+		return nil
+	}
+	parts := strings.SplitN(s, "//", 2)
+	if parts[0] != "" {
+		if n, err := strconv.Atoi(strings.TrimPrefix(parts[0], "/")); err != nil || n < 0 || n > 32 || !strings.HasPrefix(parts[0], "/") {
+			return fmt.Errorf("invalid IPv4 prefix length %s", parts[0])
+		}
+	}
+	if len(parts) == 2 {
+		if n, err := strconv.Atoi(parts[1]); err != nil || n < 0 || n > 128 {
+			return fmt.Errorf("invalid IPv6 prefix length //%s", parts[1])
+		}
+	}
+	return nil
+}
+
+// parseSPF parses and validates an SPF record (RFC 7208 section 4.6).
+func parseSPF(record string) ([]spfTerm, error) {
+	fields := strings.Fields(record)
+	if len(fields) == 0 || strings.ToLower(fields[0]) != "v=spf1" {
+		return nil, fmt.Errorf("does not start with v=spf1")
+	}
+	var terms []spfTerm
+	modifiers := make(map[string]bool)
+	for _, f := range fields[1:] {
+		t := spfTerm{Qualifier: '+'}
+		if i := strings.IndexAny(f, ":/="); i > 0 && f[i] == '=' {
+			t.Modifier, t.Name, t.Arg = true, strings.ToLower(f[:i]), f[i+1:]
+			if (t.Name == "redirect" || t.Name == "exp") && modifiers[t.Name] {
+				return nil, fmt.Errorf("more than one %s modifier", t.Name)
+			}
+			if (t.Name == "redirect" || t.Name == "exp") && t.Arg == "" {
+				return nil, fmt.Errorf("%s modifier without domain", t.Name)
+			}
+			modifiers[t.Name] = true
+			terms = append(terms, t)
+			continue
+		}
+		if strings.IndexByte("+-~?", f[0]) >= 0 {
+			t.Qualifier, f = f[0], f[1:]
+		}
+		t.Name = strings.ToLower(f)
+		if i := strings.IndexAny(f, ":/"); i >= 0 {
+			t.Name, t.Arg = strings.ToLower(f[:i]), f[i:]
+		}
+		domain, cidr := "", t.Arg
+		if strings.HasPrefix(t.Arg, ":") {
+			domain, cidr = t.Arg[1:], ""
+			if i := strings.Index(domain, "/"); i >= 0 {
+				domain, cidr = domain[:i], domain[i:]
+			}
+		}
+		switch t.Name {
+		case "all":
+			if t.Arg != "" {
+				return nil, fmt.Errorf("all takes no argument: %s", f)
+			}
+		case "include", "exists":
+			if domain == "" || cidr != "" {
+				return nil, fmt.Errorf("%s needs a domain: %s", t.Name, f)
+			}
+		case "a", "mx":
+			if err := spfCIDR(cidr); err != nil {
+				return nil, fmt.Errorf("%s: %s", f, err)
+			}
+		case "ptr":
+			if cidr != "" {
+				return nil, fmt.Errorf("ptr takes no prefix length: %s", f)
+			}
+		case "ip4", "ip6":
+			ip, bits := domain+cidr, 32
+			if t.Name == "ip6" {
+				bits = 128
+			}
+			addr := net.ParseIP(ip)
+			if strings.Contains(ip, "/") {
+				var n *net.IPNet
+				var err error
+				addr, n, err = net.ParseCIDR(ip)
+				if err == nil {
+					if ones, size := n.Mask.Size(); size != bits || ones > bits {
+						addr = nil
+					}
+				}
+			}
+			if addr == nil || (addr.To4() != nil) != (t.Name == "ip4") {
+				return nil, fmt.Errorf("invalid %s address: %s", t.Name, f)
+			}
+		default:
+			return nil, fmt.Errorf("unknown mechanism %s", f)
+		}
+		t.Arg = domain
+		terms = append(terms, t)
+	}
+	return terms, nil
+}
+
+// spfLookups counts the DNS querying terms of an SPF record, following
+// include and redirect. path holds the records on the include path to domain,
+// a target on it is a loop; a target included twice elsewhere is counted
+// twice, as an evaluator does. followed is the number of records fetched so
+// far. It returns the problems found along the way.
+func (s *session) spfLookups(domain string, terms []spfTerm, path map[string]bool, followed *int) (int, []string) {
+	name := strings.ToLower(dns.Fqdn(domain))
+	path[name] = true
+	defer delete(path, name)
+	count := 0
+	var problems []string
+	for _, t := range terms {
+		switch {
+		case t.Name == "a" || t.Name == "mx" || t.Name == "ptr" || t.Name == "exists":
+			count++
+			continue
+		case t.Name == "include" || t.Modifier && t.Name == "redirect":
+			count++
+		default:
+			continue
+		}
+		// targets with macros depend on the sender, offline none are fetched
+		if s.offline || strings.Contains(t.Arg, "%") {
+			continue
+		}
+		// every target followed costs a lookup, past the limit the record
+		// fails anyway and a hostile chain of includes could go on forever
+		if *followed > spfLookupLimit {
+			continue
+		}
+		target := strings.ToLower(dns.Fqdn(t.Arg))
+		if path[target] {
+			problems = append(problems, fmt.Sprintf("%s includes %s again, a loop", domain, target))
+			continue
+		}
+		*followed++
+		txt, _, err := s.queryRRset(target, dns.TypeTXT, s.resolver, false)
+		records := spfText(txt)
+		if err != nil && !strings.Contains(err.Error(), "NXDOMAIN") && !strings.Contains(err.Error(), "no rr for") {
+			problems = append(problems, fmt.Sprintf("looking up the SPF record of %s failed: %s", target, err))
+			continue
+		}
+		switch {
+		case len(records) == 0:
+			problems = append(problems, fmt.Sprintf("%s:%s has no SPF record (permerror)", t.Name, t.Arg))
+			continue
+		case len(records) > 1:
+			problems = append(problems, fmt.Sprintf("%s has %v SPF records (permerror)", target, len(records)))
+			continue
+		}
+		sub, err := parseSPF(records[0])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("SPF record of %s is invalid: %s", target, err))
+			continue
+		}
+		n, p := s.spfLookups(target, sub, path, followed)
+		count += n
+		problems = append(problems, p...)
+	}
+	return count, problems
+}
+
+// CheckSPF validates the SPF record of domain and counts its DNS lookups
+// against the limit of 10.
+func (c *SpamCheck) CheckSPF(domain string) []ReportResult {
+	results := []ReportResult{}
+	var records []string
+	for _, ns := range c.Spam {
+		if ns.Spf != nil {
+			records = spfText(ns.Spf)
+			break
+		}
+	}
+	switch {
+	case len(records) == 0:
+		return results
+	case len(records) > 1:
+		return append(results, ReportResult{Result: fmt.Sprintf("FAIL: %v SPF records found, receivers treat this as a permanent error", len(records)),
+			Status: false, Name: "SPFSyntax", Remediation: "Merge the SPF records into a single TXT record."})
+	}
+	terms, err := parseSPF(records[0])
+	if err != nil {
+		return append(results, ReportResult{Result: fmt.Sprintf("FAIL: SPF record is invalid: %s", err),
+			Status: false, Name: "SPFSyntax", Remediation: "Fix the record, see RFC 7208 section 5 for the mechanisms."})
+	}
+	final := false
+	for _, t := range terms {
+		switch {
+		case t.Name == "all":
+			final = true
+			if t.Qualifier == '+' || t.Qualifier == '?' {
+				results = append(results, ReportResult{Result: fmt.Sprintf("WARN: SPF record ends with %call, which allows anyone to send mail as you", t.Qualifier),
+					Status: false, Name: "SPFAll", Remediation: "End the record with -all or ~all."})
+			}
+		case t.Modifier && t.Name == "redirect":
+			final = true
+		case t.Name == "ptr":
+			results = append(results, ReportResult{Result: "WARN: SPF record uses the ptr mechanism, which is slow, unreliable and deprecated (RFC 7208 section 5.5)",
+				Status: false, Name: "SPFPtr", Remediation: "Replace ptr with ip4/ip6 or a mechanisms."})
+		}
+	}
+	if !final {
+		results = append(results, ReportResult{Result: "WARN: SPF record has no all mechanism or redirect, mail from unlisted hosts gets a neutral result",
+			Status: false, Name: "SPFAll", Remediation: "End the record with -all or ~all."})
+	}
+	lookups, problems := c.s.spfLookups(domain, terms, make(map[string]bool), new(int))
+	if l := strings.ToLower(records[0]); c.s.offline && (strings.Contains(l, "include:") || strings.Contains(l, "redirect=")) {
+		results = append(results, ReportResult{Result: "SKIP: SPF include and redirect targets not followed offline, their lookups are not counted",
+			Status: true, Name: "SPFInclude"})
+	}
+	for _, p := range problems {
+		res := ReportResult{Result: "FAIL: SPF " + p, Status: false, Name: "SPFInclude",
+			Remediation: "Fix or drop the include, every domain included must publish exactly one valid SPF record and none may include itself."}
+		if strings.HasPrefix(p, "looking up") {
+			res = ReportResult{Result: "ERR : SPF " + p, Status: false, Name: "SPFInclude", Error: p}
+		}
+		results = append(results, res)
+	}
+	valid := true
+	for _, res := range results {
+		if status := resultStatus(res); status == "FAIL" || status == "WARN" || status == "ERR" {
+			valid = false
+		}
+	}
+	if lookups > spfLookupLimit {
+		results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: SPF record needs %v DNS lookups, more than the limit of %v (permerror)", lookups, spfLookupLimit),
+			Status: false, Name: "SPFLookups", Remediation: "Replace includes by the ip4/ip6 ranges they resolve to, or drop unused ones."})
+	} else if valid {
+		results = append(results, ReportResult{Result: fmt.Sprintf("OK  : SPF record is valid and needs %v of %v DNS lookups", lookups, spfLookupLimit),
+			Status: true, Name: "SPFLookups"})
+	}
+	return results
+}
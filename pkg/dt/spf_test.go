@@ -0,0 +1,144 @@
+package dt
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func FuzzParseSPF(f *testing.F) {
+	for _, seed := range []string{
+		"v=spf1 -all",
+		"v=spf1 ip4:192.0.2.0/24 ip6:2001:db8::/32 a/24//64 mx include:_spf.example.com ~all",
+		"v=spf1 redirect=_spf.example.com exp=explain.example.com",
+		"v=spf1 exists:%{i}.example.com ?ptr +all",
+		"v=spf1 include: a//129 redirect= all:x",
+		"V=SPF1 MX -ALL",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, record string) {
+		terms, err := parseSPF(record)
+		if err != nil {
+			return
+		}
+		if len(terms) > len(strings.Fields(record))-1 {
+			t.Fatalf("%q: %v terms from %v fields", record, len(terms), len(strings.Fields(record)))
+		}
+		for _, term := range terms {
+			if strings.IndexByte("+-~?", term.Qualifier) < 0 {
+				t.Fatalf("%q: invalid qualifier %q", record, term.Qualifier)
+			}
+		}
+	})
+}
+
+// txtServer serves the TXT records of records on a local port and returns a
+// session resolving through it.
+func txtServer(t *testing.T, records map[string]string) *session {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("no local UDP:", err)
+	}
+	started := make(chan struct{})
+	server := &dns.Server{PacketConn: pc, NotifyStartedFunc: func() { close(started) },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			q := r.Question[0]
+			if txt, ok := records[q.Name]; ok && q.Qtype == dns.TypeTXT {
+				m.Answer = append(m.Answer, &dns.TXT{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 300},
+					Txt: []string{txt}})
+			} else if !ok {
+				m.Rcode = dns.RcodeNameError
+			}
+			w.WriteMsg(m)
+		})}
+	go server.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { server.Shutdown() })
+	s, err := newSession(context.Background(), Options{Resolver: pc.LocalAddr().String(), Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSPFLookups(t *testing.T) {
+	records := map[string]string{
+		"flat.test.":     "v=spf1 a mx ip4:192.0.2.1 -all",
+		"nested.test.":   "v=spf1 include:inc1.test -all",
+		"inc1.test.":     "v=spf1 a include:inc2.test -all",
+		"inc2.test.":     "v=spf1 mx exists:%{i}.inc2.test -all",
+		"redirect.test.": "v=spf1 redirect=inc2.test",
+		"diamond.test.":  "v=spf1 include:left.test include:right.test -all",
+		"left.test.":     "v=spf1 include:shared.test -all",
+		"right.test.":    "v=spf1 include:shared.test -all",
+		"shared.test.":   "v=spf1 a -all",
+		"loop.test.":     "v=spf1 include:back.test -all",
+		"back.test.":     "v=spf1 include:loop.test -all",
+		"missing.test.":  "v=spf1 include:none.test -all",
+		"macro.test.":    "v=spf1 include:%{d}.macro.test -all",
+		"nospf.test.":    "v=spf1 include:text.test -all",
+		"text.test.":     "not spf",
+	}
+	// a chain of includes longer than the limit is not followed to its end
+	for i := 0; i < 20; i++ {
+		records[fmt.Sprintf("chain%v.test.", i)] = fmt.Sprintf("v=spf1 include:chain%v.test -all", i+1)
+	}
+	s := txtServer(t, records)
+	tests := []struct {
+		domain  string
+		want    int
+		problem string
+	}{
+		{"flat.test", 2, ""},
+		{"nested.test", 5, ""},
+		{"redirect.test", 3, ""},
+		// a record included twice is counted twice, it is no loop
+		{"diamond.test", 6, ""},
+		{"loop.test", 2, "includes loop.test. again, a loop"},
+		{"missing.test", 1, "include:none.test has no SPF record"},
+		// macros depend on the sender and are counted without following
+		{"macro.test", 1, ""},
+		{"nospf.test", 1, "include:text.test has no SPF record"},
+		{"chain0.test", spfLookupLimit + 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			terms, err := parseSPF(s.mustTXT(t, tt.domain))
+			if err != nil {
+				t.Fatal(err)
+			}
+			followed := 0
+			got, problems := s.spfLookups(tt.domain, terms, make(map[string]bool), &followed)
+			if got != tt.want {
+				t.Errorf("%v lookups, want %v", got, tt.want)
+			}
+			found := tt.problem == ""
+			for _, p := range problems {
+				if tt.problem == "" {
+					t.Errorf("unexpected problem %q", p)
+				}
+				found = found || strings.Contains(p, tt.problem)
+			}
+			if !found {
+				t.Errorf("problems %q, want %q", problems, tt.problem)
+			}
+		})
+	}
+}
+
+// mustTXT returns the first TXT record of domain.
+func (s *session) mustTXT(t *testing.T, domain string) string {
+	txt, _, err := s.queryRRset(dns.Fqdn(domain), dns.TypeTXT, s.resolver, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return txtString(txt[0].(*dns.TXT))
+}
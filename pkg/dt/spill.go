@@ -0,0 +1,153 @@
+package dt
+
+import (
+	"bufio"
+	"container/heap"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// spillLimit is the number of lines a spillSort keeps in memory before
+// writing them sorted to a temporary file.
+const spillLimit = 100000
+
+// spillSort sorts more lines than fit in memory: lines are added in chunks,
+// each sorted and spilled to disk, and merged back in order by Each. Lines
+// must not contain a newline.
+type spillSort struct {
+	lines []string
+	files []string
+	err   error
+}
+
+func (s *spillSort) Add(line string) {
+	s.lines = append(s.lines, line)
+	if len(s.lines) >= spillLimit {
+		s.spill()
+	}
+}
+
+// spill writes the lines in memory sorted to a temporary file.
+func (s *spillSort) spill() {
+	if s.err != nil {
+		s.lines = s.lines[:0]
+		return
+	}
+	sort.Strings(s.lines)
+	f, err := ioutil.TempFile("", "dt-spill-")
+	if err != nil {
+		s.err = err
+		return
+	}
+	w := bufio.NewWriter(f)
+	for _, line := range s.lines {
+		w.WriteString(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		s.err = err
+	}
+	f.Close()
+	s.files = append(s.files, f.Name())
+	s.lines = s.lines[:0]
+}
+
+// mergeItem is the current line of a spilled file.
+type mergeItem struct {
+	line    string
+	scanner *bufio.Scanner
+}
+
+type mergeHeap []*mergeItem
+
+func (h mergeHeap) Len() int            { return len(h) }
+func (h mergeHeap) Less(i, j int) bool  { return h[i].line < h[j].line }
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(*mergeItem)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// Each calls fn with every line in sorted order and removes the temporary
+// files.
+func (s *spillSort) Each(fn func(line string)) error {
+	defer s.Close()
+	if len(s.files) == 0 {
+		sort.Strings(s.lines)
+		for _, line := range s.lines {
+			fn(line)
+		}
+		return s.err
+	}
+	if len(s.lines) > 0 {
+		s.spill()
+	}
+	if s.err != nil {
+		return s.err
+	}
+	h := &mergeHeap{}
+	for _, name := range s.files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		if scanner.Scan() {
+			heap.Push(h, &mergeItem{scanner.Text(), scanner})
+		}
+	}
+	for h.Len() > 0 {
+		item := (*h)[0]
+		fn(item.line)
+		if item.scanner.Scan() {
+			item.line = item.scanner.Text()
+			heap.Fix(h, 0)
+		} else {
+			if err := item.scanner.Err(); err != nil {
+				return err
+			}
+			heap.Pop(h)
+		}
+	}
+	return nil
+}
+
+// Close removes the temporary files.
+func (s *spillSort) Close() {
+	for _, name := range s.files {
+		os.Remove(name)
+	}
+	s.files, s.lines = nil, nil
+}
+
+// canonicalKey returns a sort key for name that orders names like the
+// canonical DNS order: a name before the names below it, which follow it
+// without other names in between.
+func canonicalKey(name string) string {
+	labels := dns.SplitDomainName(strings.ToLower(name))
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return strings.Join(labels, "\x01")
+}
+
+// keyName returns the name of a canonicalKey.
+func keyName(key string) string {
+	if key == "" {
+		return "."
+	}
+	labels := strings.Split(key, "\x01")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return strings.Join(labels, ".") + "."
+}
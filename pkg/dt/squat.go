@@ -0,0 +1,171 @@
+package dt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/miekg/dns"
+)
+
+var squatTLDs = []string{"com", "net", "org", "info", "biz", "co", "io", "me", "eu", "us", "uk", "de", "nl", "be", "fr", "cc", "tv", "xyz", "online", "site"}
+
+// squatGlyphs are ASCII sequences that are easily mistaken for each other.
+var squatGlyphs = map[string][]string{
+	"o": {"0"}, "0": {"o"}, "l": {"1", "i"}, "1": {"l", "i"}, "i": {"1", "l"},
+	"m": {"rn", "nn"}, "rn": {"m"}, "w": {"vv"}, "vv": {"w"}, "d": {"cl"}, "cl": {"d"},
+	"s": {"5"}, "5": {"s"}, "e": {"3"}, "a": {"4"}, "g": {"q", "9"}, "q": {"g"}, "b": {"6"}, "u": {"v"}, "v": {"u"},
+}
+
+type SquatCandidate struct {
+	Domain string
+	Kind   string
+	NS     []string
+	MX     []string
+	Mail   bool
+}
+
+func validLabel(label string) bool {
+	if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, c := range label {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// squatPermutations generates bitflip, homoglyph, TLD swap and hyphenation
+// variants of domain, keyed on the variant.
+func squatPermutations(domain string) map[string]string {
+	m := make(map[string]string)
+	labels := dns.SplitDomainName(strings.ToLower(dns.Fqdn(domain)))
+	if len(labels) < 2 {
+		return m
+	}
+	name, suffix := labels[0], strings.Join(labels[1:], ".")
+	original := name + "." + suffix
+	add := func(label, suffix, kind string) {
+		candidate := label + "." + suffix
+		if validLabel(label) && candidate != original {
+			if _, ok := m[candidate]; !ok {
+				m[candidate] = kind
+			}
+		}
+	}
+	// bitflips
+	for i := 0; i < len(name); i++ {
+		for bit := uint(0); bit < 8; bit++ {
+			b := []byte(name)
+			b[i] ^= 1 << bit
+			add(string(b), suffix, "bitflip")
+		}
+	}
+	// homoglyphs
+	for from, tos := range squatGlyphs {
+		for i := 0; i < len(name); i++ {
+			if !strings.HasPrefix(name[i:], from) {
+				continue
+			}
+			for _, to := range tos {
+				add(name[:i]+to+name[i+len(from):], suffix, "homoglyph")
+			}
+		}
+	}
+	// TLD swaps
+	for _, tld := range squatTLDs {
+		add(name, tld, "tld")
+	}
+	// hyphenation
+	for i := 1; i < len(name); i++ {
+		add(name[:i]+"-"+name[i:], suffix, "hyphenation")
+	}
+	add(strings.Replace(name, "-", "", -1), suffix, "hyphenation")
+	return m
+}
+
+func (s *session) squatLookup(candidate SquatCandidate) (SquatCandidate, bool) {
+	ns, _, err := s.queryRRset(candidate.Domain, dns.TypeNS, s.resolver, false)
+	if err != nil {
+		return candidate, false
+	}
+	for _, rr := range ns {
+		candidate.NS = append(candidate.NS, rr.(*dns.NS).Ns)
+	}
+	mx, _, _ := s.queryRRset(candidate.Domain, dns.TypeMX, s.resolver, false)
+	for _, rr := range mx {
+		host := rr.(*dns.MX).Mx
+		candidate.MX = append(candidate.MX, host)
+		// a null MX (RFC 7505) explicitly refuses mail
+		if host != "." && len(s.resolveHost(host)) > 0 {
+			candidate.Mail = true
+		}
+	}
+	return candidate, true
+}
+
+// Squat looks up typosquatting variants of domain and prints the registered
+// ones.
+func Squat(domain string, opts Options) error {
+	s, err := newSession(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	perms := squatPermutations(domain)
+	if len(perms) == 0 {
+		return fmt.Errorf("no permutations for %s", domain)
+	}
+	spin := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	spin.Suffix = fmt.Sprintf(" Checking %v permutations...", len(perms))
+	if !s.debug {
+		spin.Start()
+	}
+
+	reqc := make(chan SquatCandidate)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var registered []SquatCandidate
+	limiter := time.Tick(time.Second / time.Duration(s.qps))
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			for candidate := range reqc {
+				<-limiter
+				if res, ok := s.squatLookup(candidate); ok {
+					mu.Lock()
+					registered = append(registered, res)
+					mu.Unlock()
+				}
+			}
+			wg.Done()
+		}()
+	}
+	for candidate, kind := range perms {
+		reqc <- SquatCandidate{Domain: candidate, Kind: kind}
+	}
+	close(reqc)
+	wg.Wait()
+	spin.Stop()
+
+	sort.Slice(registered, func(i, j int) bool { return registered[i].Domain < registered[j].Domain })
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+	fmt.Fprintf(w, "Domain\tType\tNS\tMX\tReceives mail\n")
+	for _, c := range registered {
+		mail := "no"
+		if c.Mail {
+			mail = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.Domain, c.Kind, strings.Join(c.NS, " "), strings.Join(c.MX, " "), mail)
+	}
+	w.Flush()
+	fmt.Printf("\n%v of %v permutations are registered\n", len(registered), len(perms))
+	return nil
+}
@@ -0,0 +1,413 @@
+package dt
+
+import (
+	"bufio"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// sshfpAlgorithm is an SSHFP algorithm number (RFC 4255, RFC 6594, RFC 7479,
+// RFC 8709) with the SSH host key algorithms it covers.
+type sshfpAlgorithm struct {
+	Name     string
+	HostKey  string
+	Obsolete bool
+}
+
+var sshfpAlgorithms = map[uint8]sshfpAlgorithm{
+	1: {Name: "RSA", HostKey: "rsa-sha2-512,rsa-sha2-256,ssh-rsa"},
+	2: {Name: "DSA", HostKey: "ssh-dss", Obsolete: true},
+	3: {Name: "ECDSA", HostKey: "ecdsa-sha2-nistp256,ecdsa-sha2-nistp384,ecdsa-sha2-nistp521"},
+	4: {Name: "Ed25519", HostKey: "ssh-ed25519"},
+	6: {Name: "Ed448", HostKey: "ssh-ed448"},
+}
+
+// sshfpLength is the fingerprint length in hex per SSHFP fingerprint type.
+var sshfpLength = map[uint8]int{1: 2 * sha1.Size, 2: 2 * sha256.Size}
+
+// SSHFPCheck validates the SSHFP records of the apex, the nameservers in the
+// domain and Hosts, and with Connect compares them with the host keys the
+// hosts offer on port 22.
+type SSHFPCheck struct {
+	NS      []NSData
+	Hosts   []string
+	Connect bool
+	SSHFP   []SSHFPData
+	Report
+}
+
+type SSHFPData struct {
+	Host  string
+	SSHFP []*dns.SSHFP
+	// Secure is set when the resolver validated the SSHFP records.
+	Secure bool
+	IP     string
+	// Keys maps the algorithms of the SSHFP records to the host key the host
+	// offered for them, Errors to why it offered none.
+	Keys   map[uint8][]byte
+	Errors map[uint8]string
+	Error  string
+}
+
+// sshPacket frames payload as an unencrypted SSH binary packet (RFC 4253
+// section 6).
+func sshPacket(payload []byte) []byte {
+	pad := 8 - (5+len(payload))%8
+	if pad < 4 {
+		pad += 8
+	}
+	b := make([]byte, 5, 5+len(payload)+pad)
+	binary.BigEndian.PutUint32(b, uint32(1+len(payload)+pad))
+	b[4] = byte(pad)
+	b = append(b, payload...)
+	return append(b, make([]byte, pad)...)
+}
+
+// sshReadPacket returns the payload of the next unencrypted packet.
+func sshReadPacket(r io.Reader) ([]byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(hdr[:4])
+	if length < 1+uint32(hdr[4]) || length > 256*1024 {
+		return nil, fmt.Errorf("invalid SSH packet length %v", length)
+	}
+	b := make([]byte, length-1)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b[:len(b)-int(hdr[4])], nil
+}
+
+// sshString reads an SSH string (uint32 length and data) from b.
+func sshString(b []byte) (s, rest []byte, ok bool) {
+	if len(b) < 4 {
+		return nil, nil, false
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint32(len(b)-4) < n {
+		return nil, nil, false
+	}
+	return b[4 : 4+n], b[4+n:], true
+}
+
+func appendSSHString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// sshHostKey fetches the host key of ip for one of hostKeys (a name-list)
+// by running the key exchange until the server sends its key. The exchange
+// isn't completed, so the key isn't proven to belong to the server, which
+// doesn't matter to compare it with fingerprints.
+func sshHostKey(ip net.IP, hostKeys string) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip.String(), "22"), 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	if _, err := io.WriteString(conn, "SSH-2.0-dt\r\n"); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	// servers may send other lines before their version (RFC 4253 section 4.2)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(line, "SSH-") {
+			break
+		}
+	}
+	kexs := []string{"curve25519-sha256", "curve25519-sha256@libssh.org", "ecdh-sha2-nistp256"}
+	kexinit := []byte{20}
+	cookie := make([]byte, 16)
+	rand.Read(cookie)
+	kexinit = append(kexinit, cookie...)
+	for _, list := range []string{strings.Join(kexs, ","), hostKeys,
+		"aes128-ctr,aes256-ctr,chacha20-poly1305@openssh.com,aes128-gcm@openssh.com", "aes128-ctr,aes256-ctr,chacha20-poly1305@openssh.com,aes128-gcm@openssh.com",
+		"hmac-sha2-256,hmac-sha2-512,hmac-sha2-256-etm@openssh.com", "hmac-sha2-256,hmac-sha2-512,hmac-sha2-256-etm@openssh.com",
+		"none", "none", "", ""} {
+		kexinit = appendSSHString(kexinit, list)
+	}
+	kexinit = append(kexinit, 0, 0, 0, 0, 0)
+	if _, err := conn.Write(sshPacket(kexinit)); err != nil {
+		return nil, err
+	}
+	var curve ecdh.Curve
+	for curve == nil {
+		payload, err := sshReadPacket(r)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case len(payload) > 0 && payload[0] == 1:
+			return nil, fmt.Errorf("disconnected: %s", sshDisconnect(payload))
+		case len(payload) > 17 && payload[0] == 20:
+			list, _, ok := sshString(payload[17:])
+			if !ok {
+				return nil, fmt.Errorf("invalid KEXINIT")
+			}
+			server := "," + string(list) + ","
+			for _, kex := range kexs {
+				if strings.Contains(server, ","+kex+",") {
+					if strings.HasPrefix(kex, "curve25519") {
+						curve = ecdh.X25519()
+					} else {
+						curve = ecdh.P256()
+					}
+					break
+				}
+			}
+			if curve == nil {
+				return nil, fmt.Errorf("no supported key exchange in %s", list)
+			}
+		}
+	}
+	key, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(sshPacket(appendSSHString([]byte{30}, string(key.PublicKey().Bytes())))); err != nil {
+		return nil, err
+	}
+	for {
+		payload, err := sshReadPacket(r)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case len(payload) > 0 && payload[0] == 1:
+			return nil, fmt.Errorf("disconnected: %s", sshDisconnect(payload))
+		case len(payload) > 0 && payload[0] == 31:
+			blob, _, ok := sshString(payload[1:])
+			if !ok {
+				return nil, fmt.Errorf("invalid KEX_ECDH_REPLY")
+			}
+			return blob, nil
+		}
+	}
+}
+
+// sshDisconnect returns the description of an SSH_MSG_DISCONNECT.
+func sshDisconnect(payload []byte) string {
+	if len(payload) < 5 {
+		return "no reason given"
+	}
+	desc, _, ok := sshString(payload[5:])
+	if !ok {
+		return "no reason given"
+	}
+	return string(desc)
+}
+
+// sshfpMatches reports whether the fingerprint of rr is the one of blob.
+func sshfpMatches(rr *dns.SSHFP, blob []byte) bool {
+	var digest []byte
+	switch rr.Type {
+	case 1:
+		sum := sha1.Sum(blob)
+		digest = sum[:]
+	case 2:
+		sum := sha256.Sum256(blob)
+		digest = sum[:]
+	default:
+		return false
+	}
+	return strings.EqualFold(rr.FingerPrint, hex.EncodeToString(digest))
+}
+
+func (c *SSHFPCheck) Scan(domain string) {
+	apex := strings.ToLower(dns.Fqdn(domain))
+	hosts := []string{apex}
+	for _, ns := range c.NS {
+		if dns.IsSubDomain(apex, strings.ToLower(ns.Name)) {
+			hosts = append(hosts, strings.ToLower(ns.Name))
+		}
+	}
+	for _, host := range c.Hosts {
+		hosts = append(hosts, strings.ToLower(syncName(host, domain)))
+	}
+	seen := make(map[string]bool)
+	for _, host := range hosts {
+		if seen[host] {
+			continue
+		}
+		seen[host] = true
+		data := SSHFPData{Host: host}
+		res, err := c.s.query(host, dns.TypeSSHFP, c.s.resolver, true)
+		if err == nil {
+			for _, rr := range extractRR(res.Msg.Answer, dns.TypeSSHFP) {
+				data.SSHFP = append(data.SSHFP, rr.(*dns.SSHFP))
+			}
+			data.Secure = res.Msg.AuthenticatedData
+		}
+		if len(data.SSHFP) > 0 && c.Connect {
+			c.scanKeys(&data)
+		}
+		c.SSHFP = append(c.SSHFP, data)
+	}
+}
+
+// scanKeys fetches the host key of every algorithm with SSHFP records.
+func (c *SSHFPCheck) scanKeys(data *SSHFPData) {
+	ips := c.s.resolveHost(data.Host)
+	if len(ips) == 0 {
+		data.Error = "no A/AAAA records"
+		return
+	}
+	data.IP = ips[0].String()
+	data.Keys = make(map[uint8][]byte)
+	data.Errors = make(map[uint8]string)
+	for _, rr := range data.SSHFP {
+		alg, ok := sshfpAlgorithms[rr.Algorithm]
+		if !ok {
+			continue
+		}
+		if _, done := data.Keys[rr.Algorithm]; done {
+			continue
+		}
+		if _, done := data.Errors[rr.Algorithm]; done {
+			continue
+		}
+		blob, err := sshHostKey(ips[0], alg.HostKey)
+		if err != nil {
+			data.Errors[rr.Algorithm] = err.Error()
+			// without a connection the other algorithms fail the same way
+			if _, ok := err.(net.Error); ok {
+				data.Error = err.Error()
+				return
+			}
+			continue
+		}
+		c.s.log.Debugf("Host key of %s (%s) for %s: %x", data.Host, data.IP, alg.Name, sha256.Sum256(blob))
+		data.Keys[rr.Algorithm] = blob
+	}
+}
+
+func (c *SSHFPCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	var with []string
+	for _, d := range c.SSHFP {
+		if len(d.SSHFP) == 0 {
+			continue
+		}
+		with = append(with, d.Host)
+		var records, invalid, obsolete []string
+		sha256s := make(map[uint8]bool)
+		for _, rr := range d.SSHFP {
+			records = append(records, rr.String())
+			alg, ok := sshfpAlgorithms[rr.Algorithm]
+			length, known := sshfpLength[rr.Type]
+			switch {
+			case !ok:
+				invalid = append(invalid, fmt.Sprintf("unknown algorithm %v", rr.Algorithm))
+			case !known:
+				invalid = append(invalid, fmt.Sprintf("unknown fingerprint type %v", rr.Type))
+			case len(rr.FingerPrint) != length:
+				invalid = append(invalid, fmt.Sprintf("%s fingerprint of %v hex digits instead of %v", alg.Name, len(rr.FingerPrint), length))
+			case alg.Obsolete:
+				obsolete = append(obsolete, alg.Name)
+			}
+			if rr.Type == 2 {
+				sha256s[rr.Algorithm] = true
+			}
+		}
+		var sha1Only []string
+		for _, rr := range d.SSHFP {
+			if alg, ok := sshfpAlgorithms[rr.Algorithm]; ok && rr.Type == 1 && !sha256s[rr.Algorithm] {
+				sha1Only = append(sha1Only, alg.Name)
+				sha256s[rr.Algorithm] = true
+			}
+		}
+		if len(invalid) > 0 {
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: Invalid SSHFP records for %s: %s", d.Host, strings.Join(invalid, ", ")),
+				Status: false, Name: "SSHFP", Records: records, Remediation: "Generate the records with ssh-keygen -r on the host."})
+		}
+		if len(obsolete) > 0 {
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: SSHFP records for %s publish obsolete %s host keys", d.Host, strings.Join(obsolete, ", ")),
+				Status: false, Name: "SSHFP", Remediation: "Remove the DSA host key from the host and its SSHFP records, OpenSSH no longer accepts it."})
+		}
+		if len(sha1Only) > 0 {
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: SSHFP records for %s have only SHA-1 fingerprints for %s", d.Host, strings.Join(sha1Only, ", ")),
+				Status: false, Name: "SSHFP", Remediation: "Publish SHA-256 fingerprints (type 2, RFC 6594)."})
+		}
+		if !d.Secure {
+			results = append(results, ReportResult{Result: fmt.Sprintf("WARN: SSHFP records for %s are not DNSSEC validated, SSH clients don't trust them", d.Host),
+				Status: false, Name: "SSHFP", Remediation: "Sign the zone, VerifyHostKeyDNS only trusts validated records."})
+		}
+		if !c.Connect {
+			continue
+		}
+		if d.Error != "" {
+			results = append(results, ReportResult{Result: fmt.Sprintf("ERR : Fetching the host keys of %s (%s) failed: %s", d.Host, d.IP, d.Error),
+				Status: false, Name: "SSHFP", Error: d.Error})
+			continue
+		}
+		var algs []int
+		for alg := range d.Keys {
+			algs = append(algs, int(alg))
+		}
+		for alg := range d.Errors {
+			algs = append(algs, int(alg))
+		}
+		sort.Ints(algs)
+		for _, a := range algs {
+			alg := uint8(a)
+			name := sshfpAlgorithms[alg].Name
+			blob, ok := d.Keys[alg]
+			if !ok {
+				results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: %s (%s) has %s SSHFP records but offers no such host key: %s", d.Host, d.IP, name, d.Errors[alg]),
+					Status: false, Name: "SSHFP", Remediation: "Remove the SSHFP records of host keys the host no longer has."})
+				continue
+			}
+			matched := false
+			for _, rr := range d.SSHFP {
+				if rr.Algorithm == alg && sshfpMatches(rr, blob) {
+					matched = true
+				}
+			}
+			if matched {
+				results = append(results, ReportResult{Result: fmt.Sprintf("OK  : %s host key of %s (%s) matches its SSHFP records", name, d.Host, d.IP),
+					Status: true, Name: "SSHFP"})
+			} else {
+				results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: %s host key of %s (%s) matches none of its SSHFP records, clients will warn about a changed key", name, d.Host, d.IP),
+					Status: false, Name: "SSHFP", Records: records, Remediation: "Publish the output of ssh-keygen -r on the host after changing its keys."})
+			}
+		}
+	}
+	if len(with) == 0 {
+		if len(c.SSHFP) > 0 {
+			results = append(results, ReportResult{Result: "SKIP: No SSHFP records for the apex, the nameservers and the hosts given",
+				Status: true, Name: "SSHFP"})
+		}
+		return results
+	}
+	if len(results) == 0 {
+		results = append(results, ReportResult{Result: fmt.Sprintf("OK  : SSHFP records of %s are valid", strings.Join(with, ", ")),
+			Status: true, Name: "SSHFP"})
+	}
+	return results
+}
+
+func (c *SSHFPCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "SSHFP"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
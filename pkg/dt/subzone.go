@@ -0,0 +1,179 @@
+package dt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// maxNSECWalk is the maximum number of names visited walking the NSEC chain.
+const maxNSECWalk = 1000
+
+// maxSubzoneDepth is the maximum depth of subzones checked with -recurse.
+const maxSubzoneDepth = 3
+
+type SubzoneCheck struct {
+	NS       []NSData
+	List     []string
+	Source   string
+	Subzones []string
+	Lame     map[string][]string
+	Missing  []string
+	Report
+}
+
+// nsecWalk follows the NSEC chain of domain on server and returns the names
+// that have NS records, excluding the apex.
+func (s *session) nsecWalk(domain, server string) []string {
+	var delegations []string
+	apex := strings.ToLower(dns.Fqdn(domain))
+	name := apex
+	for i := 0; i < maxNSECWalk; i++ {
+		res, err := s.query(name, dns.TypeNSEC, server, true)
+		if err != nil {
+			return delegations
+		}
+		nsec := extractRR(res.Msg.Answer, dns.TypeNSEC)
+		if len(nsec) == 0 && len(extractRR(res.Msg.Ns, dns.TypeNS)) > 0 {
+			// referral, the NSEC of a delegation point is returned for DS
+			res, err = s.query(name, dns.TypeDS, server, true)
+			if err != nil {
+				return delegations
+			}
+			nsec = extractRR(res.Msg.Ns, dns.TypeNSEC)
+		}
+		if len(nsec) == 0 {
+			return delegations
+		}
+		rr := nsec[0].(*dns.NSEC)
+		if strings.ToLower(rr.Header().Name) != name {
+			return delegations
+		}
+		for _, t := range rr.TypeBitMap {
+			if t == dns.TypeNS && name != apex {
+				delegations = append(delegations, name)
+			}
+		}
+		name = strings.ToLower(rr.NextDomain)
+		if name == apex || !dns.IsSubDomain(apex, name) {
+			break
+		}
+	}
+	return delegations
+}
+
+// delegated returns the NS records of name when server delegates it.
+func (s *session) delegated(name, server string) []dns.RR {
+	res, err := s.query(name, dns.TypeNS, server, false)
+	if err != nil {
+		return nil
+	}
+	if ns := extractRR(res.Msg.Answer, dns.TypeNS); len(ns) > 0 {
+		return ns
+	}
+	return extractRR(res.Msg.Ns, dns.TypeNS)
+}
+
+// findSubzones returns the delegated subzones of domain in the zone transfer
+// from nsdatas or, without one, by walking the NSEC chain on server, with how
+// they were found ("" when none were).
+func (s *session) findSubzones(domain string, nsdatas []NSData, server string) ([]string, string) {
+	apex := strings.ToLower(dns.Fqdn(domain))
+	var subzones []string
+	seen := make(map[string]bool)
+	source, _ := s.streamZone("", nsdatas, apex, func(r ZoneRecord) {
+		if name := strings.ToLower(r.RR.Header().Name); r.RR.Header().Rrtype == dns.TypeNS && name != apex && !seen[name] {
+			seen[name] = true
+			subzones = append(subzones, name)
+		}
+	})
+	if source != "" {
+		return subzones, "AXFR"
+	}
+	if subzones = s.nsecWalk(domain, server); len(subzones) > 0 {
+		return subzones, "NSEC walk"
+	}
+	return nil, ""
+}
+
+func (c *SubzoneCheck) Scan(domain string) {
+	apex := strings.ToLower(dns.Fqdn(domain))
+	c.Lame = make(map[string][]string)
+	if len(c.NS) == 0 || len(c.NS[0].IP) == 0 {
+		return
+	}
+	server := c.NS[0].addr(c.NS[0].IP[0])
+	m := make(map[string]bool)
+	for _, name := range c.List {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		name = strings.ToLower(dns.Fqdn(name))
+		if !dns.IsSubDomain(apex, name) {
+			name = strings.ToLower(dns.Fqdn(strings.TrimSuffix(name, ".") + "." + apex))
+		}
+		if ns := c.s.delegated(name, server); len(ns) > 0 && strings.ToLower(ns[0].Header().Name) == name {
+			m[name] = true
+		} else {
+			c.Missing = append(c.Missing, name)
+		}
+	}
+	c.Source = "list"
+	found, source := c.s.findSubzones(domain, c.NS, server)
+	if source != "" {
+		c.Source = source
+	}
+	for _, name := range found {
+		m[name] = true
+	}
+	for name := range m {
+		c.Subzones = append(c.Subzones, name)
+	}
+	sort.Strings(c.Subzones)
+
+	for _, name := range c.Subzones {
+		for _, rr := range c.s.delegated(name, server) {
+			host := rr.(*dns.NS).Ns
+			ips := c.s.resolveHost(host)
+			if len(ips) == 0 {
+				c.Lame[name] = append(c.Lame[name], fmt.Sprintf("%s (no address)", host))
+			}
+			for _, ip := range ips {
+				res, err := c.s.query(name, dns.TypeSOA, ip.String(), false)
+				if err != nil || !res.Msg.Authoritative {
+					c.Lame[name] = append(c.Lame[name], fmt.Sprintf("%s (%s)", host, ip))
+				}
+			}
+		}
+	}
+}
+
+func (c *SubzoneCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	for _, name := range c.Missing {
+		results = append(results, ReportResult{Result: fmt.Sprintf("WARN: %s is not a delegated subzone", name),
+			Status: false, Name: "Subzone", Remediation: "Delegate the subzone with NS records at the parent, or remove it from the list."})
+	}
+	if len(c.Subzones) == 0 {
+		return append(results, ReportResult{Result: "OK  : No delegated subzones found",
+			Status: true, Name: "Subzone"})
+	}
+	results = append(results, ReportResult{Result: fmt.Sprintf("OK  : Found %v delegated subzones (%s): %s", len(c.Subzones), c.Source, strings.Join(c.Subzones, ", ")),
+		Status: true, Name: "Subzone"})
+	for _, name := range c.Subzones {
+		if lame := c.Lame[name]; len(lame) > 0 {
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: Subzone %s has lame nameservers: %s", name, strings.Join(lame, ", ")),
+				Status: false, Name: "Lame", Remediation: "Load the subzone on the lame nameservers or remove them from its delegation."})
+		}
+	}
+	return results
+}
+
+func (c *SubzoneCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "Subzones"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
@@ -0,0 +1,57 @@
+package dt
+
+import (
+	"sort"
+	"strings"
+)
+
+// summaryTop is the number of most critical findings in the summary.
+const summaryTop = 3
+
+type Summary struct {
+	Counts map[string]int
+	Top    []string
+	Grade  string
+	// Parked lists why the domain looks parked, its checks are skipped.
+	Parked []string `json:",omitempty"`
+}
+
+// summarize counts the results of reports by severity and grades the domain.
+func summarize(reports []Report) Summary {
+	s := Summary{Counts: make(map[string]int)}
+	type finding struct {
+		severity int
+		result   string
+	}
+	var findings []finding
+	for _, report := range reports {
+		for _, res := range report.Result {
+			status := resultStatus(res)
+			if status == "" {
+				continue
+			}
+			s.Counts[status]++
+			if status != "OK" {
+				findings = append(findings, finding{resultSeverity[status], report.Type + ": " + strings.SplitN(res.Result, "\n", 2)[0]})
+			}
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].severity > findings[j].severity })
+	for i := 0; i < len(findings) && i < summaryTop; i++ {
+		s.Top = append(s.Top, findings[i].result)
+	}
+	score := 100 - 15*s.Counts["FAIL"] - 10*s.Counts["ERR"] - 3*s.Counts["WARN"]
+	switch {
+	case score >= 90:
+		s.Grade = "A"
+	case score >= 80:
+		s.Grade = "B"
+	case score >= 70:
+		s.Grade = "C"
+	case score >= 60:
+		s.Grade = "D"
+	default:
+		s.Grade = "F"
+	}
+	return s
+}
@@ -0,0 +1,26 @@
+package dt
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// fallbackResolver is used when the system has no resolver configured.
+const fallbackResolver = "8.8.8.8"
+
+// resolvConf returns the nameservers of a resolv.conf file as host[:port].
+func resolvConf(file string) ([]string, error) {
+	config, err := dns.ClientConfigFromFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var servers []string
+	for _, server := range config.Servers {
+		if config.Port != "" && config.Port != "53" {
+			server = net.JoinHostPort(server, config.Port)
+		}
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
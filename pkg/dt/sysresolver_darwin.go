@@ -0,0 +1,40 @@
+package dt
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"strings"
+)
+
+// systemResolvers returns the resolvers of the default resolver in the
+// scutil --dns output, which unlike /etc/resolv.conf follows VPN and network
+// changes, falling back to /etc/resolv.conf.
+func systemResolvers() ([]string, error) {
+	out, err := exec.Command("scutil", "--dns").Output()
+	if err != nil {
+		return resolvConf("/etc/resolv.conf")
+	}
+	var servers []string
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "resolver #"):
+			if section != "" {
+				// the first resolver is the default one
+				return servers, nil
+			}
+			section = line
+		case strings.HasPrefix(line, "nameserver[") && section != "":
+			if i := strings.Index(line, ":"); i > 0 {
+				servers = append(servers, strings.TrimSpace(line[i+1:]))
+			}
+		}
+	}
+	if len(servers) == 0 {
+		return resolvConf("/etc/resolv.conf")
+	}
+	return servers, nil
+}
@@ -0,0 +1,8 @@
+//go:build !windows && !darwin
+
+package dt
+
+// systemResolvers returns the resolvers of /etc/resolv.conf.
+func systemResolvers() ([]string, error) {
+	return resolvConf("/etc/resolv.conf")
+}
@@ -0,0 +1,77 @@
+package dt
+
+import (
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// tcpipInterfaces are the registry keys holding the per interface TCP/IP
+// settings, IPv4 and IPv6.
+var tcpipInterfaces = []string{
+	`SYSTEM\CurrentControlSet\Services\Tcpip\Parameters\Interfaces`,
+	`SYSTEM\CurrentControlSet\Services\Tcpip6\Parameters\Interfaces`,
+}
+
+// regString returns the string value name of key, the first string of a
+// multi-string, or "" when it is not set.
+func regString(key syscall.Handle, name string) string {
+	p, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return ""
+	}
+	var typ, n uint32
+	if syscall.RegQueryValueEx(key, p, nil, &typ, nil, &n) != nil || n < 2 {
+		return ""
+	}
+	buf := make([]uint16, n/2+1)
+	if syscall.RegQueryValueEx(key, p, nil, &typ, (*byte)(unsafe.Pointer(&buf[0])), &n) != nil {
+		return ""
+	}
+	return syscall.UTF16ToString(buf)
+}
+
+// systemResolvers returns the nameservers of the interfaces with an address
+// from the registry, the static NameServer before the one from DHCP.
+func systemResolvers() ([]string, error) {
+	var servers []string
+	seen := make(map[string]bool)
+	for _, path := range tcpipInterfaces {
+		p, _ := syscall.UTF16PtrFromString(path)
+		var key syscall.Handle
+		if err := syscall.RegOpenKeyEx(syscall.HKEY_LOCAL_MACHINE, p, 0, syscall.KEY_READ, &key); err != nil {
+			continue
+		}
+		for i := uint32(0); ; i++ {
+			name := make([]uint16, 256)
+			n := uint32(len(name))
+			if syscall.RegEnumKeyEx(key, i, &name[0], &n, nil, nil, nil, nil) != nil {
+				break
+			}
+			var iface syscall.Handle
+			if syscall.RegOpenKeyEx(key, &name[0], 0, syscall.KEY_READ, &iface) != nil {
+				continue
+			}
+			ip := regString(iface, "IPAddress")
+			if ip == "" || ip == "0.0.0.0" {
+				ip = regString(iface, "DhcpIPAddress")
+			}
+			list := regString(iface, "NameServer")
+			if list == "" {
+				list = regString(iface, "DhcpNameServer")
+			}
+			syscall.RegCloseKey(iface)
+			if path == tcpipInterfaces[0] && (ip == "" || ip == "0.0.0.0") {
+				continue
+			}
+			for _, server := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' }) {
+				if !seen[server] {
+					seen[server] = true
+					servers = append(servers, server)
+				}
+			}
+		}
+		syscall.RegCloseKey(key)
+	}
+	return servers, nil
+}
@@ -0,0 +1,121 @@
+package dt
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// tcpQtypes are tried in order for a response too large for 512 bytes of
+// UDP, DNSSEC signed DNSKEY sets are the usual candidates.
+var tcpQtypes = []uint16{dns.TypeDNSKEY, dns.TypeTXT, dns.TypeNS, dns.TypeMX, dns.TypeSOA}
+
+// TCPCheck forces every nameserver address to truncate a UDP response and
+// verifies it then answers the same query over TCP, as resolvers will retry
+// it (RFC 7766).
+type TCPCheck struct {
+	NS  []NSData
+	TCP []TCPData
+	Report
+}
+
+type TCPData struct {
+	Name string
+	IP   string
+	// Qtype is the query retried over TCP, Truncated whether the UDP
+	// response to it had TC set.
+	Qtype     string
+	Truncated bool
+	// UDPError is set when the server doesn't answer at all, TCPError when
+	// it doesn't answer over TCP, Problem when the TCP answer is wrong.
+	UDPError string
+	TCPError string
+	Problem  string
+}
+
+func (c *TCPCheck) Scan(domain string) {
+	zone := dns.Fqdn(domain)
+	for _, ns := range c.NS {
+		for _, ip := range ns.IP {
+			c.TCP = append(c.TCP, TCPData{Name: ns.Name, IP: ns.addr(ip)})
+		}
+	}
+	sem := make(chan struct{}, c.s.concurrency)
+	var wg sync.WaitGroup
+	for i := range c.TCP {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(d *TCPData) {
+			defer func() { <-sem; wg.Done() }()
+			qtype := dns.TypeSOA
+			var udp *dns.Msg
+			for _, t := range tcpQtypes {
+				in, err := c.s.ednsExchange(c.s.ednsQuery(zone, t, 0, 512, 0x8000), d.IP, "udp")
+				if err != nil {
+					d.UDPError = err.Error()
+					return
+				}
+				if in.Truncated {
+					qtype, udp, d.Truncated = t, in, true
+					break
+				}
+			}
+			d.Qtype = dns.TypeToString[qtype]
+			in, err := c.s.ednsExchange(c.s.ednsQuery(zone, qtype, 0, 4096, 0x8000), d.IP, "tcp")
+			switch {
+			case err != nil:
+				d.TCPError = err.Error()
+			case ednsRcode(in) != dns.RcodeSuccess:
+				d.Problem = fmt.Sprintf("answered %s over TCP", dns.RcodeToString[ednsRcode(in)])
+			case in.Truncated:
+				d.Problem = "set TC over TCP"
+			case len(extractRR(in.Answer, qtype)) == 0:
+				d.Problem = fmt.Sprintf("answered without the %s records over TCP", d.Qtype)
+			case udp != nil && len(in.Answer) < len(udp.Answer):
+				d.Problem = fmt.Sprintf("answered %v records over TCP, fewer than the %v of the truncated UDP response", len(in.Answer), len(udp.Answer))
+			}
+		}(&c.TCP[i])
+	}
+	wg.Wait()
+}
+
+func (c *TCPCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	var truncated []string
+	for _, d := range c.TCP {
+		server := fmt.Sprintf("%s (%s)", d.Name, d.IP)
+		switch {
+		case d.UDPError != "":
+			results = append(results, ReportResult{Result: fmt.Sprintf("ERR : %s doesn't answer, TCP not checked: %s", server, d.UDPError),
+				Status: false, Name: "TCP", Server: d.IP, Error: d.UDPError, Unreachable: true})
+		case d.TCPError != "":
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: %s is not reachable on 53/tcp, truncated answers can't be retrieved: %s", server, d.TCPError),
+				Status: false, Name: "TCP", Error: d.TCPError,
+				Remediation: "Allow TCP port 53 to the nameserver, DNS over TCP is mandatory (RFC 7766)."})
+		case d.Problem != "":
+			results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: %s %s to the %s query truncated over UDP", server, d.Problem, d.Qtype),
+				Status: false, Name: "TCP",
+				Remediation: "Make sure the nameserver and any load balancer in front of it serve the full zone over TCP."})
+		case d.Truncated:
+			truncated = append(truncated, fmt.Sprintf("%s %s", server, d.Qtype))
+		}
+	}
+	if len(results) > 0 || len(c.TCP) == 0 {
+		return results
+	}
+	if len(truncated) == 0 {
+		return append(results, ReportResult{Result: fmt.Sprintf("OK  : All %v nameserver addresses answer over TCP (no response large enough to truncate, checked with SOA)", len(c.TCP)),
+			Status: true, Name: "TCP"})
+	}
+	return append(results, ReportResult{Result: fmt.Sprintf("OK  : All %v nameserver addresses answer over TCP, truncated UDP responses retried: %s", len(c.TCP), strings.Join(truncated, ", ")),
+		Status: true, Name: "TCP"})
+}
+
+func (c *TCPCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "TCP"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
@@ -0,0 +1,144 @@
+package dt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type ThreatCheck struct {
+	NS     []NSData
+	Feeds  []string
+	Nets   []ThreatNet
+	Target []ThreatTarget
+	Report
+}
+
+type ThreatNet struct {
+	Net  *net.IPNet
+	Feed string
+}
+
+type ThreatTarget struct {
+	Role string
+	Name string
+	IP   net.IP
+}
+
+// loadFeed reads an IP/CIDR list from a file or http(s) URL. Only the first
+// field of each line is used, so lists like Spamhaus DROP work as is.
+func loadFeed(feed string) ([]ThreatNet, error) {
+	var r io.ReadCloser
+	if strings.HasPrefix(feed, "http://") || strings.HasPrefix(feed, "https://") {
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Get(feed)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("fetching %s failed: %s", feed, resp.Status)
+		}
+		r = resp.Body
+	} else {
+		f, err := os.Open(feed)
+		if err != nil {
+			return nil, err
+		}
+		r = f
+	}
+	defer r.Close()
+
+	var nets []ThreatNet
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.FieldsFunc(scanner.Text(), func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ';' || r == ','
+		})
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		entry := fields[0]
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil {
+				if ip.To4() != nil {
+					entry += "/32"
+				} else {
+					entry += "/128"
+				}
+			}
+		}
+		_, ipnet, err := net.ParseCIDR(entry)
+		if err != nil {
+			continue
+		}
+		nets = append(nets, ThreatNet{Net: ipnet, Feed: feed})
+	}
+	return nets, scanner.Err()
+}
+
+func (c *ThreatCheck) Scan(domain string) {
+	for _, feed := range c.Feeds {
+		if feed == "" {
+			continue
+		}
+		nets, err := loadFeed(feed)
+		if err != nil {
+			c.Report.Result = append(c.Report.Result, ReportResult{Result: fmt.Sprintf("ERR : Loading threat feed %s failed: %s", feed, err)})
+			continue
+		}
+		c.s.log.Debugf("Loaded %v entries from threat feed %s", len(nets), feed)
+		c.Nets = append(c.Nets, nets...)
+	}
+
+	for _, ns := range c.NS {
+		for _, ip := range ns.IP {
+			c.Target = append(c.Target, ThreatTarget{Role: "NS", Name: ns.Name, IP: ip})
+		}
+	}
+	mx, _, _ := c.s.queryRRset(domain, dns.TypeMX, c.s.resolver, false)
+	for _, rr := range mx {
+		name := rr.(*dns.MX).Mx
+		for _, ip := range c.s.resolveHost(name) {
+			c.Target = append(c.Target, ThreatTarget{Role: "MX", Name: name, IP: ip})
+		}
+	}
+	for _, ip := range c.s.resolveHost(dns.Fqdn(domain)) {
+		c.Target = append(c.Target, ThreatTarget{Role: "apex", Name: dns.Fqdn(domain), IP: ip})
+	}
+}
+
+func (c *ThreatCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	if len(c.Nets) == 0 {
+		return results
+	}
+	for _, target := range c.Target {
+		for _, n := range c.Nets {
+			if n.Net.Contains(target.IP) {
+				results = append(results, ReportResult{Result: fmt.Sprintf("FAIL: %s %s (%s) matches %s in threat feed %s", target.Role, target.Name, target.IP, n.Net, n.Feed),
+					Status: false, Name: "Match", Remediation: "Investigate the host, move it off the listed network, or request delisting from the feed."})
+				break
+			}
+		}
+	}
+	if len(results) == 0 {
+		results = append(results, ReportResult{Result: fmt.Sprintf("OK  : None of your NS, MX or apex addresses are listed in %v threat feed entries", len(c.Nets)),
+			Status: true, Name: "Match"})
+	}
+	return results
+}
+
+func (c *ThreatCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "Threat"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
@@ -0,0 +1,79 @@
+package dt
+
+import (
+	"fmt"
+	"time"
+)
+
+// durationUnits are the units of FormatDuration, largest first.
+var durationUnits = []struct {
+	Name string
+	Unit time.Duration
+}{
+	{"d", 24 * time.Hour},
+	{"h", time.Hour},
+	{"m", time.Minute},
+	{"s", time.Second},
+}
+
+// inZone returns t in UTC with utc, in the local time zone otherwise.
+func inZone(t time.Time, utc bool) time.Time {
+	if utc {
+		return t.UTC()
+	}
+	return t.Local()
+}
+
+// FormatTime returns t as an RFC 3339 timestamp, in UTC with utc.
+func FormatTime(t time.Time, utc bool) string {
+	return inZone(t, utc).Format(time.RFC3339)
+}
+
+// FormatDuration returns d in its largest unit and the one below, like 3d 4h
+// or 5m 10s.
+func FormatDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	for i, u := range durationUnits {
+		if d < u.Unit {
+			continue
+		}
+		s := fmt.Sprintf("%v%s", int64(d/u.Unit), u.Name)
+		if i+1 < len(durationUnits) {
+			next := durationUnits[i+1]
+			if n := d % u.Unit / next.Unit; n > 0 {
+				s += fmt.Sprintf(" %v%s", int64(n), next.Name)
+			}
+		}
+		return s
+	}
+	return "0s"
+}
+
+// FormatRelative returns how long from now t is, like in 3d 4h or 2h 5m ago.
+func FormatRelative(t time.Time) string {
+	d := time.Until(t)
+	switch {
+	case d > -time.Second && d < time.Second:
+		return "now"
+	case d > 0:
+		return "in " + FormatDuration(d)
+	}
+	return FormatDuration(d) + " ago"
+}
+
+// FormatWhen returns t relative to now with the timestamp, like
+// in 3d 4h (2024-05-01T12:00:00Z), in UTC with utc.
+func FormatWhen(t time.Time, utc bool) string {
+	return fmt.Sprintf("%s (%s)", FormatRelative(t), FormatTime(t, utc))
+}
+
+// FormatTTL returns a TTL in seconds with the duration it is, like
+// 3600 (1h).
+func FormatTTL(ttl uint32) string {
+	if ttl == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%v (%s)", ttl, FormatDuration(time.Duration(ttl)*time.Second))
+}
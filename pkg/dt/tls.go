@@ -0,0 +1,200 @@
+package dt
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// certWarnDays is the number of days before expiry a certificate is reported.
+const certWarnDays = 14
+
+type TLSCheck struct {
+	NS    []NSData
+	Hosts []string
+	TLS   []TLSData
+	Report
+}
+
+type TLSData struct {
+	Host  string
+	IP    string
+	Certs []*x509.Certificate
+	Error string
+}
+
+// fetchCerts does a TLS handshake with ip on port 443 using host as SNI and
+// returns the presented chain without verifying it.
+func fetchCerts(host string, ip net.IP) ([]*x509.Certificate, error) {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", net.JoinHostPort(ip.String(), "443"),
+		&tls.Config{ServerName: host, InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate presented")
+	}
+	return certs, nil
+}
+
+// verifyChain checks the leaf certificate chains up to a trusted root using the
+// intermediates the server sent.
+func verifyChain(certs []*x509.Certificate) error {
+	pool := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		pool.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{Intermediates: pool})
+	return err
+}
+
+func (c *TLSCheck) hosts(domain string) []string {
+	apex := strings.TrimSuffix(dns.Fqdn(domain), ".")
+	hosts := []string{apex, "www." + apex}
+	for _, host := range c.Hosts {
+		if host != "" {
+			hosts = append(hosts, strings.TrimSuffix(host, "."))
+		}
+	}
+	return hosts
+}
+
+func (c *TLSCheck) Scan(domain string) {
+	for _, host := range c.hosts(domain) {
+		for _, ip := range c.s.resolveHost(host) {
+			data := TLSData{Host: host, IP: ip.String()}
+			certs, err := fetchCerts(host, ip)
+			if err != nil {
+				data.Error = err.Error()
+			}
+			data.Certs = certs
+			c.TLS = append(c.TLS, data)
+		}
+	}
+}
+
+func (c *TLSCheck) CheckCerts() []ReportResult {
+	rep := []ReportResult{}
+	for _, data := range c.TLS {
+		if data.Error != "" {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: TLS handshake with %s (%s) failed: %s", data.Host, data.IP, data.Error),
+				Status: false, Name: "Handshake", Remediation: "Make sure the host listens on port 443 with a valid certificate for its name."})
+			continue
+		}
+		cert := data.Certs[0]
+		rep = append(rep, ReportResult{Records: []string{fmt.Sprintf("%s (%s): issuer %q, SAN %v, valid until %s",
+			data.Host, data.IP, cert.Issuer.CommonName, cert.DNSNames, FormatTime(cert.NotAfter, c.s.useUTC))}})
+
+		switch {
+		case time.Now().After(cert.NotAfter):
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: Certificate for %s (%s) expired %s", data.Host, data.IP, FormatWhen(cert.NotAfter, c.s.useUTC)),
+				Status: false, Name: "Expiry", Remediation: "Renew the certificate and install it on every server."})
+		case time.Now().Add(certWarnDays * 24 * time.Hour).After(cert.NotAfter):
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("WARN: Certificate for %s (%s) expires %s", data.Host, data.IP, FormatWhen(cert.NotAfter, c.s.useUTC)),
+				Status: false, Name: "Expiry", Remediation: "Renew the certificate before it expires, ideally with automated renewal (ACME)."})
+		default:
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("OK  : Certificate for %s (%s) issued by %s expires %s", data.Host, data.IP, cert.Issuer.CommonName, FormatWhen(cert.NotAfter, c.s.useUTC)),
+				Status: true, Name: "Expiry"})
+		}
+
+		if err := cert.VerifyHostname(data.Host); err != nil {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: Certificate for %s (%s) does not cover %s (SAN: %v)", data.Host, data.IP, data.Host, cert.DNSNames),
+				Status: false, Name: "SAN", Remediation: "Issue a certificate that lists the hostname in its subject alternative names."})
+		}
+
+		if err := verifyChain(data.Certs); err != nil {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: Certificate chain for %s (%s) is not valid: %s", data.Host, data.IP, err),
+				Status: false, Name: "Chain", Remediation: "Install the full chain of intermediate certificates along with the certificate."})
+		}
+	}
+	return rep
+}
+
+func issuerName(cert *x509.Certificate) string {
+	if len(cert.Issuer.Organization) > 0 {
+		return cert.Issuer.Organization[0]
+	}
+	return cert.Issuer.CommonName
+}
+
+// CheckCAA cross-references the CAA records of the domain with the CAs that
+// issued the certificates actually served.
+func (c *TLSCheck) CheckCAA(domain string) []ReportResult {
+	rep := []ReportResult{}
+	caas := make(map[string][]dns.RR)
+	seen := make(map[string]bool)
+	for _, data := range c.TLS {
+		if len(data.Certs) == 0 {
+			continue
+		}
+		cert := data.Certs[0]
+		issuer := issuerName(cert)
+		if seen[data.Host+issuer] {
+			continue
+		}
+		seen[data.Host+issuer] = true
+
+		caa, ok := caas[data.Host]
+		if !ok {
+			caa = c.s.findCAA(data.Host)
+			caas[data.Host] = caa
+			if len(caa) == 0 {
+				rep = append(rep, ReportResult{Result: fmt.Sprintf("WARN: No CAA records found while %s serves TLS. Any CA may issue certificates for it.", data.Host),
+					Status: false, Name: "CAA", Remediation: "Add CAA records naming the CAs allowed to issue certificates for the domain."})
+			}
+		}
+		if len(caa) == 0 {
+			continue
+		}
+
+		allowed, found := caaIssue(caa, "issue")
+		for _, name := range cert.DNSNames {
+			if strings.HasPrefix(name, "*.") {
+				if wild, ok := caaIssue(caa, "issuewild"); ok {
+					allowed, found = wild, ok
+				}
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+		known := caaDomains(issuer)
+		if known == nil {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("WARN: Unknown CA %s issued the certificate for %s, can't cross-check with CAA", issuer, data.Host),
+				Status: false, Name: "CAA", Remediation: "Check the certificate was issued by a CA you use and add it to the CAA records."})
+			continue
+		}
+		authorized := false
+		for _, a := range allowed {
+			for _, k := range known {
+				if a == k {
+					authorized = true
+				}
+			}
+		}
+		if authorized {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("OK  : CAA records authorize %s, which issued the certificate for %s", issuer, data.Host),
+				Status: true, Name: "CAA"})
+		} else {
+			rep = append(rep, ReportResult{Result: fmt.Sprintf("FAIL: CAA records (%v) do not authorize %s, which issued the certificate for %s", allowed, issuer, data.Host),
+				Status: false, Name: "CAA", Remediation: "Add the issuing CA to the CAA records, or get the certificate from an authorized CA."})
+		}
+	}
+	return rep
+}
+
+func (c *TLSCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "TLS"
+	c.Report.Result = append(c.Report.Result, c.CheckCerts()...)
+	c.Report.Result = append(c.Report.Result, c.CheckCAA(domain)...)
+	return c.Report
+}
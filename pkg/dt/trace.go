@@ -0,0 +1,145 @@
+package dt
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// traceMaxHops bounds the referrals followed by Trace.
+const traceMaxHops = 30
+
+// TraceHop is one step of an iterative resolution: the zone asked, the
+// server that answered and what it answered.
+type TraceHop struct {
+	Zone   string
+	Server string
+	Rtt    time.Duration
+	Msg    *dns.Msg
+	// Glue are the servers of the next zone with their addresses, Resolved
+	// those without glue that had to be looked up.
+	Glue     []NSData
+	Resolved []string
+	Err      error
+}
+
+// referral returns the NS records of a referral to a zone below zone.
+func referral(msg *dns.Msg, zone string) []dns.RR {
+	var ns []dns.RR
+	for _, rr := range extractRR(msg.Ns, dns.TypeNS) {
+		name := rr.Header().Name
+		if !strings.EqualFold(name, zone) && dns.IsSubDomain(zone, name) {
+			ns = append(ns, rr)
+		}
+	}
+	return ns
+}
+
+// traceHop asks the servers of zone for name until one answers.
+func (s *session) traceHop(zone string, servers []NSData, name string, qtype uint16) TraceHop {
+	hop := TraceHop{Zone: zone, Err: fmt.Errorf("no servers for %s", zone)}
+	for _, ns := range servers {
+		for _, ip := range ns.IP {
+			hop.Server = fmt.Sprintf("%s (%s)", ns.Name, ip)
+			res, err := s.query(name, qtype, ip.String(), false)
+			if err != nil && !strings.Contains(err.Error(), "NXDOMAIN") {
+				s.log.Debugf("%s failed: %s", hop.Server, err)
+				hop.Err = err
+				continue
+			}
+			hop.Msg, hop.Rtt, hop.Err = res.Msg, res.Rtt, err
+			return hop
+		}
+	}
+	return hop
+}
+
+// resolveMissing looks up the addresses of the servers without glue through
+// the resolver and returns their names.
+func (s *session) resolveMissing(servers []NSData) []string {
+	var resolved []string
+	for i, nsdata := range servers {
+		if len(nsdata.IP) > 0 {
+			continue
+		}
+		servers[i].IP = s.inFamily(append(s.getIP(nsdata.Name, dns.TypeA, s.resolver), s.getIP(nsdata.Name, dns.TypeAAAA, s.resolver)...))
+		resolved = append(resolved, nsdata.Name)
+	}
+	return resolved
+}
+
+// TraceHops resolves name iteratively from the root servers, primed from the
+// root hints of opts (bundled when RootHints is empty), following every
+// referral like dig +trace.
+func TraceHops(name string, qtype uint16, opts Options) ([]TraceHop, error) {
+	s, err := newSession(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+	hints, err := s.loadRootHints(opts.RootHints)
+	if err != nil {
+		return nil, err
+	}
+	msg, _, err := s.primeRoot(hints)
+	if err != nil {
+		return nil, err
+	}
+	servers := s.rootNSData(extractRR(msg.Answer, dns.TypeNS), extractRR(msg.Extra, dns.TypeA, dns.TypeAAAA))
+	s.resolveMissing(servers)
+	name = dns.Fqdn(name)
+	zone := "."
+	var hops []TraceHop
+	for i := 0; i < traceMaxHops; i++ {
+		hop := s.traceHop(zone, servers, name, qtype)
+		if hop.Msg == nil {
+			return append(hops, hop), nil
+		}
+		ns := referral(hop.Msg, zone)
+		if len(hop.Msg.Answer) > 0 || len(ns) == 0 {
+			return append(hops, hop), nil
+		}
+		glue := extractRR(hop.Msg.Extra, dns.TypeA, dns.TypeAAAA)
+		hop.Glue = s.rootNSData(ns, glue)
+		hop.Resolved = s.resolveMissing(hop.Glue)
+		hops = append(hops, hop)
+		zone, servers = strings.ToLower(ns[0].Header().Name), hop.Glue
+	}
+	return hops, fmt.Errorf("more than %v referrals for %s", traceMaxHops, name)
+}
+
+// Trace prints the iterative resolution of name with every referral, the
+// server answering, the glue used and the round trip time per hop.
+func Trace(name, qtype string, opts Options) error {
+	t, err := parseType(qtype)
+	if err != nil {
+		return err
+	}
+	hops, err := TraceHops(name, t, opts)
+	for _, hop := range hops {
+		if hop.Msg == nil {
+			fmt.Printf("%s: no server answered: %s\n", hop.Zone, hop.Err)
+			continue
+		}
+		fmt.Printf("%s from %s in %v\n", hop.Zone, hop.Server, hop.Rtt)
+		if hop.Err != nil {
+			fmt.Printf("\t %s\n", hop.Err)
+		}
+		for _, rr := range append(hop.Msg.Answer, hop.Msg.Ns...) {
+			fmt.Printf("\t %s\n", rr)
+		}
+		for _, ns := range hop.Glue {
+			src := "glue"
+			for _, r := range hop.Resolved {
+				if r == ns.Name {
+					src = "resolved"
+				}
+			}
+			fmt.Printf("\t %s %s: %s\n", src, ns.Name, strings.Join(ipStrings(ns.IP), " "))
+		}
+		fmt.Println()
+	}
+	return err
+}
@@ -0,0 +1,543 @@
+package dt
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Limits on the data taken from responses, so a hostile server can't make a
+// scan fan out into thousands of queries or loop.
+const (
+	// maxRRs is the number of records extractRR returns.
+	maxRRs = 100
+	// maxCNAMEDepth is the length of the CNAME chain accepted in an answer.
+	maxCNAMEDepth = 8
+	// maxTXTLength is the length of the TXT values parsed.
+	maxTXTLength = 4096
+)
+
+func (s *session) getIP(host string, qtype uint16, server string) []net.IP {
+	var ips []net.IP
+	rrset, _, err := s.queryRRset(host, qtype, server, false)
+	if err != nil {
+		return ips
+	}
+	return extractIP(rrset)
+}
+
+func extractIP(rrset []dns.RR) []net.IP {
+	var ips []net.IP
+	for _, rr := range rrset {
+		switch rr.(type) {
+		case *dns.A:
+			ips = append(ips, rr.(*dns.A).A)
+		case *dns.AAAA:
+			ips = append(ips, rr.(*dns.AAAA).AAAA)
+		}
+	}
+	return ips
+}
+
+func extractRR(rrset []dns.RR, qtypes ...uint16) []dns.RR {
+	var out []dns.RR
+	m := make(map[uint16]bool)
+	for _, qtype := range qtypes {
+		m[qtype] = true
+	}
+	for _, rr := range rrset {
+		if _, ok := m[rr.Header().Rrtype]; !ok {
+			continue
+		}
+		if len(out) == maxRRs {
+			break
+		}
+		out = append(out, rr)
+	}
+	return out
+}
+
+// txtString returns the value of a TXT record, cut at maxTXTLength.
+func txtString(txt *dns.TXT) string {
+	s := strings.Join(txt.Txt, "")
+	if len(s) > maxTXTLength {
+		s = s[:maxTXTLength]
+	}
+	return s
+}
+
+// logBounds notes in the debug log what extractRR and txtString leave out of
+// the response in.
+func (s *session) logBounds(in *dns.Msg) {
+	for _, section := range [][]dns.RR{in.Answer, in.Ns, in.Extra} {
+		if len(section) > maxRRs {
+			s.log.Debugf("Ignoring records of %s beyond the first %v", section[maxRRs].Header().Name, maxRRs)
+		}
+		for _, rr := range section {
+			if txt, ok := rr.(*dns.TXT); ok && len(strings.Join(txt.Txt, "")) > maxTXTLength {
+				s.log.Debugf("Cutting TXT record of %s at %v bytes", txt.Hdr.Name, maxTXTLength)
+			}
+		}
+	}
+}
+
+func extractRRMsg(msg *dns.Msg, qtypes ...uint16) []dns.RR {
+	if msg != nil {
+		return extractRR(msg.Answer, qtypes...)
+	}
+	return []dns.RR{}
+}
+
+// serverAddr returns the address to send DNS queries for server to: server
+// itself when it is host:port, else port 53 of it.
+func serverAddr(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(server, "53")
+}
+
+// hostPort returns the server at port of host, host alone for port 53 so a
+// server is written the same way whether the port was given or not.
+func hostPort(host, port string) string {
+	if port == "" || port == "53" {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// serverHost returns the host of server, host or host:port.
+func serverHost(server string) string {
+	if host, _, err := net.SplitHostPort(server); err == nil {
+		return host
+	}
+	return server
+}
+
+// inFamily returns the addresses of ips in the family queries are
+// restricted to with -4 or -6, all of them otherwise.
+func (s *session) inFamily(ips []net.IP) []net.IP {
+	if s.ipFamily == 0 {
+		return ips
+	}
+	var kept []net.IP
+	for _, ip := range ips {
+		if (ip.To4() != nil) == (s.ipFamily == 4) {
+			kept = append(kept, ip)
+		}
+	}
+	return kept
+}
+
+// familyNet returns the network of proto ("udp", "tcp" or "tcp-tls") in the
+// family queries are restricted to.
+func (s *session) familyNet(proto string) string {
+	if s.ipFamily == 0 {
+		return proto
+	}
+	if proto == "tcp-tls" {
+		return fmt.Sprintf("tcp%v-tls", s.ipFamily)
+	}
+	return fmt.Sprintf("%s%v", proto, s.ipFamily)
+}
+
+// overrideNS builds the nameservers to check from a list of host[:port]
+// entries instead of the published delegation.
+func (s *session) overrideNS(servers []string) ([]NSData, error) {
+	var nsdatas []NSData
+	for _, server := range servers {
+		if server = strings.TrimSpace(server); server == "" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(server)
+		if err != nil {
+			host, port = strings.Trim(server, "[]"), ""
+		}
+		nsdata := NSData{Name: dns.Fqdn(host), Port: port}
+		if ip := net.ParseIP(host); ip != nil {
+			nsdata.Name = ip.String()
+			nsdata.IP = []net.IP{ip}
+		} else {
+			nsdata.IP = append(nsdata.IP, s.getIP(host, dns.TypeA, s.resolver)...)
+			nsdata.IP = append(nsdata.IP, s.getIP(host, dns.TypeAAAA, s.resolver)...)
+		}
+		if len(nsdata.IP) == 0 {
+			return nil, fmt.Errorf("can't resolve nameserver %s", host)
+		}
+		if nsdata.IP = s.inFamily(nsdata.IP); len(nsdata.IP) == 0 {
+			return nil, fmt.Errorf("nameserver %s has no IPv%v address", host, s.ipFamily)
+		}
+		for _, ip := range nsdata.IP {
+			nsdata.Info = append(nsdata.Info, NSInfo{IPInfo: IPInfo{IP: ip}, Name: nsdata.Name})
+		}
+		nsdatas = append(nsdatas, nsdata)
+	}
+	return nsdatas, nil
+}
+
+func (s *session) query(q string, qtype uint16, server string, sec bool) (Response, error) {
+	return s.queryNet(q, qtype, server, sec, "udp")
+}
+
+// queryNet is query over the given transport ("udp" or "tcp").
+func (s *session) queryNet(q string, qtype uint16, server string, sec bool, proto string) (Response, error) {
+	return s.queryClassNet(q, qtype, s.qclass, server, sec, proto)
+}
+
+// queryClass is query in another class than the one set with -class, like
+// CH for the version.bind and id.server queries.
+func (s *session) queryClass(q string, qtype, class uint16, server string, sec bool) (Response, error) {
+	return s.queryClassNet(q, qtype, class, server, sec, "udp")
+}
+
+// queryClassNet returns the answer also with an error response (NXDOMAIN,
+// SERVFAIL, ...), so the rcode and authority section can be inspected.
+func (s *session) queryClassNet(q string, qtype, class uint16, server string, sec bool, proto string) (Response, error) {
+	c := &dns.Client{Net: s.familyNet(proto), Timeout: s.queryTimeout}
+	if s.dotConfig != nil && server == s.resolver {
+		c.Net, c.TLSConfig = s.familyNet("tcp-tls"), s.dotConfig
+	}
+	m := prepMsg(q, qtype, class)
+	m.CheckingDisabled = true
+	m.RecursionDesired = true
+	if sec {
+		m.CheckingDisabled = false
+		m.SetEdns0(4096, true)
+	}
+	var resp Response
+	if s.offline {
+		return resp, errOffline
+	}
+	if err := s.ctx.Err(); err != nil {
+		return resp, err
+	}
+	atomic.AddInt64(&s.queryCount, 1)
+	in, rtt, err := c.Exchange(m, serverAddr(server))
+	s.recordRaw(m, in, server, c.Net, rtt, err)
+	if err != nil && err != dns.ErrTruncated && server == s.resolver {
+		in, rtt, server, err = s.failover(proto, m, err)
+	}
+	if err != nil {
+		return resp, err
+	}
+	resp = Response{Msg: in, Server: server, Rtt: rtt}
+	s.logBounds(in)
+	if qtype != dns.TypeCNAME && len(extractRR(in.Answer, dns.TypeCNAME)) > maxCNAMEDepth {
+		return Response{}, fmt.Errorf("CNAME chain of %s longer than %v", q, maxCNAMEDepth)
+	}
+	if in.Rcode != 0 {
+		return resp, fmt.Errorf("failure: %s", dns.RcodeToString[in.Rcode])
+	}
+	return resp, nil
+}
+
+// failover sends m over plain proto to the fallback resolvers in order after
+// the resolver failed with err, and returns the first response with the resolver sending
+// it.
+func (s *session) failover(proto string, m *dns.Msg, err error) (*dns.Msg, time.Duration, string, error) {
+	// the DoT settings and pins are those of the resolver, not of the fallbacks
+	c := &dns.Client{Net: s.familyNet(proto), Timeout: s.queryTimeout}
+	for _, server := range s.resolverFallbacks {
+		if s.ctx.Err() != nil {
+			break
+		}
+		s.log.Debugf("resolver %s failed: %s, trying %s", s.resolver, err, server)
+		atomic.AddInt64(&s.queryCount, 1)
+		in, rtt, ferr := c.Exchange(m, serverAddr(server))
+		s.recordRaw(m, in, server, c.Net, rtt, ferr)
+		if ferr == nil || ferr == dns.ErrTruncated {
+			return in, rtt, server, ferr
+		}
+	}
+	return nil, 0, s.resolver, err
+}
+
+// probeRounds returns the number of probes set with -probes, or def.
+func (s *session) probeRounds(def int) int {
+	if s.probes > 0 {
+		return s.probes
+	}
+	return def
+}
+
+func (s *session) queryRRset(q string, qtype uint16, server string, sec bool) ([]dns.RR, time.Duration, error) {
+	res, err := s.query(q, qtype, server, sec)
+	if err == dns.ErrTruncated || err == nil && res.Msg.Truncated {
+		// the rrset doesn't fit in UDP, get all of it over TCP like a resolver
+		s.log.Debugf("Truncated %s %s from %s, retrying over TCP", q, dns.TypeToString[qtype], server)
+		res, err = s.queryNet(q, qtype, server, sec, "tcp")
+	}
+	if err != nil {
+		return []dns.RR{}, 0, err
+	}
+	rrset := extractRR(res.Msg.Answer, qtype)
+	if len(rrset) == 0 {
+		return []dns.RR{}, 0, fmt.Errorf("no rr for %#v", qtype)
+	}
+	return rrset, res.Rtt, nil
+}
+
+func (s *session) findNS(domain string) ([]NSData, error) {
+	rrset, _, err := s.queryRRset(domain, dns.TypeNS, s.resolver, false)
+	if err != nil {
+		return []NSData{}, err
+	}
+	var nsdatas []NSData
+	for _, rr := range rrset {
+		var ips []net.IP
+		nsdata := NSData{}
+		ns := rr.(*dns.NS).Ns
+		nsdata.Name = ns
+		ips = append(ips, s.getIP(ns, dns.TypeA, s.resolver)...)
+		ips = append(ips, s.getIP(ns, dns.TypeAAAA, s.resolver)...)
+		ips = s.inFamily(ips)
+		var nsinfos []NSInfo
+		for _, ip := range ips {
+			nsinfos = append(nsinfos, NSInfo{IPInfo: IPInfo{IP: ip}, Name: ns})
+		}
+		nsdata.IP = ips
+		nsdata.Info = nsinfos
+		nsdatas = append(nsdatas, nsdata)
+	}
+	if len(nsdatas) == 0 {
+		return nsdatas, fmt.Errorf("no NS found")
+	}
+	return nsdatas, nil
+}
+
+// excludeNS removes the nameservers and addresses in exclude (names or IPs)
+// from nsdatas. It returns the remaining nameservers and a description of
+// every excluded one.
+func excludeNS(nsdatas []NSData, exclude []string) ([]NSData, []string) {
+	m := make(map[string]bool)
+	for _, e := range exclude {
+		if e = strings.TrimSpace(e); e == "" {
+			continue
+		}
+		if ip := net.ParseIP(e); ip != nil {
+			m[ip.String()] = true
+		} else {
+			m[strings.ToLower(dns.Fqdn(e))] = true
+		}
+	}
+	if len(m) == 0 {
+		return nsdatas, nil
+	}
+	var kept []NSData
+	var excluded []string
+	for _, nsdata := range nsdatas {
+		if m[strings.ToLower(nsdata.Name)] {
+			excluded = append(excluded, nsdata.Name)
+			continue
+		}
+		newdata := NSData{Name: nsdata.Name}
+		for _, info := range nsdata.Info {
+			if m[info.IP.String()] {
+				excluded = append(excluded, fmt.Sprintf("%s (%s)", nsdata.Name, info.IP))
+				continue
+			}
+			newdata.IP = append(newdata.IP, info.IP)
+			newdata.Info = append(newdata.Info, info)
+		}
+		if len(newdata.IP) > 0 {
+			kept = append(kept, newdata)
+		}
+	}
+	return kept, excluded
+}
+
+// familyNS drops the nameservers without an address in the family of -4 or
+// -6, as resolvers limited to it can't reach them. It returns the remaining
+// nameservers and the report of the address family.
+func (s *session) familyNS(nsdatas []NSData) ([]NSData, Report) {
+	report := Report{Type: "Address family"}
+	var kept []NSData
+	for _, nsdata := range nsdatas {
+		if len(nsdata.IP) == 0 {
+			report.Result = append(report.Result, ReportResult{Result: fmt.Sprintf("FAIL: %s has no IPv%v address, IPv%v-only resolvers can't reach it", nsdata.Name, s.ipFamily, s.ipFamily),
+				Status: false, Name: "AddressFamily", Remediation: fmt.Sprintf("Add an IPv%v address to the nameserver, or replace it with one that has one.", s.ipFamily)})
+			continue
+		}
+		kept = append(kept, nsdata)
+	}
+	if len(report.Result) == 0 {
+		report.Result = append(report.Result, ReportResult{Result: fmt.Sprintf("OK  : All %v nameservers have an IPv%v address", len(kept), s.ipFamily),
+			Status: true, Name: "AddressFamily"})
+	}
+	return kept, report
+}
+
+// parentReferral asks the nameservers of the parent zone for the NS of domain
+// and returns the first referral containing NS records.
+func (s *session) parentReferral(domain string) (*dns.Msg, error) {
+	nsdata, err := s.findNS(s.parentZone(domain))
+	if err != nil {
+		return nil, err
+	}
+	for _, ns := range nsdata {
+		for _, nsip := range ns.IP {
+			res, err := s.query(dns.Fqdn(domain), dns.TypeNS, ns.addr(nsip), true)
+			if err != nil {
+				continue
+			}
+			if len(extractRR(res.Msg.Ns, dns.TypeNS)) > 0 {
+				return res.Msg, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no referral found for %s at the parent nameservers", dns.Fqdn(domain))
+}
+
+func prepMsg(q string, qtype, class uint16) *dns.Msg {
+	m := new(dns.Msg)
+	m.Id = dns.Id()
+	m.RecursionDesired = true
+	m.Question = []dns.Question{{Name: dns.Fqdn(q), Qtype: qtype, Qclass: class}}
+	return m
+}
+
+func getParentDomain(domain string) string {
+	i, end := dns.NextLabel(domain, 0)
+	if !end {
+		return domain[i:]
+	}
+	return "."
+}
+
+func isRFC1918(ip net.IP) bool {
+	ten := net.IPNet{IP: net.ParseIP("10.0.0.0"), Mask: net.CIDRMask(8, 32)}
+	oneNineTwo := net.IPNet{IP: net.ParseIP("192.168.0.0"), Mask: net.CIDRMask(16, 32)}
+	oneSevenTwo := net.IPNet{IP: net.ParseIP("172.16.0.0"), Mask: net.CIDRMask(12, 32)}
+	return ten.Contains(ip) || oneNineTwo.Contains(ip) || oneSevenTwo.Contains(ip)
+}
+
+func isSameSubnet(ips ...net.IP) bool {
+	// ipv4 only for now
+	var ipnets []net.IPNet
+	ipv4 := 0
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			ipv4++
+			ipnets = append(ipnets, net.IPNet{IP: ip, Mask: net.CIDRMask(24, 32)})
+		}
+	}
+	count := 0
+	for _, ipnet := range ipnets {
+		for _, ip := range ips {
+			if ipnet.Contains(ip) {
+				count++
+			}
+		}
+	}
+	if count == ipv4*len(ipnets) {
+		return true
+	}
+	return false
+}
+
+func scanerror(r *Report, check, ns, ip, domain string, results []dns.RR, err error) bool {
+	fail := false
+	if err != nil {
+		if !strings.Contains(err.Error(), "NXDOMAIN") && !strings.Contains(err.Error(), "no rr for") {
+			r.Result = append(r.Result, ReportResult{Result: fmt.Sprintf("ERR : %s failed on %s (%s): %s", check, ns, ip, err),
+				Name: check, Error: err.Error(), Server: fmt.Sprintf("%s (%s)", ns, ip), Unreachable: true})
+		}
+		fail = true
+	}
+	if len(results) == 0 && err == nil {
+		//		r.Result = append(r.Result, ReportResult{Result: fmt.Sprintf("ERR : %s failed on %s (%s): %s", check, ns, ip, "no records found")})
+		fail = true
+	}
+	return fail
+}
+
+// serverCount returns the number of addresses of nsdatas.
+func serverCount(nsdatas []NSData) int {
+	n := 0
+	for _, ns := range nsdatas {
+		n += len(ns.IP)
+	}
+	return n
+}
+
+// nsAddr is an address of a nameserver and its index in the addresses of all
+// nameservers.
+type nsAddr struct {
+	i  int
+	ns NSData
+	ip net.IP
+}
+
+// probeOrder returns the addresses of nsdatas in the order to probe them:
+// random unless shuffling is turned off, so repeated runs don't always hit
+// the same server first.
+func (s *session) probeOrder(nsdatas []NSData) []nsAddr {
+	var addrs []nsAddr
+	for _, ns := range nsdatas {
+		for _, ip := range ns.IP {
+			addrs = append(addrs, nsAddr{len(addrs), ns, ip})
+		}
+	}
+	if s.shuffle {
+		rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	}
+	return addrs
+}
+
+// eachServer calls fn for every address of nsdatas, at most concurrency at a
+// time, in probe order. i is the index of the address in nsdatas, so callers
+// can store their data in a slice of serverCount(nsdatas) in a deterministic
+// order. fn gets a report of its own; its results are appended to r in
+// address order. A panic in fn is recorded as an error of that address. No
+// more addresses are started once the context of s is done.
+func (s *session) eachServer(nsdatas []NSData, r *Report, fn func(i int, ns NSData, ip net.IP, r *Report)) {
+	reports := make([]Report, serverCount(nsdatas))
+	sem := make(chan struct{}, s.concurrency)
+	var wg sync.WaitGroup
+	for _, addr := range s.probeOrder(nsdatas) {
+		if s.ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, ns NSData, ip net.IP) {
+			defer func() { <-sem; wg.Done() }()
+			defer func() {
+				if e := recover(); e != nil {
+					server := fmt.Sprintf("%s (%s)", ns.Name, ip)
+					reports[i].Result = append(reports[i].Result, ReportResult{Result: fmt.Sprintf("ERR : Check aborted on %s: %v", server, e),
+						Name: "Aborted", Error: fmt.Sprint(e), Server: server, Unreachable: true})
+				}
+			}()
+			fn(i, ns, ip, &reports[i])
+		}(addr.i, addr.ns, addr.ip)
+	}
+	wg.Wait()
+	for _, report := range reports {
+		r.Result = append(r.Result, report.Result...)
+	}
+}
+
+// respondingServer returns the first address of nsdatas answering the SOA
+// query for domain, so a single unreachable nameserver doesn't void a check.
+func (s *session) respondingServer(nsdatas []NSData, domain string) (string, bool) {
+	for _, addr := range s.probeOrder(nsdatas) {
+		if _, _, err := s.queryRRset(domain, dns.TypeSOA, addr.ns.addr(addr.ip), false); err == nil {
+			return addr.ns.addr(addr.ip), true
+		}
+	}
+	return "", false
+}
+
+func rrset2map(rrset []dns.RR, m map[dns.RR]bool) map[dns.RR]bool {
+	for _, rr := range rrset {
+		m[rr] = true
+	}
+	return m
+}
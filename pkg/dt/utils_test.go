@@ -0,0 +1,46 @@
+package dt
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// FuzzExtractTXT unpacks responses the way queries do and runs the answer
+// through extractRR and txtString, which must bound what they return.
+func FuzzExtractTXT(f *testing.F) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeTXT)
+	m.Response = true
+	for _, s := range []string{`example.com. 300 IN TXT "v=spf1 -all"`, `example.com. 300 IN TXT "a" "b" "c"`, "example.com. 300 IN A 192.0.2.1"} {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			f.Fatal(err)
+		}
+		m.Answer = append(m.Answer, rr)
+	}
+	seed, err := m.Pack()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(seed)
+	f.Fuzz(func(t *testing.T, wire []byte) {
+		in := new(dns.Msg)
+		if in.Unpack(wire) != nil {
+			return
+		}
+		txts := extractRR(in.Answer, dns.TypeTXT)
+		if len(txts) > maxRRs {
+			t.Fatalf("extractRR returned %v records, more than %v", len(txts), maxRRs)
+		}
+		for _, rr := range txts {
+			txt, ok := rr.(*dns.TXT)
+			if !ok {
+				t.Fatalf("extractRR returned %T for TXT", rr)
+			}
+			if s := txtString(txt); len(s) > maxTXTLength {
+				t.Fatalf("txtString returned %v bytes, more than %v", len(s), maxTXTLength)
+			}
+		}
+	})
+}
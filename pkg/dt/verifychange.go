@@ -0,0 +1,216 @@
+package dt
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// verifyInterval is the time between two verification rounds.
+const verifyInterval = 30 * time.Second
+
+// publicResolvers are asked besides -resolver to follow propagation.
+var publicResolvers = []string{"8.8.8.8", "1.1.1.1", "9.9.9.9"}
+
+// changeSpec holds the expected values per record type.
+type changeSpec map[uint16][]string
+
+// parseChangeSpec reads a spec with lines of a record type (NS, DS or A)
+// followed by the expected values, for example "NS ns1.example.net ns2.example.net".
+// DS values are digests.
+func parseChangeSpec(file string) (changeSpec, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	spec := make(changeSpec)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		var qtype uint16
+		switch strings.ToUpper(fields[0]) {
+		case "NS":
+			qtype = dns.TypeNS
+		case "DS":
+			qtype = dns.TypeDS
+		case "A":
+			qtype = dns.TypeA
+		default:
+			return nil, fmt.Errorf("unsupported record type %s in %s", fields[0], file)
+		}
+		for _, v := range fields[1:] {
+			spec[qtype] = append(spec[qtype], normalizeValue(qtype, v))
+		}
+	}
+	if len(spec) == 0 {
+		return nil, fmt.Errorf("no expected values in %s", file)
+	}
+	return spec, scanner.Err()
+}
+
+func normalizeValue(qtype uint16, v string) string {
+	switch qtype {
+	case dns.TypeNS:
+		return strings.ToLower(dns.Fqdn(v))
+	case dns.TypeDS:
+		return strings.ToUpper(v)
+	case dns.TypeA:
+		if ip := net.ParseIP(v); ip != nil {
+			return ip.String()
+		}
+	}
+	return v
+}
+
+func rrValues(rrset []dns.RR) []string {
+	var values []string
+	for _, rr := range rrset {
+		switch rr := rr.(type) {
+		case *dns.NS:
+			values = append(values, normalizeValue(dns.TypeNS, rr.Ns))
+		case *dns.DS:
+			values = append(values, normalizeValue(dns.TypeDS, rr.Digest))
+		case *dns.A:
+			values = append(values, rr.A.String())
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// changeSource is a server whose view on the change is verified.
+type changeSource struct {
+	Role   string
+	Name   string
+	Server string
+	Types  []uint16
+}
+
+// fetch returns the values of qtype seen by src. Parent servers return the
+// NS in the authority section of a referral.
+func (s *session) fetch(src changeSource, domain string, qtype uint16) []string {
+	res, err := s.query(domain, qtype, src.Server, false)
+	if err != nil {
+		return nil
+	}
+	rrset := extractRR(res.Msg.Answer, qtype)
+	if len(rrset) == 0 && src.Role == "parent" {
+		rrset = extractRR(res.Msg.Ns, qtype)
+	}
+	return rrValues(rrset)
+}
+
+func (s *session) changeSources(domain string, spec changeSpec) []changeSource {
+	var sources []changeSource
+	parents, _ := s.findNS(s.parentZone(domain))
+	for _, ns := range parents {
+		for _, ip := range ns.IP {
+			sources = append(sources, changeSource{Role: "parent", Name: ns.Name, Server: ip.String(), Types: []uint16{dns.TypeNS, dns.TypeDS}})
+		}
+	}
+	names := spec[dns.TypeNS]
+	if len(names) == 0 {
+		nsdatas, _ := s.findNS(dns.Fqdn(domain))
+		for _, ns := range nsdatas {
+			names = append(names, ns.Name)
+		}
+	}
+	for _, name := range names {
+		for _, ip := range s.resolveHost(name) {
+			sources = append(sources, changeSource{Role: "child", Name: name, Server: ip.String(), Types: []uint16{dns.TypeNS, dns.TypeA}})
+		}
+	}
+	seen := make(map[string]bool)
+	for _, r := range append([]string{s.resolver}, publicResolvers...) {
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+		sources = append(sources, changeSource{Role: "resolver", Name: r, Server: r, Types: []uint16{dns.TypeNS, dns.TypeDS, dns.TypeA}})
+	}
+	return sources
+}
+
+// VerifyChange polls the parent, the nameservers and public resolvers until
+// they all serve the change in file, or wait has passed.
+func VerifyChange(domain, file string, wait time.Duration, opts Options) error {
+	s, err := newSession(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	spec, err := parseChangeSpec(file)
+	if err != nil {
+		return err
+	}
+	domain = dns.Fqdn(domain)
+	sources := s.changeSources(domain, spec)
+	if len(sources) == 0 {
+		return fmt.Errorf("no servers found to verify %s", domain)
+	}
+
+	type check struct {
+		source changeSource
+		qtype  uint16
+		seen   []string
+		ok     bool
+	}
+	var checks []*check
+	for _, source := range sources {
+		for _, qtype := range source.Types {
+			if _, ok := spec[qtype]; ok {
+				checks = append(checks, &check{source: source, qtype: qtype})
+			}
+		}
+	}
+	deadline := time.Now().Add(wait)
+	for {
+		matched := 0
+		for _, c := range checks {
+			if !c.ok {
+				c.seen = s.fetch(c.source, domain, c.qtype)
+				expected := append([]string{}, spec[c.qtype]...)
+				sort.Strings(expected)
+				c.ok = strings.Join(c.seen, " ") == strings.Join(expected, " ")
+			}
+			if c.ok {
+				matched++
+			}
+		}
+		fmt.Printf("%s %v/%v match\n", inZone(time.Now(), s.useUTC).Format("15:04:05"), matched, len(checks))
+		if matched == len(checks) || time.Now().Add(verifyInterval).After(deadline) {
+			break
+		}
+		time.Sleep(verifyInterval)
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+	fmt.Fprintf(w, "Role\tServer\tIP\tType\tStatus\tSeen\n")
+	all := true
+	for _, c := range checks {
+		status := "OK"
+		if !c.ok {
+			status, all = "pending", false
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", c.source.Role, c.source.Name, c.source.Server, dns.TypeToString[c.qtype], status, strings.Join(c.seen, " "))
+	}
+	w.Flush()
+	if all {
+		fmt.Println("\nAll parent, child and resolver answers match the expected values")
+	} else {
+		fmt.Printf("\nNot all answers match the expected values after %v\n", wait)
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package dt
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// flatteningProviders are DNS providers known to synthesize apex records from
+// ALIAS/ANAME or CNAME flattening.
+var flatteningProviders = map[string]bool{
+	"Cloudflare": true, "Amazon Route 53": true, "DNSimple": true, "DNS Made Easy": true,
+	"NS1": true, "easyDNS": true, "Netlify": true, "Vercel": true, "Oracle Dyn": true,
+}
+
+// flatteningRounds is the number of times the apex is asked per nameserver.
+const flatteningRounds = 3
+
+type WebCheck struct {
+	NS  []NSData
+	Web []WebData
+	// HTTP allows CheckParity to follow redirects between apex and www.
+	HTTP bool
+	Report
+}
+
+type WebData struct {
+	Name  string
+	IP    string
+	A     []dns.RR
+	Apex  []dns.RR
+	Error string
+}
+
+func (c *WebCheck) Scan(domain string) {
+	c.Web = make([]WebData, serverCount(c.NS))
+	c.s.eachServer(c.NS, &c.Report, func(i int, ns NSData, nsip net.IP, r *Report) {
+		data := WebData{Name: ns.Name, IP: ns.addr(nsip)}
+		// www
+		rrset, _, err := c.s.queryRRset("www."+domain, dns.TypeA, ns.addr(nsip), true)
+		if !scanerror(r, "WWW ipv4 scan", ns.Name, ns.addr(nsip), domain, rrset, err) {
+			data.A = append(data.A, rrset...)
+		}
+		rrset, _, err = c.s.queryRRset("www."+domain, dns.TypeAAAA, ns.addr(nsip), true)
+		if !scanerror(r, "WWW ipv6 scan", ns.Name, ns.addr(nsip), domain, rrset, err) {
+			data.A = append(data.A, rrset...)
+		}
+		// apex
+		res, err := c.s.query(domain, dns.TypeA, ns.addr(nsip), true)
+		rrset = extractRRMsg(res.Msg, dns.TypeA)
+		if !scanerror(r, "root ipv4 scan", ns.Name, ns.addr(nsip), domain, rrset, err) {
+			data.Apex = append(data.Apex, rrset...)
+			data.Apex = append(data.Apex, extractRR(res.Msg.Answer, dns.TypeCNAME)...)
+		}
+		res, err = c.s.query(domain, dns.TypeAAAA, ns.addr(nsip), true)
+		rrset = extractRRMsg(res.Msg, dns.TypeAAAA)
+		if !scanerror(r, "root ipv6 scan", ns.Name, ns.addr(nsip), domain, rrset, err) {
+			data.Apex = append(data.Apex, rrset...)
+			data.Apex = append(data.Apex, extractRR(res.Msg.Answer, dns.TypeCNAME)...)
+		}
+		c.Web[i] = data
+	})
+}
+
+func (c *WebCheck) CheckWww() []ReportResult {
+	rep := []ReportResult{}
+	for _, web := range c.Web {
+		if len(web.A) > 0 {
+			rep = append(rep, ReportResult{Result: ("OK  : Found a www record"),
+				Status: true, Name: "WWW"})
+			break
+		}
+	}
+	if len(rep) == 0 {
+		rep = append(rep, ReportResult{Result: ("WARN: Didn't find a www record"),
+			Status: false, Name: "WWW", Remediation: "Add a www record (A/AAAA or CNAME) pointing at your website."})
+	}
+	return rep
+}
+
+func (c *WebCheck) checkRFC1918() bool {
+	for _, web := range c.Web {
+		ips := extractIP(web.A)
+		if len(ips) > 0 {
+			for _, ip := range ips {
+				if isRFC1918(ip) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (c *WebCheck) CheckApex() []ReportResult {
+	rep := []ReportResult{}
+	match := false
+	cmatch := false
+	for _, web := range c.Web {
+		if len(web.Apex) > 0 {
+			for _, rr := range web.Apex {
+				switch rr.(type) {
+				case *dns.A, *dns.AAAA:
+					if !match {
+						rep = append(rep, ReportResult{Result: ("OK  : Found a root record"),
+							Status: true, Name: "Apex"})
+						match = true
+					}
+				case *dns.CNAME:
+					cmatch = true
+					rep = append(rep, ReportResult{Result: ("WARN: Found a CNAME for the root record"),
+						Status: false, Name: "ApexCNAME", Remediation: "Replace the apex CNAME with A/AAAA records or your provider's ALIAS/ANAME feature."})
+				}
+				break
+			}
+		}
+	}
+	if len(rep) == 0 {
+		rep = append(rep, ReportResult{Result: ("WARN: Didn't find a root record"),
+			Status: false, Name: "Apex", Remediation: "Add A/AAAA records at the apex pointing at your website."})
+	}
+	if !cmatch {
+		rep = append(rep, ReportResult{Result: ("OK  : Didn't find a CNAME for the root record"),
+			Status: true, Name: "ApexCNAME"})
+	}
+	return rep
+}
+
+// CheckFlattening detects apex records synthesized by ALIAS/ANAME flattening
+// by comparing answers across queries and nameservers.
+func (c *WebCheck) CheckFlattening(domain string) []ReportResult {
+	rep := []ReportResult{}
+	sets := make(map[string]bool)
+	minTTL := uint32(0)
+	for _, ns := range c.NS {
+		for _, nsip := range ns.IP {
+			for i := 0; i < c.s.probeRounds(flatteningRounds); i++ {
+				rrset, _, err := c.s.queryRRset(domain, dns.TypeA, ns.addr(nsip), false)
+				if err != nil {
+					continue
+				}
+				var ips []string
+				for _, rr := range rrset {
+					ips = append(ips, rr.(*dns.A).A.String())
+					if minTTL == 0 || rr.Header().Ttl < minTTL {
+						minTTL = rr.Header().Ttl
+					}
+				}
+				sort.Strings(ips)
+				sets[strings.Join(ips, " ")] = true
+			}
+		}
+	}
+	if len(sets) == 0 {
+		return rep
+	}
+
+	var reasons []string
+	txt, _, _ := c.s.queryRRset(domain, dns.TypeTXT, c.s.resolver, false)
+	for _, rr := range txt {
+		if strings.HasPrefix(txtString(rr.(*dns.TXT)), "ALIAS for ") {
+			reasons = append(reasons, "ALIAS TXT record found")
+		}
+	}
+	provider := ""
+	for _, ns := range c.NS {
+		if p := c.s.nsProvider(ns); flatteningProviders[p] {
+			provider = p
+		}
+	}
+	if len(sets) > 1 && (minTTL <= 300 || provider != "") {
+		reasons = append(reasons, fmt.Sprintf("%v different answers with TTL %s", len(sets), FormatTTL(minTTL)))
+		if provider != "" {
+			reasons = append(reasons, fmt.Sprintf("hosted at %s which flattens apex records", provider))
+		}
+	}
+	if len(reasons) > 0 {
+		rep = append(rep, ReportResult{Result: fmt.Sprintf("OK  : Your root record is dynamically synthesized (ALIAS/ANAME flattening): %s", strings.Join(reasons, ", ")),
+			Status: true, Name: "Flattening"})
+	} else {
+		rep = append(rep, ReportResult{Result: "OK  : Your root record is static",
+			Status: true, Name: "Flattening"})
+	}
+	return rep
+}
+
+// networks returns the ISPs announcing ips, keyed by AS number.
+func (s *session) networks(ips []net.IP) map[string]string {
+	m := make(map[string]string)
+	infos, _ := s.ipinfos(ips)
+	for _, info := range infos {
+		if info.ASN != 0 {
+			m[info.ASN.String()] = info.ISP
+		}
+	}
+	return m
+}
+
+// redirectsTo reports whether http://from/ ends up on host to.
+func (s *session) redirectsTo(from, to string) bool {
+	var redirects int
+	resp, err := s.httpClient(&redirects).Get("http://" + from + "/")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return strings.EqualFold(resp.Request.URL.Hostname(), to)
+}
+
+// CheckParity verifies the apex and www both resolve and lead to the same
+// website: the same addresses, the same network (CDN) or a redirect from one
+// to the other.
+func (c *WebCheck) CheckParity(domain string) []ReportResult {
+	apex := strings.TrimSuffix(dns.Fqdn(domain), ".")
+	www := "www." + apex
+	apexIPs, wwwIPs := c.s.resolveHost(apex), c.s.resolveHost(www)
+	switch {
+	case len(apexIPs) == 0 && len(wwwIPs) == 0:
+		return nil
+	case len(apexIPs) == 0:
+		return []ReportResult{{Result: fmt.Sprintf("WARN: %s resolves but %s does not, visitors leaving out www get an error", www, apex),
+			Status: false, Name: "Parity", Remediation: fmt.Sprintf("Add A/AAAA (or ALIAS) records for %s pointing at the website or at a redirect to %s.", apex, www)}}
+	case len(wwwIPs) == 0:
+		return []ReportResult{{Result: fmt.Sprintf("WARN: %s resolves but %s does not, visitors typing www get an error", apex, www),
+			Status: false, Name: "Parity", Remediation: fmt.Sprintf("Add a www record (A/AAAA or a CNAME to %s).", apex)}}
+	}
+
+	addrs := make(map[string]bool)
+	for _, ip := range apexIPs {
+		addrs[ip.String()] = true
+	}
+	for _, ip := range wwwIPs {
+		if addrs[ip.String()] {
+			return []ReportResult{{Result: fmt.Sprintf("OK  : %s and %s point at the same addresses", apex, www),
+				Status: true, Name: "Parity"}}
+		}
+	}
+	apexNets := c.s.networks(apexIPs)
+	for asn, isp := range c.s.networks(wwwIPs) {
+		if _, ok := apexNets[asn]; ok {
+			return []ReportResult{{Result: fmt.Sprintf("OK  : %s and %s are served by the same network (%s %s)", apex, www, asn, isp),
+				Status: true, Name: "Parity"}}
+		}
+	}
+	if c.HTTP {
+		switch {
+		case c.s.redirectsTo(apex, www):
+			return []ReportResult{{Result: fmt.Sprintf("OK  : %s redirects to %s", apex, www), Status: true, Name: "Parity"}}
+		case c.s.redirectsTo(www, apex):
+			return []ReportResult{{Result: fmt.Sprintf("OK  : %s redirects to %s", www, apex), Status: true, Name: "Parity"}}
+		}
+	}
+	res := ReportResult{Result: fmt.Sprintf("WARN: %s (%s) and %s (%s) point at different destinations", apex, strings.Join(ipStrings(apexIPs), " "), www, strings.Join(ipStrings(wwwIPs), " ")),
+		Status: false, Name: "Parity", Remediation: "Serve the same website on both names or redirect one to the other."}
+	if !c.HTTP {
+		res.Result += ", use -web to check for a redirect between them"
+	}
+	return []ReportResult{res}
+}
+
+func (c *WebCheck) Values() []ReportResult {
+	var results []ReportResult
+	if !c.checkRFC1918() {
+		results = append(results, ReportResult{Result: "OK  : Your www record has a public / routable address.",
+			Status: true, Name: "RFC1918"})
+	} else {
+		results = append(results, ReportResult{Result: "FAIL: Your www record has a non-routable (RFC1918) address.",
+			Status: false, Name: "RFC1918", Remediation: "Point the www record at a public address."})
+	}
+	return results
+}
+
+func (c *WebCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "Web"
+	c.Report.Result = append(c.Report.Result, c.CheckWww()...)
+	c.Report.Result = append(c.Report.Result, c.CheckApex()...)
+	c.Report.Result = append(c.Report.Result, c.CheckParity(domain)...)
+	c.Report.Result = append(c.Report.Result, c.CheckFlattening(domain)...)
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}
@@ -0,0 +1,67 @@
+package dt
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// zoneCut returns the apex of the zone name is part of: name itself when it
+// is an apex, else the closest zone cut above it. The SOA in the answer or
+// the authority section of a SOA query for name names the zone, names that
+// don't tell (a CNAME to another zone, a lame response) are retried one
+// label up. It is false when no query got an answer.
+func (s *session) zoneCut(name string) (string, bool) {
+	name = strings.ToLower(dns.Fqdn(name))
+	s.cuts.Lock()
+	zone, ok := s.cuts.zones[name]
+	s.cuts.Unlock()
+	if ok {
+		return zone, true
+	}
+	answered := false
+	for n := name; zone == ""; n = getParentDomain(n) {
+		// NXDOMAIN and NODATA responses carry the SOA as well
+		res, _ := s.query(n, dns.TypeSOA, s.resolver, false)
+		if res.Msg != nil {
+			answered = true
+			for _, rr := range append(res.Msg.Answer, res.Msg.Ns...) {
+				if soa, ok := rr.(*dns.SOA); ok && dns.IsSubDomain(soa.Hdr.Name, n) {
+					zone = strings.ToLower(dns.Fqdn(soa.Hdr.Name))
+					break
+				}
+			}
+		}
+		if n == "." {
+			break
+		}
+	}
+	if !answered || zone == "" {
+		return "", false
+	}
+	s.cuts.Lock()
+	s.cuts.zones[name] = zone
+	s.cuts.Unlock()
+	return zone, true
+}
+
+// findZone returns the zone name is part of, the root when it can't be
+// found.
+func (s *session) findZone(name string) string {
+	if zone, ok := s.zoneCut(name); ok {
+		return zone
+	}
+	return "."
+}
+
+// parentZone returns the zone delegating domain, the zone of the name one
+// label up. That is the label above domain only when it is a zone apex:
+// example.pvt.k12.ma.us is delegated by k12.ma.us and a subzone by the zone
+// it is carved from. Without answers it falls back to the label above.
+func (s *session) parentZone(domain string) string {
+	parent := getParentDomain(dns.Fqdn(domain))
+	if zone, ok := s.zoneCut(parent); ok {
+		return zone
+	}
+	return dns.Fqdn(parent)
+}
@@ -0,0 +1,370 @@
+package dt
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// zoneDataTop is the number of records listed per zone data problem.
+const zoneDataTop = 10
+
+// ZoneRecord is a record of the zone data with where it came from.
+type ZoneRecord struct {
+	RR     dns.RR
+	Source string
+}
+
+// zoneLines finds the line each record in a zone file starts on, skipping
+// blank lines, comments, directives and the continuation of records between
+// parentheses.
+type zoneLines struct {
+	scanner *bufio.Scanner
+	n       int
+	depth   int
+}
+
+// next returns the line the next record starts on, or 0 at the end of the
+// file.
+func (z *zoneLines) next() int {
+	for z.scanner.Scan() {
+		z.n++
+		line := z.scanner.Text()
+		start := z.depth == 0
+		quoted, content := false, false
+	loop:
+		for i, r := range line {
+			switch {
+			case r == '"' && (i == 0 || line[i-1] != '\\'):
+				quoted = !quoted
+			case quoted:
+			case r == ';':
+				break loop
+			case r == '(':
+				z.depth++
+			case r == ')':
+				z.depth--
+			case r != ' ' && r != '\t':
+				if !content && start && r == '$' {
+					start = false
+				}
+				content = true
+			}
+		}
+		if start && content {
+			return z.n
+		}
+	}
+	return 0
+}
+
+// streamZoneFile parses a zone file and passes every record to fn with the
+// line it starts on.
+func streamZoneFile(file, origin string, fn func(ZoneRecord)) error {
+	lf, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer lf.Close()
+	lines := &zoneLines{scanner: bufio.NewScanner(lf)}
+	lines.scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for t := range dns.ParseZone(f, dns.Fqdn(origin), file) {
+		if t.Error != nil {
+			return t.Error
+		}
+		source := file
+		if n := lines.next(); n > 0 {
+			source = fmt.Sprintf("%s:%v", file, n)
+		}
+		fn(ZoneRecord{t.RR, source})
+	}
+	return nil
+}
+
+// streamZone passes every record of the zone file or, without one, of the
+// zone transfer from the first nameserver allowing it to fn and returns where
+// the records came from. The SOA closing the transfer is left out. A transfer
+// failing halfway is not retried on another server as the records read are
+// already consumed.
+func (s *session) streamZone(file string, nsdatas []NSData, apex string, fn func(ZoneRecord)) (string, error) {
+	if file != "" {
+		return file, streamZoneFile(file, apex, fn)
+	}
+	for _, ns := range nsdatas {
+		for _, ip := range ns.IP {
+			server := fmt.Sprintf("%s (%s)", ns.Name, ip)
+			// hold back the last record, it is the closing SOA
+			var held dns.RR
+			n := 0
+			err := s.streamTransfer(apex, ns.addr(ip), func(envelope []dns.RR) {
+				for _, rr := range envelope {
+					if held != nil {
+						n++
+						fn(ZoneRecord{held, fmt.Sprintf("AXFR %s #%v", server, n)})
+					}
+					held = rr
+				}
+			})
+			if held == nil {
+				continue
+			}
+			if n == 0 || held.Header().Rrtype != dns.TypeSOA {
+				n++
+				fn(ZoneRecord{held, fmt.Sprintf("AXFR %s #%v", server, n)})
+			}
+			return server, err
+		}
+	}
+	return "", nil
+}
+
+// ZoneDataCheck looks for duplicate and conflicting records in a zone file
+// or, without one, in a zone transfer. The records are streamed through a
+// disk backed sort, so zones of any size are checked in bounded memory.
+type ZoneDataCheck struct {
+	NS         []NSData
+	File       string
+	Source     string
+	Count      int
+	Duplicates zoneProblems
+	Conflicts  zoneProblems
+	SOAs       zoneProblems
+	ApexSOAs   int
+	Outside    zoneProblems
+	// Rdata are records with bogus rdata and names occluded by a DNAME.
+	Rdata zoneProblems
+	Err   error
+	// dname is the sort key of the last DNAME owner while checking names.
+	dname string
+	Report
+}
+
+// zoneProblems keeps the first zoneDataTop problems of a kind and counts all
+// of them.
+type zoneProblems struct {
+	List  []string
+	Total int
+}
+
+func (p *zoneProblems) add(problem string) {
+	p.Total++
+	if len(p.List) < zoneDataTop {
+		p.List = append(p.List, problem)
+	}
+}
+
+// zoneEntry is a record read back from the sorted zone data.
+type zoneEntry struct {
+	rtype                uint16
+	norm, source, record string
+}
+
+func (c *ZoneDataCheck) Scan(domain string) {
+	apex := strings.ToLower(dns.Fqdn(domain))
+	sorter := &spillSort{}
+	defer sorter.Close()
+	c.Source, c.Err = c.s.streamZone(c.File, c.NS, apex, func(r ZoneRecord) {
+		c.Count++
+		name := strings.ToLower(r.RR.Header().Name)
+		switch rr := r.RR.(type) {
+		case *dns.SOA:
+			c.SOAs.add(fmt.Sprintf("%s (%s)", rr, r.Source))
+			if name == apex {
+				c.ApexSOAs++
+			}
+		case *dns.NS:
+			if name != apex {
+				// marks the target as glue, sorting before its records
+				sorter.Add(canonicalKey(rr.Ns) + "\x000")
+			}
+		}
+		if problem := rdataProblem(r.RR); problem != "" {
+			c.Rdata.add(fmt.Sprintf("%s (%s): %s", r.RR, r.Source, problem))
+		}
+		if !dns.IsSubDomain(apex, name) {
+			c.Outside.add(fmt.Sprintf("%s (%s): outside of %s", r.RR, r.Source, apex))
+		}
+		sorter.Add(strings.Join([]string{canonicalKey(name), "1", fmt.Sprintf("%05d", r.RR.Header().Rrtype),
+			normalizedRR(r.RR), fmt.Sprintf("%012d", c.Count), r.Source, r.RR.String()}, "\x00"))
+	})
+	if c.Err == nil && c.Source == "" {
+		// no zone data, check the apex at least
+		if server, ok := c.s.respondingServer(c.NS, apex); ok {
+			for _, problem := range c.s.apexRdata(apex, server) {
+				c.Rdata.add(problem)
+			}
+		}
+	}
+	if c.Err != nil || c.Source == "" {
+		return
+	}
+
+	// the names come in canonical order, a zone cut before the names below it
+	var key, cut string
+	glue := false
+	var entries []zoneEntry
+	c.Err = sorter.Each(func(line string) {
+		fields := strings.SplitN(line, "\x00", 7)
+		if fields[0] != key {
+			cut = c.checkName(apex, key, cut, glue, entries)
+			key, glue, entries = fields[0], false, entries[:0]
+		}
+		if fields[1] == "0" {
+			glue = true
+			return
+		}
+		rtype, _ := strconv.Atoi(fields[2])
+		entries = append(entries, zoneEntry{uint16(rtype), fields[3], fields[5], fields[6]})
+	})
+	c.checkName(apex, key, cut, glue, entries)
+}
+
+// checkName checks the records of the name with the sort key and returns the
+// zone cut the names following it may be below.
+func (c *ZoneDataCheck) checkName(apex, key, cut string, glue bool, entries []zoneEntry) string {
+	if len(entries) == 0 {
+		return cut
+	}
+	name := keyName(key)
+	types := make(map[uint16][]string)
+	for i, e := range entries {
+		// identical records sort next to each other, in the order read
+		if i > 0 && e.norm == entries[i-1].norm {
+			first := i - 1
+			for first > 0 && entries[first-1].norm == e.norm {
+				first--
+			}
+			c.Duplicates.add(fmt.Sprintf("%s (%s, first at %s)", e.record, e.source, entries[first].source))
+		}
+		types[e.rtype] = append(types[e.rtype], e.source)
+	}
+
+	if cnames := types[dns.TypeCNAME]; len(cnames) > 0 {
+		var others []string
+		for t, sources := range types {
+			switch t {
+			case dns.TypeCNAME, dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3:
+				continue
+			}
+			others = append(others, fmt.Sprintf("%s at %s", dns.TypeToString[t], strings.Join(sources, ", ")))
+		}
+		if len(cnames) > 1 {
+			others = append(others, fmt.Sprintf("another CNAME at %s", strings.Join(cnames[1:], ", ")))
+		}
+		if len(others) > 0 {
+			sort.Strings(others)
+			c.Conflicts.add(fmt.Sprintf("%s: CNAME (%s) next to %s", name, cnames[0], strings.Join(others, "; ")))
+		}
+	}
+
+	// a DNAME replaces the names below its owner (RFC 6672 section 2.3)
+	if c.dname != "" && strings.HasPrefix(key, c.dname+"\x01") {
+		c.Rdata.add(fmt.Sprintf("%s: occluded by the DNAME at %s, it can't be queried", name, keyName(c.dname)))
+	} else if len(types[dns.TypeDNAME]) > 0 {
+		c.dname = key
+	} else {
+		c.dname = ""
+	}
+
+	// records below a zone cut other than glue for a delegation
+	if cut != "" && strings.HasPrefix(key, cut+"\x01") {
+		for _, e := range entries {
+			if glue && (e.rtype == dns.TypeA || e.rtype == dns.TypeAAAA) {
+				continue
+			}
+			c.Outside.add(fmt.Sprintf("%s (%s): below the zone cut at %s", e.record, e.source, keyName(cut)))
+		}
+		return cut
+	}
+	if len(types[dns.TypeNS]) > 0 && name != apex {
+		return key
+	}
+	return ""
+}
+
+// normalizedRR returns rr as text without TTL and with a lowercase owner, so
+// identical records compare equal.
+func normalizedRR(rr dns.RR) string {
+	hdr := *rr.Header()
+	rr.Header().Ttl = 0
+	rr.Header().Name = strings.ToLower(hdr.Name)
+	s := rr.String()
+	*rr.Header() = hdr
+	return s
+}
+
+// zoneDataResult lists the problems found, up to zoneDataTop.
+func zoneDataResult(status, msg string, problems zoneProblems, remediation string) ReportResult {
+	res := ReportResult{Result: fmt.Sprintf("%s: %s", status, msg), Status: false, Name: "ZoneData", Remediation: remediation}
+	for _, p := range problems.List {
+		res.Result += "\n\t   " + p
+	}
+	if problems.Total > len(problems.List) {
+		res.Result += fmt.Sprintf("\n\t   ... and %v more", problems.Total-len(problems.List))
+	}
+	return res
+}
+
+func (c *ZoneDataCheck) Values(domain string) []ReportResult {
+	results := []ReportResult{}
+	if c.Err != nil {
+		if c.File != "" {
+			return append(results, ReportResult{Result: fmt.Sprintf("ERR : Reading zone file %s failed: %s", c.File, c.Err),
+				Error: c.Err.Error(), Name: "ZoneData"})
+		}
+		return append(results, ReportResult{Result: fmt.Sprintf("ERR : Zone transfer from %s failed after %v records: %s", c.Source, c.Count, c.Err),
+			Error: c.Err.Error(), Name: "ZoneData"})
+	}
+	if c.Source == "" {
+		results = append(results, ReportResult{Result: "SKIP: Zone transfer refused by all nameservers, no zone data to verify (use -zonefile)",
+			Status: true, Name: "ZoneData"})
+		if c.Rdata.Total > 0 {
+			results = append(results, zoneDataResult("WARN", fmt.Sprintf("%v records with bogus data at the apex", c.Rdata.Total), c.Rdata,
+				"Fix or remove these records, resolvers and other tools silently ignore them."))
+		}
+		return results
+	}
+
+	if c.Duplicates.Total > 0 {
+		results = append(results, zoneDataResult("WARN", fmt.Sprintf("%v duplicate records in %s", c.Duplicates.Total, c.Source), c.Duplicates,
+			"Remove the duplicate records, servers silently merge them."))
+	}
+	if c.Conflicts.Total > 0 {
+		results = append(results, zoneDataResult("FAIL", fmt.Sprintf("%v names have a CNAME next to other data in %s (RFC 1034 section 3.6.2)", c.Conflicts.Total, c.Source), c.Conflicts,
+			"Keep only the CNAME or only the other records at these names."))
+	}
+	if c.SOAs.Total != 1 || c.ApexSOAs != 1 {
+		results = append(results, zoneDataResult("FAIL", fmt.Sprintf("%v SOA records in %s, the zone needs exactly one at the apex", c.SOAs.Total, c.Source), c.SOAs,
+			"Keep a single SOA record at the apex of the zone."))
+	}
+	if c.Outside.Total > 0 {
+		results = append(results, zoneDataResult("WARN", fmt.Sprintf("%v records outside of the zone in %s, servers ignore them", c.Outside.Total, c.Source), c.Outside,
+			"Move these records to the zone they belong to, only glue may be below a zone cut."))
+	}
+	if c.Rdata.Total > 0 {
+		results = append(results, zoneDataResult("WARN", fmt.Sprintf("%v records with bogus data in %s", c.Rdata.Total, c.Source), c.Rdata,
+			"Fix or remove these records, resolvers and other tools silently ignore them."))
+	}
+	if len(results) == 0 {
+		results = append(results, ReportResult{Result: fmt.Sprintf("OK  : No duplicate or conflicting records in %v records from %s", c.Count, c.Source),
+			Status: true, Name: "ZoneData"})
+	}
+	return results
+}
+
+func (c *ZoneDataCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "ZoneData"
+	c.Report.Result = append(c.Report.Result, c.Values(domain)...)
+	return c.Report
+}
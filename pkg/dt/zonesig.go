@@ -0,0 +1,231 @@
+package dt
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// zoneSigTop is the number of worst signature problems listed.
+const zoneSigTop = 10
+
+// ZoneSigCheck audits the signatures of every authoritative RRset of a zone
+// obtained by zone transfer, or read from File. The records are streamed
+// through a disk backed sort and only the worst problems are kept, so zones
+// of any size are audited in bounded memory.
+type ZoneSigCheck struct {
+	NS     []NSData
+	File   string
+	Server string
+	RRsets int
+	// Problems are the worst zoneSigTop problems, Counts the number of
+	// problems of each kind.
+	Problems []ZoneSigProblem
+	Counts   map[string]int
+	Err      error
+	Report
+}
+
+type ZoneSigProblem struct {
+	Name    string
+	Type    string
+	Problem string
+	// Expired is how long ago the newest signature expired.
+	Expired time.Duration
+}
+
+// zoneSigSeverity orders the problems, worst first.
+var zoneSigSeverity = map[string]int{"bogus": 0, "expired": 1, "unsigned": 2}
+
+func (c *ZoneSigCheck) Scan(domain string) {
+	apex := strings.ToLower(dns.Fqdn(domain))
+	c.Counts = make(map[string]int)
+	sorter := &spillSort{}
+	defer sorter.Close()
+	var keys []*dns.DNSKEY
+	c.Server, c.Err = c.s.streamZone(c.File, c.NS, apex, func(r ZoneRecord) {
+		name := strings.ToLower(r.RR.Header().Name)
+		qtype := r.RR.Header().Rrtype
+		switch v := r.RR.(type) {
+		case *dns.RRSIG:
+			qtype = v.TypeCovered
+		case *dns.DNSKEY:
+			if name == apex {
+				keys = append(keys, v)
+			}
+		}
+		// the wire format survives the round trip through the sort intact
+		buf := make([]byte, dns.Len(r.RR))
+		off, err := dns.PackRR(r.RR, buf, 0, nil, false)
+		if err != nil {
+			return
+		}
+		sorter.Add(fmt.Sprintf("%s\x00%05d\x00%s", canonicalKey(name), qtype, base64.StdEncoding.EncodeToString(buf[:off])))
+	})
+	if c.Err != nil || c.Server == "" {
+		return
+	}
+
+	// the names come in canonical order, a zone cut before the names below it
+	var key, cut string
+	var rrs []dns.RR
+	c.Err = sorter.Each(func(line string) {
+		fields := strings.SplitN(line, "\x00", 3)
+		if fields[0] != key {
+			cut = c.checkName(apex, key, cut, rrs, keys)
+			key, rrs = fields[0], rrs[:0]
+		}
+		wire, err := base64.StdEncoding.DecodeString(fields[2])
+		if err != nil {
+			return
+		}
+		if rr, _, err := dns.UnpackRR(wire, 0); err == nil {
+			rrs = append(rrs, rr)
+		}
+	})
+	c.checkName(apex, key, cut, rrs, keys)
+	c.sortProblems()
+}
+
+// checkName audits the RRsets of the name with the sort key and returns the
+// zone cut the names following it may be below. Only the NSEC and DS records
+// at a zone cut are authoritative, nothing below it is.
+func (c *ZoneSigCheck) checkName(apex, key, cut string, rrs []dns.RR, keys []*dns.DNSKEY) string {
+	if len(rrs) == 0 {
+		return cut
+	}
+	if cut != "" && strings.HasPrefix(key, cut+"\x01") {
+		return cut
+	}
+	name := keyName(key)
+	rrsets := make(map[uint16][]dns.RR)
+	sigs := make(map[uint16][]*dns.RRSIG)
+	for _, rr := range rrs {
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			sigs[sig.TypeCovered] = append(sigs[sig.TypeCovered], sig)
+			continue
+		}
+		rrsets[rr.Header().Rrtype] = append(rrsets[rr.Header().Rrtype], rr)
+	}
+	isCut := name != apex && len(rrsets[dns.TypeNS]) > 0
+
+	now := time.Now()
+	for qtype, rrset := range rrsets {
+		if isCut && qtype != dns.TypeDS && qtype != dns.TypeNSEC && qtype != dns.TypeNSEC3 {
+			continue
+		}
+		c.RRsets++
+		problem := ZoneSigProblem{Name: name, Type: dns.TypeToString[qtype]}
+		if len(sigs[qtype]) == 0 {
+			problem.Problem = "unsigned"
+			c.addProblem(problem)
+			continue
+		}
+		valid, verified := false, false
+		var newest *dns.RRSIG
+		for _, sig := range sigs[qtype] {
+			for _, key := range keys {
+				if key.KeyTag() != sig.KeyTag || key.Algorithm != sig.Algorithm {
+					continue
+				}
+				if sig.Verify(key, rrset) != nil {
+					continue
+				}
+				verified = true
+				if sig.ValidityPeriod(now) {
+					valid = true
+				}
+				if newest == nil || sig.Expiration > newest.Expiration {
+					newest = sig
+				}
+			}
+		}
+		switch {
+		case valid:
+			continue
+		case verified:
+			problem.Problem = "expired"
+			_, te := explicitValid(newest)
+			problem.Expired = now.Sub(time.Unix(te, 0))
+		default:
+			problem.Problem = "bogus"
+		}
+		c.addProblem(problem)
+	}
+	if isCut {
+		return key
+	}
+	return ""
+}
+
+// addProblem counts the problem and keeps it when it is among the worst.
+func (c *ZoneSigCheck) addProblem(problem ZoneSigProblem) {
+	c.Counts[problem.Problem]++
+	c.Problems = append(c.Problems, problem)
+	if len(c.Problems) > 4*zoneSigTop {
+		c.sortProblems()
+	}
+}
+
+// sortProblems orders the problems worst first and drops all but zoneSigTop.
+func (c *ZoneSigCheck) sortProblems() {
+	sort.Slice(c.Problems, func(i, j int) bool {
+		a, b := c.Problems[i], c.Problems[j]
+		if zoneSigSeverity[a.Problem] != zoneSigSeverity[b.Problem] {
+			return zoneSigSeverity[a.Problem] < zoneSigSeverity[b.Problem]
+		}
+		if a.Expired != b.Expired {
+			return a.Expired > b.Expired
+		}
+		return a.Name+a.Type < b.Name+b.Type
+	})
+	if len(c.Problems) > zoneSigTop {
+		c.Problems = c.Problems[:zoneSigTop]
+	}
+}
+
+func (c *ZoneSigCheck) Values() []ReportResult {
+	results := []ReportResult{}
+	if c.Err != nil {
+		return append(results, ReportResult{Result: fmt.Sprintf("ERR : Reading zone data from %s failed: %s", c.Server, c.Err),
+			Error: c.Err.Error(), Name: "Coverage"})
+	}
+	if c.Server == "" {
+		return append(results, ReportResult{Result: "SKIP: Zone transfer refused by all nameservers, can't audit all signatures",
+			Status: true, Name: "Coverage"})
+	}
+	total := c.Counts["bogus"] + c.Counts["expired"] + c.Counts["unsigned"]
+	if total == 0 {
+		return append(results, ReportResult{Result: fmt.Sprintf("OK  : All %v authoritative RRsets from %s have a valid signature", c.RRsets, c.Server),
+			Status: true, Name: "Coverage"})
+	}
+	res := ReportResult{Result: fmt.Sprintf("FAIL: %v of %v authoritative RRsets from %s lack a valid signature (%v bogus, %v expired, %v unsigned)",
+		total, c.RRsets, c.Server, c.Counts["bogus"], c.Counts["expired"], c.Counts["unsigned"]),
+		Status: false, Name: "Coverage", Remediation: "Re-sign the zone and check that the signer covers every RRset and refreshes signatures before they expire."}
+	for _, p := range c.Problems {
+		line := fmt.Sprintf("%s %s: %s", p.Name, p.Type, p.Problem)
+		if p.Problem == "expired" {
+			line += fmt.Sprintf(" %s ago", FormatDuration(p.Expired))
+		}
+		res.Result += "\n\t   " + line
+	}
+	if total > len(c.Problems) {
+		res.Result += fmt.Sprintf("\n\t   ... and %v more", total-len(c.Problems))
+	}
+	return append(results, res)
+}
+
+func (c *ZoneSigCheck) Dependency() Dependency {
+	return Dependency{Type: "ZoneSignatures", Requires: []string{"DNSKEY"}}
+}
+
+func (c *ZoneSigCheck) CreateReport(domain string) Report {
+	c.Scan(domain)
+	c.Report.Type = "ZoneSignatures"
+	c.Report.Result = append(c.Report.Result, c.Values()...)
+	return c.Report
+}